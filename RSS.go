@@ -1,14 +1,39 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
 	"fmt"
 	"html"
 	"io"
 	"net/http"
+	"time"
 )
 
+// maxFeedSize caps how much of a response fetchFeed reads, so a huge or
+// endless body can't exhaust memory.
+const maxFeedSize = 10 << 20
+
+// defaultFetchTimeout bounds a whole feed or article request, including
+// redirects and reading the body, unless fetch_timeout says otherwise.
+const defaultFetchTimeout = 30 * time.Second
+
+// fetcher downloads feeds and the articles of their posts, and sends alert
+// and webhook POSTs. All those URLs come from users, so agg's fetcher only
+// connects to public addresses (see publicClient), and publicOnly has
+// commands refuse other hosts up front; tests give theirs a client that
+// reaches their loopback servers.
+type fetcher struct {
+	client     *http.Client
+	timeout    time.Duration
+	publicOnly bool
+}
+
+func newFetcher() fetcher {
+	return fetcher{client: publicClient(), timeout: defaultFetchTimeout, publicOnly: true}
+}
+
 type RSSFeed struct {
 	Channel struct {
 		Title       string    `xml:"title"`
@@ -19,34 +44,49 @@ type RSSFeed struct {
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string `xml:"category"`
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+func fetchFeed(ctx context.Context, f fetcher, feedURL string) (*RSSFeed, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", "gator")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := f.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get resp: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read the resp.body: %w", err)
 	}
+	if len(body) > maxFeedSize {
+		return nil, fmt.Errorf("feed is larger than %d bytes", maxFeedSize)
+	}
 
+	// Feeds often use HTML named entities such as &nbsp; or &mdash;, which
+	// aren't defined in XML
 	var feed RSSFeed
-
-	if err := xml.Unmarshal(body, &feed); err != nil {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Entity = xml.HTMLEntity
+	if err := dec.Decode(&feed); err != nil {
 		return nil, fmt.Errorf("failed to parse body: %w", err)
 	}
 
@@ -56,6 +96,8 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	for i := range feed.Channel.Item {
 		feed.Channel.Item[i].Title = html.UnescapeString(feed.Channel.Item[i].Title)
 		feed.Channel.Item[i].Description = html.UnescapeString(feed.Channel.Item[i].Description)
+		feed.Channel.Item[i].Author = html.UnescapeString(feed.Channel.Item[i].Author)
+		feed.Channel.Item[i].Creator = html.UnescapeString(feed.Channel.Item[i].Creator)
 	}
 	return &feed, nil
 }
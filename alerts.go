@@ -0,0 +1,551 @@
+package main
+
+import (
+	"blog/internal/database"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Watches are saved searches. Every post scrapeFeed saves is matched against
+// the watches of the users following its feed; each hit is recorded as an
+// alert, which stays listed by `gator alerts` until it is acknowledged, and
+// is sent to the watch's notifier once the feed is saved.
+//
+// Watches live in the shared database but are notified by whoever runs agg,
+// so they can only name notifiers that are safe to run for anyone: webhooks
+// on public addresses, or none to only record their alerts. Commands, log
+// files and stdout are set in the alert_notify setting of the agg operator,
+// and watches reach them with --notify local.
+
+// notifyTimeout bounds a single exec or webhook notification, and
+// notifyDeadline all the notifications of a feed, so stuck commands or
+// endpoints can't stall agg.
+var (
+	notifyTimeout  = 10 * time.Second
+	notifyDeadline = 30 * time.Second
+)
+
+// notifyConcurrency is how many notifications of a feed are sent at once.
+const notifyConcurrency = 8
+
+// localNotify is the --notify value of watches sent to alert_notify, and
+// recordOnly the one of watches that only record their alerts.
+const (
+	localNotify = "local"
+	recordOnly  = "none"
+)
+
+func watchFlags(fs *flag.FlagSet) {
+	fs.String("feed", "", "only posts from the followed feed with this name or url")
+	fs.String("notify", recordOnly, "none|local|<webhook url>")
+}
+
+func handlerWatch(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+
+	switch action := cmd.Args[0]; action {
+	case "add":
+		if len(cmd.Args) != 3 {
+			return fmt.Errorf("usage: gator watch add [--feed <feed>] [--notify <notifier>] <name> <regex>")
+		}
+		feedID, err := followedFeedID(ctx, s.db, user, cmd.flagString("feed"))
+		if err != nil {
+			return err
+		}
+		watch, err := compileWatch(database.Watch{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UserID:    user.ID,
+			FeedID:    feedID,
+			Name:      cmd.Args[1],
+			Pattern:   cmd.Args[2],
+			Notify:    cmd.flagString("notify"),
+		}, s.fetcher.client)
+		if err != nil {
+			return err
+		}
+		_, err = s.db.CreateWatch(ctx, database.CreateWatchParams(watch.Watch))
+		if isUniqueViolation(err) {
+			return fmt.Errorf("you already have a watch named %s", watch.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add watch: %w", err)
+		}
+		if watch.Notify == recordOnly {
+			s.out.notef("Watching for %s: see its alerts with 'gator alerts'\n", watch.Pattern)
+			return nil
+		}
+		s.out.notef("Watching for %s, notifying %s\n", watch.Pattern, watch.Notify)
+		return nil
+
+	case "list":
+		if len(cmd.Args) != 1 {
+			return fmt.Errorf("usage: gator watch list")
+		}
+		watches, err := s.db.ListWatches(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list watches: %w", err)
+		}
+		if len(watches) == 0 {
+			s.out.notef("No watches yet: add one with 'gator watch add <name> <regex>'\n")
+		}
+		return s.out.render(watchesTable(watches))
+
+	case "remove":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator watch remove <name>")
+		}
+		n, err := s.db.DeleteWatch(ctx, database.DeleteWatchParams{UserID: user.ID, Name: cmd.Args[1]})
+		if err != nil {
+			return fmt.Errorf("failed to remove watch: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no watch named %s: see 'gator watch list'", cmd.Args[1])
+		}
+		s.out.notef("Removed watch %s and its alerts\n", cmd.Args[1])
+		return nil
+
+	case "test":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator watch test <name>")
+		}
+		watch, err := getWatch(ctx, s.db, user, cmd.Args[1], s.fetcher.client)
+		if err != nil {
+			return err
+		}
+		if watch.Notify == recordOnly {
+			return fmt.Errorf("watch %s only records its alerts: there is no notifier to test", watch.Name)
+		}
+		now := time.Now()
+		msg := alertMessage{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			Watch:     watch.Name,
+			Feed:      "gator",
+			Title:     "Test alert for " + watch.Name,
+			URL:       "https://example.com/gator-test-alert",
+		}
+		n := watch.notifier
+		if watch.Notify == localNotify {
+			local, err := parseLocalNotifier(s.sStruct.AlertNotify, s.fetcher.client)
+			if err != nil {
+				return fmt.Errorf("alert_notify: %w", err)
+			}
+			n = local
+		}
+		if err := n.notify(ctx, msg); err != nil {
+			return fmt.Errorf("notifying %s failed: %w", watch.Notify, err)
+		}
+		s.out.notef("Sent a test alert to %s\n", watch.Notify)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown watch action %q: expected add, list, remove or test", action)
+	}
+}
+
+// compiledWatch is a watch with its pattern compiled and its notifier set up.
+type compiledWatch struct {
+	database.Watch
+	re       *regexp.Regexp
+	notifier notifier
+}
+
+func compileWatch(watch database.Watch, client *http.Client) (compiledWatch, error) {
+	if watch.Name == "" || watch.Pattern == "" {
+		return compiledWatch{}, fmt.Errorf("a watch needs a name and a pattern")
+	}
+	re, err := regexp.Compile("(?i)" + watch.Pattern)
+	if err != nil {
+		return compiledWatch{}, fmt.Errorf("invalid pattern %q: %w", watch.Pattern, err)
+	}
+	n, err := parseNotifier(watch.Notify, client)
+	if err != nil {
+		return compiledWatch{}, err
+	}
+	return compiledWatch{Watch: watch, re: re, notifier: n}, nil
+}
+
+// matches reports whether the pattern occurs in the post's title or in the
+// text of its description or full content.
+func (w compiledWatch) matches(post database.Post) bool {
+	return w.re.MatchString(post.Title) ||
+		(post.Description.Valid && w.re.MatchString(htmlText(post.Description.String))) ||
+		(post.Content.Valid && w.re.MatchString(htmlText(post.Content.String)))
+}
+
+func getWatch(ctx context.Context, db database.Querier, user database.User, name string, client *http.Client) (compiledWatch, error) {
+	watch, err := db.GetWatchByName(ctx, database.GetWatchByNameParams{UserID: user.ID, Name: name})
+	if errors.Is(err, sql.ErrNoRows) {
+		return compiledWatch{}, fmt.Errorf("no watch named %s: see 'gator watch list'", name)
+	}
+	if err != nil {
+		return compiledWatch{}, fmt.Errorf("failed to get watch: %w", err)
+	}
+	return compileWatch(watch, client)
+}
+
+// feedWatches returns the compiled watches of everyone following a feed. A
+// watch that fails to compile is reported and left out. Webhooks are sent
+// with client.
+func feedWatches(ctx context.Context, db database.Querier, feedID uuid.UUID, client *http.Client) ([]compiledWatch, error) {
+	rows, err := db.ListWatchesForFeed(ctx, feedID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get watches: %w", err)
+	}
+	var watches []compiledWatch
+	var errs []error
+	for _, w := range rows {
+		watch, err := compileWatch(w, client)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("watch %s: %w", w.Name, err))
+			continue
+		}
+		watches = append(watches, watch)
+	}
+	return watches, errors.Join(errs...)
+}
+
+// raiseAlert records that a watch matched a new post and returns the alert
+// for sendAlerts.
+func raiseAlert(ctx context.Context, db database.Querier, watch compiledWatch, feed database.Feed, post database.Post) (pendingAlert, error) {
+	alert, err := db.CreateAlert(ctx, database.CreateAlertParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		WatchID:   watch.ID,
+		PostID:    post.ID,
+	})
+	if err != nil {
+		return pendingAlert{}, fmt.Errorf("couldn't record alert: %w", err)
+	}
+	msg := alertMessage{
+		ID:        alert.ID,
+		CreatedAt: alert.CreatedAt,
+		Watch:     watch.Name,
+		Feed:      feed.Name,
+		Title:     post.Title,
+		URL:       post.Url,
+	}
+	if post.PublishedAt.Valid {
+		msg.PublishedAt = &post.PublishedAt.Time
+	}
+	return pendingAlert{watch: watch, msg: msg}, nil
+}
+
+// pendingAlert is an alert that is recorded but not sent yet.
+type pendingAlert struct {
+	watch compiledWatch
+	msg   alertMessage
+}
+
+// sendAlerts sends alerts to their watches' notifiers, a few at a time, and
+// gives up on the ones still unsent after notifyDeadline. Watches notifying
+// local use the agg operator's notifier if they are theirs, and are only
+// recorded otherwise, like the ones of watches notifying none. The alerts
+// are kept whatever happens here.
+func sendAlerts(ctx context.Context, alerts []pendingAlert, local *localNotifier) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyDeadline)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, notifyConcurrency)
+	)
+	for _, alert := range alerts {
+		n := alert.watch.notifier
+		if alert.watch.Notify == recordOnly {
+			continue
+		}
+		if alert.watch.Notify == localNotify {
+			if local == nil || local.userID != alert.watch.UserID {
+				continue
+			}
+			n = local.notifier
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("watch %s on %s: %w", alert.watch.Name, alert.msg.URL, ctx.Err()))
+				mu.Unlock()
+				return
+			}
+			if err := n.notify(ctx, alert.msg); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("watch %s on %s: couldn't notify %s: %w", alert.watch.Name, alert.msg.URL, alert.watch.Notify, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// localNotifier is the alert_notify setting of whoever runs agg, which only
+// serves their own watches.
+type localNotifier struct {
+	userID   uuid.UUID
+	notifier notifier
+}
+
+// loadLocalNotifier sets up alert_notify for the logged in user, or returns
+// nil if it isn't set.
+func loadLocalNotifier(ctx context.Context, s *State) (*localNotifier, error) {
+	if s.sStruct.AlertNotify == "" {
+		return nil, nil
+	}
+	n, err := parseLocalNotifier(s.sStruct.AlertNotify, s.fetcher.client)
+	if err != nil {
+		return nil, fmt.Errorf("alert_notify: %w", err)
+	}
+	key := currentAPIKey(s)
+	if key == "" {
+		return nil, fmt.Errorf("alert_notify only serves your own watches: log in first")
+	}
+	user, err := authenticate(ctx, s.db, key)
+	if err != nil {
+		return nil, fmt.Errorf("alert_notify: %w", err)
+	}
+	return &localNotifier{userID: user.ID, notifier: n}, nil
+}
+
+// alertMessage is what notifiers send: one line of text, or JSON for
+// commands and webhooks.
+type alertMessage struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Watch       string     `json:"watch"`
+	Feed        string     `json:"feed"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+func (m alertMessage) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s <%s>", m.CreatedAt.Format(time.RFC3339), m.Watch, m.Feed, m.Title, m.URL)
+}
+
+type notifier interface {
+	notify(ctx context.Context, msg alertMessage) error
+}
+
+// parseNotifier turns a watch's --notify value into its notifier, sending
+// webhooks with client. Local and record-only watches get none: sendAlerts
+// and `watch test` look up alert_notify for the former.
+func parseNotifier(spec string, client *http.Client) (notifier, error) {
+	switch {
+	case spec == localNotify, spec == recordOnly:
+		return nil, nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return webhookNotifier{url: spec, client: client}, nil
+	case spec == "stdout", strings.HasPrefix(spec, "file:"), strings.HasPrefix(spec, "exec:"):
+		return nil, fmt.Errorf("watches are run by whoever runs agg, so they can't print, write files or run commands there: set yours with 'gator config set alert_notify %s' and use --notify local", spec)
+	default:
+		return nil, fmt.Errorf("unknown notifier %q: expected none, local or a webhook url", spec)
+	}
+}
+
+// parseLocalNotifier turns the alert_notify setting into its notifier.
+func parseLocalNotifier(spec string, client *http.Client) (notifier, error) {
+	switch {
+	case spec == "stdout":
+		return writerNotifier{os.Stdout}, nil
+	case strings.HasPrefix(spec, "file:"):
+		path := strings.TrimPrefix(spec, "file:")
+		if path == "" {
+			return nil, fmt.Errorf("file notifier needs a path, e.g. file:~/gator-alerts.log")
+		}
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = home + "/" + rest
+			}
+		}
+		return fileNotifier{path}, nil
+	case strings.HasPrefix(spec, "exec:"):
+		args := strings.Fields(strings.TrimPrefix(spec, "exec:"))
+		if len(args) == 0 {
+			return nil, fmt.Errorf("exec notifier needs a command, e.g. exec:notify-send")
+		}
+		return execNotifier{args}, nil
+	case spec == localNotify, spec == recordOnly:
+		return nil, fmt.Errorf("alert_notify can't be %s itself", spec)
+	case spec == "":
+		return nil, fmt.Errorf("alert_notify is not set: see 'gator config set'")
+	default:
+		return parseNotifier(spec, client)
+	}
+}
+
+type writerNotifier struct {
+	w io.Writer
+}
+
+func (n writerNotifier) notify(ctx context.Context, msg alertMessage) error {
+	_, err := fmt.Fprintln(n.w, msg)
+	return err
+}
+
+// fileNotifier appends a line per alert to a log file.
+type fileNotifier struct {
+	path string
+}
+
+func (n fileNotifier) notify(ctx context.Context, msg alertMessage) error {
+	f, err := os.OpenFile(n.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, msg); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// execNotifier runs a command per alert, with the alert as JSON on stdin and
+// its fields in GATOR_ALERT_* environment variables.
+type execNotifier struct {
+	args []string
+}
+
+func (n execNotifier) notify(ctx context.Context, msg alertMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	c := exec.CommandContext(ctx, n.args[0], n.args[1:]...)
+	c.Stdin = bytes.NewReader(data)
+	c.Env = append(os.Environ(),
+		"GATOR_ALERT_WATCH="+msg.Watch,
+		"GATOR_ALERT_FEED="+msg.Feed,
+		"GATOR_ALERT_TITLE="+msg.Title,
+		"GATOR_ALERT_URL="+msg.URL,
+	)
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", n.args[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// webhookNotifier POSTs the alert as JSON and expects a 2xx response.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n webhookNotifier) notify(ctx context.Context, msg alertMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gator")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+func watchesTable(watches []database.ListWatchesRow) ([]string, [][]any) {
+	rows := make([][]any, 0, len(watches))
+	for _, w := range watches {
+		feed := "(all)"
+		if w.FeedID.Valid {
+			feed = w.FeedName.String
+		}
+		rows = append(rows, []any{w.Name, w.Pattern, feed, w.Notify, w.Unacknowledged})
+	}
+	return []string{"name", "pattern", "feed", "notify", "unacknowledged"}, rows
+}
+
+func alertsFlags(fs *flag.FlagSet) {
+	fs.Bool("acknowledged", false, "also list alerts already acknowledged")
+}
+
+// handlerAlerts lists the alerts raised by the user's watches, or
+// acknowledges them with `gator alerts ack <alert_id>...|all`.
+func handlerAlerts(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+
+	if len(cmd.Args) > 0 {
+		if cmd.Args[0] != "ack" || len(cmd.Args) == 1 {
+			return fmt.Errorf("usage: gator alerts [ack <alert_id>... | ack all]")
+		}
+		if len(cmd.Args) == 2 && cmd.Args[1] == "all" {
+			n, err := s.db.AcknowledgeAllAlerts(ctx, user.ID)
+			if err != nil {
+				return fmt.Errorf("failed to acknowledge alerts: %w", err)
+			}
+			s.out.notef("Acknowledged %d alerts\n", n)
+			return nil
+		}
+		for _, id := range cmd.Args[1:] {
+			alertID, err := uuid.Parse(id)
+			if err != nil {
+				return fmt.Errorf("invalid alert id %q: use the id shown by 'gator alerts'", id)
+			}
+			n, err := s.db.AcknowledgeAlert(ctx, database.AcknowledgeAlertParams{ID: alertID, UserID: user.ID})
+			if err != nil {
+				return fmt.Errorf("failed to acknowledge alert: %w", err)
+			}
+			if n == 0 {
+				return fmt.Errorf("no unacknowledged alert %s", id)
+			}
+		}
+		s.out.notef("Acknowledged %d alerts\n", len(cmd.Args)-1)
+		return nil
+	}
+
+	alerts, err := s.db.ListAlerts(ctx, database.ListAlertsParams{UserID: user.ID, IncludeAcknowledged: cmd.flagBool("acknowledged")})
+	if err != nil {
+		return fmt.Errorf("failed to list alerts: %w", err)
+	}
+	if len(alerts) == 0 {
+		s.out.notef("No alerts\n")
+	} else {
+		s.out.notef("%d alerts, acknowledge them with 'gator alerts ack <alert_id>...|all':\n", len(alerts))
+	}
+	rows := make([][]any, 0, len(alerts))
+	for _, a := range alerts {
+		rows = append(rows, []any{a.ID, a.WatchName, a.FeedName, a.Title, a.Url, a.CreatedAt, a.AcknowledgedAt})
+	}
+	return s.out.render([]string{"id", "watch", "feed_name", "title", "url", "created_at", "acknowledged_at"}, rows)
+}
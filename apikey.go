@@ -0,0 +1,150 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API keys look like gtr_<prefix><secret>. Only a SHA-256 of the whole key is
+// stored; the prefix is kept in clear so keys can be listed and revoked.
+const (
+	apiKeyScheme    = "gtr_"
+	apiKeyPrefixLen = 8
+)
+
+var errNotLoggedIn = errors.New("not logged in: run 'gator login <username> --key <api key>'")
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSecret returns a random key starting with scheme, and the part after
+// the scheme, whose first apiKeyPrefixLen characters identify the key.
+func newSecret(scheme string) (key, body string, err error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	body = hex.EncodeToString(secret)
+	return scheme + body, body, nil
+}
+
+// createAPIKey stores a new key for user and returns it. The plain key is
+// only available here; it can't be recovered from the database later.
+func createAPIKey(ctx context.Context, db database.Querier, user database.User, name string) (string, database.ApiKey, error) {
+	key, body, err := newSecret(apiKeyScheme)
+	if err != nil {
+		return "", database.ApiKey{}, fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	apiKey, err := db.CreateAPIKey(ctx, database.CreateAPIKeyParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    body[:apiKeyPrefixLen],
+		KeyHash:   hashAPIKey(key),
+	})
+	if err != nil {
+		return "", apiKey, fmt.Errorf("failed to store api key: %w", err)
+	}
+	return key, apiKey, nil
+}
+
+// authenticate resolves an API key to its user and records that it was used.
+func authenticate(ctx context.Context, db database.Querier, key string) (database.User, error) {
+	if !strings.HasPrefix(key, apiKeyScheme) {
+		return database.User{}, fmt.Errorf("malformed api key")
+	}
+
+	row, err := db.GetUserByAPIKeyHash(ctx, hashAPIKey(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("invalid or revoked api key")
+	}
+	if err != nil {
+		return database.User{}, fmt.Errorf("failed to check api key: %w", err)
+	}
+
+	if err := db.TouchAPIKey(ctx, row.ApiKeyID); err != nil {
+		return database.User{}, fmt.Errorf("failed to record api key use: %w", err)
+	}
+
+	return database.User{
+		ID:           row.ID,
+		CreatedAt:    row.CreatedAt,
+		UpdatedAt:    row.UpdatedAt,
+		Name:         row.Name,
+		PasswordHash: row.PasswordHash,
+		FailedLogins: row.FailedLogins,
+		LockedUntil:  row.LockedUntil,
+	}, nil
+}
+
+// currentAPIKey is GATOR_API_KEY if set, otherwise the key saved by login.
+func currentAPIKey(s *State) string {
+	if key := os.Getenv("GATOR_API_KEY"); key != "" {
+		return key
+	}
+	return s.sStruct.APIKey
+}
+
+func handlerAPIKey(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+
+	switch cmd.Args[0] {
+	case "create":
+		name := "cli"
+		if len(cmd.Args) == 2 {
+			name = cmd.Args[1]
+		}
+		key, apiKey, err := createAPIKey(ctx, s.db, user, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out.w, "Created api key %s (%s) for %s:\n\n    %s\n\n", apiKey.Prefix, apiKey.Name, user.Name, key)
+		fmt.Fprintln(s.out.w, "Store it now, it can't be shown again.")
+		return nil
+
+	case "list":
+		keys, err := s.db.ListAPIKeysForUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list api keys: %w", err)
+		}
+		rows := make([][]any, 0, len(keys))
+		for _, k := range keys {
+			rows = append(rows, []any{k.Prefix, k.Name, k.CreatedAt, k.LastUsedAt, k.RevokedAt})
+		}
+		return s.out.render([]string{"prefix", "name", "created_at", "last_used_at", "revoked_at"}, rows)
+
+	case "revoke":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator apikey revoke <prefix>")
+		}
+		n, err := s.db.RevokeAPIKey(ctx, database.RevokeAPIKeyParams{
+			UserID: user.ID,
+			Prefix: cmd.Args[1],
+		})
+		if err != nil {
+			return fmt.Errorf("failed to revoke api key: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no active api key with prefix %s", cmd.Args[1])
+		}
+		s.out.notef("Revoked api key %s\n", cmd.Args[1])
+		return nil
+	}
+
+	return fmt.Errorf("unknown apikey action %q: expected create, list or revoke", cmd.Args[0])
+}
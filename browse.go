@@ -0,0 +1,241 @@
+package main
+
+import (
+	"blog/internal/database"
+	"database/sql"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type browseOptions struct {
+	limit    int
+	offset   int
+	since    sql.NullTime
+	until    sql.NullTime
+	feed     string
+	author   string
+	category string // a <category> of the post itself
+	folder   string // the user's category of feeds, see `gator category`
+	tag      string // one of the user's own tags, see `gator tag`
+	sortBy   string
+	cursor   string
+	unread   bool
+	starred  bool
+	hidden   bool // include posts hidden by rules
+	search   string
+	full     bool // show each post's full content
+}
+
+func browseFlags(fs *flag.FlagSet) {
+	fs.Int("limit", 2, "number of posts to show")
+	fs.Int("offset", 0, "number of posts to skip")
+	fs.Int("page", 0, "page number, starting at 1")
+	fs.String("since", "", "only posts newer than a duration (24h) or date (2006-01-02)")
+	fs.String("until", "", "only posts older than a duration (24h) or date (2006-01-02)")
+	fs.String("feed", "", "only posts from the feed with this name or url")
+	fs.String("author", "", "only posts whose author contains this text")
+	fs.String("category", "", "only posts the feed tagged with this category")
+	fs.String("folder", "", "only posts from feeds in this 'gator category' or its subcategories")
+	fs.String("tag", "", "only posts you tagged with this tag")
+	fs.String("sort", "published", "sort order: published|fetched")
+	fs.String("cursor", "", "continue after the cursor printed by a previous browse")
+	fs.Bool("unread", false, "only posts not marked as read")
+	fs.Bool("starred", false, "only starred posts")
+	fs.Bool("hidden", false, "include posts hidden by rules")
+	fs.String("search", "", "only posts whose title, description or full content contains this text")
+	fs.Bool("full", false, "show the full content of each post, fetched for feeds set with 'gator fullcontent'")
+}
+
+// parseBrowseArgs accepts the legacy positional limit (`browse 5`) as well as
+// the flag form (`browse --limit 5 --feed <url> --since 24h`).
+func parseBrowseArgs(cmd Command) (browseOptions, error) {
+	opts := browseOptions{
+		limit:    cmd.flagInt("limit"),
+		offset:   cmd.flagInt("offset"),
+		feed:     cmd.flagString("feed"),
+		author:   cmd.flagString("author"),
+		category: cmd.flagString("category"),
+		folder:   cmd.flagString("folder"),
+		tag:      normalizeTag(cmd.flagString("tag")),
+		sortBy:   cmd.flagString("sort"),
+		cursor:   cmd.flagString("cursor"),
+		unread:   cmd.flagBool("unread"),
+		starred:  cmd.flagBool("starred"),
+		hidden:   cmd.flagBool("hidden"),
+		search:   cmd.flagString("search"),
+		full:     cmd.flagBool("full"),
+	}
+	page := cmd.flagInt("page")
+
+	if len(cmd.Args) == 1 {
+		limit, err := strconv.Atoi(cmd.Args[0])
+		if err != nil {
+			return opts, fmt.Errorf("invalid limit: %w", err)
+		}
+		opts.limit = limit
+	}
+
+	if opts.limit <= 0 {
+		return opts, fmt.Errorf("invalid limit: %d", opts.limit)
+	}
+	if opts.offset < 0 {
+		return opts, fmt.Errorf("invalid offset: %d", opts.offset)
+	}
+	if page < 0 {
+		return opts, fmt.Errorf("invalid page: %d", page)
+	}
+	if page > 0 {
+		if opts.offset != 0 {
+			return opts, fmt.Errorf("--page and --offset cannot be used together")
+		}
+		opts.offset = (page - 1) * opts.limit
+	}
+	if opts.cursor != "" && opts.offset != 0 {
+		return opts, fmt.Errorf("--cursor cannot be used with --page or --offset")
+	}
+	if opts.sortBy != "published" && opts.sortBy != "fetched" {
+		return opts, fmt.Errorf("invalid sort %q: expected published or fetched", opts.sortBy)
+	}
+
+	var err error
+	if opts.since, err = parseTimeArg(cmd.flagString("since")); err != nil {
+		return opts, fmt.Errorf("invalid --since: %w", err)
+	}
+	if opts.until, err = parseTimeArg(cmd.flagString("until")); err != nil {
+		return opts, fmt.Errorf("invalid --until: %w", err)
+	}
+
+	return opts, nil
+}
+
+// nextPageCommand is the browse command line for the page after cursor. It
+// repeats every filter in opts, so following it doesn't widen the query;
+// relative --since and --until are given as the times they resolved to.
+func (opts browseOptions) nextPageCommand(cursor string) string {
+	args := []string{"browse", "--sort", opts.sortBy, "--limit", strconv.Itoa(opts.limit)}
+	for _, f := range []struct{ name, value string }{
+		{"feed", opts.feed},
+		{"author", opts.author},
+		{"category", opts.category},
+		{"folder", opts.folder},
+		{"tag", opts.tag},
+		{"search", opts.search},
+	} {
+		if f.value != "" {
+			args = append(args, "--"+f.name, shellQuote(f.value))
+		}
+	}
+	if opts.since.Valid {
+		args = append(args, "--since", opts.since.Time.Format(time.RFC3339Nano))
+	}
+	if opts.until.Valid {
+		args = append(args, "--until", opts.until.Time.Format(time.RFC3339Nano))
+	}
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{{"unread", opts.unread}, {"starred", opts.starred}, {"hidden", opts.hidden}, {"full", opts.full}} {
+		if f.set {
+			args = append(args, "--"+f.name)
+		}
+	}
+	return strings.Join(append(args, "--cursor", cursor), " ")
+}
+
+// shellQuote quotes s for a POSIX shell unless it is safe as is.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:@%+=,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// parseTimeArg turns "24h" into 24 hours ago and "2025-10-01" into that date.
+// An empty string means no bound.
+func parseTimeArg(arg string) (sql.NullTime, error) {
+	if arg == "" {
+		return sql.NullTime{}, nil
+	}
+	if d, err := time.ParseDuration(arg); err == nil {
+		return sql.NullTime{Time: time.Now().Add(-d), Valid: true}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, arg); err == nil {
+			return sql.NullTime{Time: t, Valid: true}, nil
+		}
+	}
+	return sql.NullTime{}, fmt.Errorf("%q is neither a duration nor a date", arg)
+}
+
+func (opts browseOptions) params(userID uuid.UUID) (database.GetPostsForUserFilteredParams, error) {
+	params := database.GetPostsForUserFilteredParams{
+		UserID:        userID,
+		Feed:          sql.NullString{String: opts.feed, Valid: opts.feed != ""},
+		Author:        sql.NullString{String: opts.author, Valid: opts.author != ""},
+		Category:      sql.NullString{String: opts.category, Valid: opts.category != ""},
+		Since:         opts.since,
+		Until:         opts.until,
+		SortBy:        opts.sortBy,
+		UnreadOnly:    opts.unread,
+		StarredOnly:   opts.starred,
+		Folder:        sql.NullString{String: opts.folder, Valid: opts.folder != ""},
+		Tag:           sql.NullString{String: opts.tag, Valid: opts.tag != ""},
+		IncludeHidden: opts.hidden,
+		Search:        sql.NullString{String: opts.search, Valid: opts.search != ""},
+		Lim:           int32(opts.limit),
+		Off:           int32(opts.offset),
+	}
+
+	if opts.cursor != "" {
+		sortBy, t, id, err := decodeCursor(opts.cursor)
+		if err != nil {
+			return params, fmt.Errorf("invalid cursor: %w", err)
+		}
+		if sortBy != opts.sortBy {
+			return params, fmt.Errorf("cursor was created with --sort %s", sortBy)
+		}
+		params.CursorTime = sql.NullTime{Time: t, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	return params, nil
+}
+
+// postSortKey mirrors the ORDER BY of GetPostsForUserFiltered.
+func postSortKey(sortBy string, post database.GetPostsForUserFilteredRow) time.Time {
+	if sortBy == "fetched" || !post.PublishedAt.Valid {
+		return post.CreatedAt
+	}
+	return post.PublishedAt.Time
+}
+
+func encodeCursor(sortBy string, t time.Time, id uuid.UUID) string {
+	raw := sortBy + "|" + t.Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (string, time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", time.Time{}, uuid.Nil, err
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return "", time.Time{}, uuid.Nil, fmt.Errorf("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return "", time.Time{}, uuid.Nil, err
+	}
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return "", time.Time{}, uuid.Nil, err
+	}
+	return parts[0], t, id, nil
+}
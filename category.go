@@ -0,0 +1,217 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Categories are a user's folders of followed feeds. They nest like OPML
+// outlines, and `category export` / `import` read and write them as such (see
+// opml.go). Each follow sits in at most one of them, so the same feed can be
+// filed differently by different users.
+
+func categoryFlags(fs *flag.FlagSet) {
+	fs.String("parent", "", "create the category inside this one")
+	fs.String("out", "-", "export: file to write, or - for stdout")
+}
+
+func handlerCategory(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+
+	switch action := cmd.Args[0]; action {
+	case "create":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator category create [--parent <name>] <name>")
+		}
+		folder, err := createCategory(ctx, s.db, user, cmd.Args[1], cmd.flagString("parent"))
+		if err != nil {
+			return err
+		}
+		s.out.notef("Created category %s\n", folder.Name)
+		return nil
+
+	case "add":
+		if len(cmd.Args) != 3 {
+			return fmt.Errorf("usage: gator category add <feed_url> <name>")
+		}
+		if err := addToCategory(ctx, s.db, user, cmd.Args[1], cmd.Args[2]); err != nil {
+			return err
+		}
+		s.out.notef("Added %s to %s\n", cmd.Args[1], cmd.Args[2])
+		return nil
+
+	case "list":
+		if len(cmd.Args) != 1 {
+			return fmt.Errorf("usage: gator category list")
+		}
+		folders, err := s.db.ListFolders(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list categories: %w", err)
+		}
+		if len(folders) == 0 {
+			s.out.notef("No categories yet: create one with 'gator category create <name>'\n")
+		}
+		return s.out.render(categoriesTable(folders))
+
+	case "delete":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator category delete <name>")
+		}
+		n, err := s.db.DeleteFolder(ctx, database.DeleteFolderParams{UserID: user.ID, Name: cmd.Args[1]})
+		if err != nil {
+			return fmt.Errorf("failed to delete category: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no category %q", cmd.Args[1])
+		}
+		s.out.notef("Deleted category %s; its feeds are uncategorized and its subcategories moved to the top\n", cmd.Args[1])
+		return nil
+
+	case "export":
+		if len(cmd.Args) != 1 {
+			return fmt.Errorf("usage: gator category export [--out <file>]")
+		}
+		out := cmd.flagString("out")
+		if out == "-" {
+			return exportOPML(ctx, s.db, user, s.out.w)
+		}
+		if err := writeFileAtomic(out, func(w io.Writer) error { return exportOPML(ctx, s.db, user, w) }); err != nil {
+			return err
+		}
+		s.out.notef("Exported your follows and categories to %s\n", out)
+		return nil
+
+	case "import":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator category import <file.opml>")
+		}
+		f, err := os.Open(cmd.Args[1])
+		if err != nil {
+			return fmt.Errorf("failed to open opml: %w", err)
+		}
+		defer f.Close()
+		result, err := importOPML(ctx, s.db, user, f)
+		if err != nil {
+			return err
+		}
+		for _, skipped := range result.skipped {
+			s.out.notef("Skipped %s\n", skipped)
+		}
+		s.out.notef("Followed %d new feeds and created %d categories from %s\n", result.feeds, result.categories, cmd.Args[1])
+		return nil
+
+	default:
+		return fmt.Errorf("unknown category action %q: expected create, add, list, delete, export or import", action)
+	}
+}
+
+func createCategory(ctx context.Context, db database.Querier, user database.User, name, parent string) (database.Folder, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Contains(name, "/") {
+		return database.Folder{}, fmt.Errorf("invalid category name %q: it can't be empty or contain /", name)
+	}
+
+	var parentID uuid.NullUUID
+	if parent != "" {
+		p, err := db.GetFolderByName(ctx, database.GetFolderByNameParams{UserID: user.ID, Name: parent})
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Folder{}, fmt.Errorf("no category %q", parent)
+		}
+		if err != nil {
+			return database.Folder{}, fmt.Errorf("failed to get category: %w", err)
+		}
+		parentID = uuid.NullUUID{UUID: p.ID, Valid: true}
+	}
+
+	folder, err := db.CreateFolder(ctx, database.CreateFolderParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		Name:      name,
+		ParentID:  parentID,
+	})
+	if isUniqueViolation(err) {
+		return folder, fmt.Errorf("category %q already exists", name)
+	}
+	if err != nil {
+		return folder, fmt.Errorf("failed to create category: %w", err)
+	}
+	return folder, nil
+}
+
+// addToCategory files a followed feed under a category, moving it out of the
+// one it was in.
+func addToCategory(ctx context.Context, db database.Querier, user database.User, feedURL, name string) error {
+	folder, err := db.GetFolderByName(ctx, database.GetFolderByNameParams{UserID: user.ID, Name: name})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no category %q: create it with 'gator category create %s'", name, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get category: %w", err)
+	}
+
+	follows, err := db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get follows: %w", err)
+	}
+	for _, f := range follows {
+		if f.FeedUrl != feedURL {
+			continue
+		}
+		err := db.SetFeedFolder(ctx, database.SetFeedFolderParams{UserID: user.ID, FeedID: f.FeedID, FolderID: folder.ID})
+		if err != nil {
+			return fmt.Errorf("failed to add feed to category: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("you don't follow %s: run 'gator follow %s' first", feedURL, feedURL)
+}
+
+// categoryPaths names each folder by its place in the tree, e.g. "tech/go".
+func categoryPaths(folders []database.ListFoldersRow) map[uuid.UUID]string {
+	byID := make(map[uuid.UUID]database.ListFoldersRow, len(folders))
+	for _, f := range folders {
+		byID[f.ID] = f
+	}
+	paths := make(map[uuid.UUID]string, len(folders))
+	var path func(f database.ListFoldersRow) string
+	path = func(f database.ListFoldersRow) string {
+		if p, ok := paths[f.ID]; ok {
+			return p
+		}
+		p := f.Name
+		if parent, ok := byID[f.ParentID.UUID]; f.ParentID.Valid && ok {
+			p = path(parent) + "/" + f.Name
+		}
+		paths[f.ID] = p
+		return p
+	}
+	for _, f := range folders {
+		path(f)
+	}
+	return paths
+}
+
+// categoriesTable lists the folders in tree order.
+func categoriesTable(folders []database.ListFoldersRow) ([]string, [][]any) {
+	paths := categoryPaths(folders)
+	sorted := append([]database.ListFoldersRow(nil), folders...)
+	sort.Slice(sorted, func(i, j int) bool { return paths[sorted[i].ID] < paths[sorted[j].ID] })
+
+	rows := make([][]any, 0, len(sorted))
+	for _, f := range sorted {
+		rows = append(rows, []any{f.Name, paths[f.ID], f.FeedCount})
+	}
+	return []string{"name", "path", "feeds"}, rows
+}
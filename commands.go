@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Command struct {
+	Name  string
+	Args  []string
+	Flags *flag.FlagSet
+}
+
+// flagString, flagInt and flagBool read flags declared by the command's
+// commandSpec.Flags. They panic on undeclared names, which is a programming
+// error rather than a user error.
+func (c Command) flagString(name string) string {
+	return c.Flags.Lookup(name).Value.String()
+}
+
+func (c Command) flagInt(name string) int {
+	n, _ := strconv.Atoi(c.Flags.Lookup(name).Value.String())
+	return n
+}
+
+func (c Command) flagBool(name string) bool {
+	b, _ := strconv.ParseBool(c.Flags.Lookup(name).Value.String())
+	return b
+}
+
+// flagPassed reports whether the flag was given on the command line, as
+// opposed to holding its default.
+func (c Command) flagPassed(name string) bool {
+	passed := false
+	c.Flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+type commandSpec struct {
+	Name        string
+	Usage       string // arguments only, e.g. "<name> <url>"
+	Description string
+	MinArgs     int
+	MaxArgs     int // -1 means no limit
+	Flags       func(fs *flag.FlagSet)
+	// Offline commands run without opening the database.
+	Offline bool
+	// AnySchema commands skip the schema version check at startup.
+	AnySchema bool
+	// Hidden commands are left out of help, suggestions and completion.
+	Hidden bool
+	// RawArgs commands get their arguments without any flag parsing.
+	RawArgs bool
+	// Complete lists candidates for the first positional argument.
+	Complete func(*State) ([]string, error)
+	Handler  func(*State, Command) error
+}
+
+type Commands struct {
+	Cmap map[string]commandSpec
+}
+
+func (c *Commands) register(spec commandSpec) {
+	if c.Cmap == nil {
+		c.Cmap = make(map[string]commandSpec)
+	}
+	if _, ok := c.Cmap[spec.Name]; ok {
+		panic(fmt.Sprintf("command %q registered twice", spec.Name))
+	}
+	if spec.Handler == nil {
+		panic(fmt.Sprintf("command %q has no handler", spec.Name))
+	}
+	c.Cmap[spec.Name] = spec
+}
+
+func (c *Commands) lookup(name string) (commandSpec, error) {
+	spec, ok := c.Cmap[name]
+	if !ok {
+		if suggestion := c.suggest(name); suggestion != "" {
+			return spec, fmt.Errorf("unknown command: %s (did you mean %q?)", name, suggestion)
+		}
+		return spec, fmt.Errorf("unknown command: %s (see 'gator help')", name)
+	}
+	return spec, nil
+}
+
+func (c *Commands) run(s *State, cmd Command) error {
+	spec, err := c.lookup(cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	if spec.RawArgs {
+		return spec.Handler(s, cmd)
+	}
+
+	fs := spec.flagSet()
+	args, err := parseInterspersed(fs, cmd.Args)
+	if errors.Is(err, flag.ErrHelp) {
+		spec.printHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w\nusage: %s", err, spec.usageLine())
+	}
+
+	if len(args) < spec.MinArgs || (spec.MaxArgs >= 0 && len(args) > spec.MaxArgs) {
+		return fmt.Errorf("usage: %s", spec.usageLine())
+	}
+
+	cmd.Args = args
+	cmd.Flags = fs
+	return spec.Handler(s, cmd)
+}
+
+// needsDB reports whether the named command has to open the database. Unknown
+// commands don't; run reports them without a connection.
+func (c *Commands) needsDB(name string) bool {
+	spec, ok := c.Cmap[name]
+	return ok && !spec.Offline
+}
+
+func (spec commandSpec) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(spec.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if spec.Flags != nil {
+		spec.Flags(fs)
+	}
+	return fs
+}
+
+func (spec commandSpec) usageLine() string {
+	line := "gator " + spec.Name
+	if spec.Flags != nil {
+		line += " [flags]"
+	}
+	if spec.Usage != "" {
+		line += " " + spec.Usage
+	}
+	return line
+}
+
+func (spec commandSpec) printHelp(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s\n\n%s\n", spec.usageLine(), spec.Description)
+	if spec.Flags == nil {
+		return
+	}
+	fmt.Fprintln(w, "\nflags:")
+	fs := spec.flagSet()
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// parseInterspersed lets flags appear after positional arguments
+// (`follow <url> --help`), which the flag package alone does not allow.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// suggest returns the registered command closest to name, or "" if none is
+// close enough to be a plausible typo.
+func (c *Commands) suggest(name string) string {
+	best, bestDist := "", 3
+	for _, candidate := range c.visibleNames() {
+		d := levenshtein(name, candidate)
+		if strings.HasPrefix(candidate, name) && len(name) >= 2 {
+			d = 1
+		}
+		if d < bestDist || (d == bestDist && candidate < best) {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func (c *Commands) handlerHelp(s *State, cmd Command) error {
+	if len(cmd.Args) == 1 {
+		spec, err := c.lookup(cmd.Args[0])
+		if err != nil {
+			return err
+		}
+		spec.printHelp(os.Stdout)
+		return nil
+	}
+
+	names := c.visibleNames()
+
+	fmt.Println("usage: gator [--format table|json|ndjson|csv|tsv] [--config <file>] [--profile <name>] <command> [args]")
+	fmt.Println("\ncommands:")
+	for _, name := range names {
+		fmt.Printf("  %-12s %s\n", name, c.Cmap[name].Description)
+	}
+	fmt.Println("\nRun 'gator help <command>' for details on a command.")
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The generated scripts hand the words typed so far to the hidden __complete
+// command, so the candidate logic lives in Go and stays in sync with the
+// command registry.
+
+const bashCompletion = `# bash completion for gator
+# source <(gator completion bash)
+_gator() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null 2>&1; then
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+    local IFS=$'\n'
+    COMPREPLY=($(gator __complete "${words[@]:1:cword}" 2>/dev/null))
+    if declare -F __ltrim_colon_completions >/dev/null 2>&1; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+complete -o default -F _gator gator
+`
+
+const zshCompletion = `#compdef gator
+# source <(gator completion zsh), or save as _gator somewhere in $fpath
+_gator() {
+    local -a completions
+    completions=("${(@f)$(gator __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    completions=(${completions:#})
+    compadd -Q -a completions
+}
+if [ "$funcstack[1]" = "_gator" ]; then
+    _gator "$@"
+else
+    compdef _gator gator
+fi
+`
+
+const fishCompletion = `# fish completion for gator
+# gator completion fish > ~/.config/fish/completions/gator.fish
+function __gator_complete
+    set -l args (commandline -opc)
+    set -e args[1]
+    gator __complete $args (commandline -ct) 2>/dev/null
+end
+complete -c gator -f -a '(__gator_complete)'
+`
+
+func handlerCompletion(s *State, cmd Command) error {
+	switch cmd.Args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		return fmt.Errorf("unsupported shell %q: expected bash, zsh or fish", cmd.Args[0])
+	}
+	return nil
+}
+
+// handlerComplete prints one candidate per line for the last word in
+// cmd.Args. Errors are swallowed: a broken database must not make the
+// shell print garbage while the user is typing.
+func (c *Commands) handlerComplete(s *State, cmd Command) error {
+	for _, candidate := range c.complete(s, cmd.Args) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+func (c *Commands) complete(s *State, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	words = words[:len(words)-1]
+
+	// Skip global options, e.g. `gator --format json <TAB>`.
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+		if words[0] == "--format" || words[0] == "-format" {
+			if len(words) == 1 {
+				return filterPrefix([]string{formatTable, formatJSON, formatNDJSON, formatCSV, formatTSV}, cur)
+			}
+			words = words[1:]
+		}
+		words = words[1:]
+	}
+
+	if len(words) == 0 {
+		if strings.HasPrefix(cur, "-") {
+			return filterPrefix([]string{"--format", "--help"}, cur)
+		}
+		return filterPrefix(c.visibleNames(), cur)
+	}
+
+	spec, ok := c.Cmap[words[0]]
+	if !ok {
+		return nil
+	}
+
+	if strings.HasPrefix(cur, "-") {
+		flags := []string{"--help"}
+		spec.flagSet().VisitAll(func(f *flag.Flag) {
+			flags = append(flags, "--"+f.Name)
+		})
+		return filterPrefix(flags, cur)
+	}
+
+	if spec.Complete == nil || countPositional(spec, words[1:]) != 0 {
+		return nil
+	}
+
+	if s.db == nil {
+		db, err := openDatabase(s.sStruct.DBURL)
+		if err != nil {
+			return nil
+		}
+		defer db.Close()
+		s.db = db.queries
+	}
+
+	candidates, err := spec.Complete(s)
+	if err != nil {
+		return nil
+	}
+	return filterPrefix(candidates, cur)
+}
+
+// countPositional counts the arguments already typed for the command,
+// skipping flags and the values of non-boolean flags.
+func countPositional(spec commandSpec, args []string) int {
+	fs := spec.flagSet()
+	n := 0
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			n++
+			continue
+		}
+		name := strings.TrimLeft(args[i], "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if f := fs.Lookup(name); f != nil {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				i++
+			}
+		}
+	}
+	return n
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (c *Commands) visibleNames() []string {
+	var names []string
+	for name, spec := range c.Cmap {
+		if !spec.Hidden {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func completeUsernames(s *State) ([]string, error) {
+	return s.db.GetAllUsers(context.Background())
+}
+
+func completeFeedURLs(s *State) ([]string, error) {
+	feeds, err := s.db.GetAllFeeds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(feeds))
+	for _, f := range feeds {
+		urls = append(urls, f.FeedUrl)
+	}
+	return urls, nil
+}
+
+func completeFollowedFeedURLs(s *State) ([]string, error) {
+	ctx := context.Background()
+	user, err := s.db.GetUserByName(ctx, s.sStruct.CurrentUser)
+	if err != nil {
+		return nil, err
+	}
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(follows))
+	for _, f := range follows {
+		urls = append(urls, f.FeedUrl)
+	}
+	return urls, nil
+}
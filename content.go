@@ -0,0 +1,430 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Feeds that only carry a teaser can be set to fetch the full article with
+// `gator fullcontent <feed> on`. scrapeFeed then downloads each new post's
+// page, picks out its main content the way Readability does and stores it,
+// sanitized, in posts.content, where `browse --full`, `browse --search`,
+// watches and the exports find it.
+
+// maxArticleSize caps how much of an article page fetchContent reads.
+const maxArticleSize = 5 << 20
+
+// publicClient returns an HTTP client whose connections, redirects included,
+// are checked by publicAddressOnly, so URLs from users can't have agg reach
+// services on its own host or network and hand their responses back. It
+// ignores proxy settings, as the proxy's address is all the check would see.
+func publicClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   publicAddressOnly,
+	}).DialContext
+	return &http.Client{Transport: transport}
+}
+
+// cgnatPrefix is the shared address space carriers use between their
+// customers, which is no more public than the private ranges.
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddressOnly is a net.Dialer Control function refusing loopback,
+// private, link-local, multicast and unspecified addresses. It runs on the
+// resolved address right before connecting, so DNS can't point a checked
+// name elsewhere.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("refusing to connect to non-public address %s", ip.Unmap())
+	}
+	return nil
+}
+
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnatPrefix.Contains(ip))
+}
+
+// checkPublicHost resolves host and fails unless all its addresses are
+// public, for refusing a URL when it is saved rather than at every send.
+// publicAddressOnly still checks each connection, as DNS can change.
+func checkPublicHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range addrs {
+		if !isPublicAddr(ip) {
+			return fmt.Errorf("%s resolves to non-public address %s", host, ip.Unmap())
+		}
+	}
+	return nil
+}
+
+func handlerFullContent(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get follows: %w", err)
+	}
+	i := slices.IndexFunc(follows, func(f database.GetFeedFollowsForUserRow) bool {
+		return f.FeedUrl == cmd.Args[0] || f.FeedName == cmd.Args[0]
+	})
+	if i < 0 {
+		return fmt.Errorf("you don't follow %s", cmd.Args[0])
+	}
+	feed, err := s.db.GetFeedByURL(ctx, follows[i].FeedUrl)
+	if err != nil {
+		return fmt.Errorf("failed to get feed: %w", err)
+	}
+
+	if len(cmd.Args) == 1 {
+		return s.out.render([]string{"feed_name", "feed_url", "full_content"}, [][]any{{feed.Name, feed.Url, feed.FetchFullContent}})
+	}
+	// The setting applies to everyone following the feed, so only the user
+	// who added it decides
+	if !feed.UserID.Valid || feed.UserID.UUID != user.ID {
+		return fmt.Errorf("only the user who added %s can change whether its full articles are fetched", feed.Name)
+	}
+	var on bool
+	switch cmd.Args[1] {
+	case "on":
+		on = true
+	case "off":
+	default:
+		return fmt.Errorf("usage: gator fullcontent <feed> [on|off]")
+	}
+	if err := s.db.SetFeedFullContent(ctx, database.SetFeedFullContentParams{ID: feed.ID, FetchFullContent: on}); err != nil {
+		return fmt.Errorf("failed to update feed: %w", err)
+	}
+	if on {
+		s.out.notef("agg now fetches the full article of each new post of %s\n", feed.Name)
+	} else {
+		s.out.notef("agg no longer fetches full articles for %s\n", feed.Name)
+	}
+	return nil
+}
+
+// fetchContent downloads the article at pageURL and returns its main
+// content as sanitized HTML, or "" if no content was found.
+func fetchContent(ctx context.Context, f fetcher, pageURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "gator")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get resp: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", fmt.Errorf("not an HTML page: %s", resp.Header.Get("Content-Type"))
+	}
+
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxArticleSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse page: %w", err)
+	}
+	main := extractContent(doc)
+	if main == nil {
+		return "", nil
+	}
+	// resp.Request.URL is the page after redirects, which relative links
+	// are relative to
+	return sanitizeHTML(main, resp.Request.URL), nil
+}
+
+// setPostContent fetches and stores the full content of a new post, and
+// updates post to match.
+func setPostContent(ctx context.Context, db database.Querier, f fetcher, post *database.Post) error {
+	content, err := fetchContent(ctx, f, post.Url)
+	if err != nil {
+		return err
+	}
+	post.Content = sql.NullString{String: content, Valid: content != ""}
+	return db.SetPostContent(ctx, database.SetPostContentParams{ID: post.ID, Content: post.Content})
+}
+
+var (
+	// unlikelyContent and likelyContent match the class and id of elements
+	// that rarely or often hold an article's text.
+	unlikelyContent = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|legends|menu|modal|nav|newsletter|pager|pagination|popup|promo|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|teaser|tweet|twitter|widget|\bad-|\bads\b`)
+	likelyContent   = regexp.MustCompile(`(?i)article|body|column|content|entry|hentry|h-entry|main|page|post|prose|story|text`)
+)
+
+// extractContent returns the element holding doc's main text, following
+// Readability's approach: every paragraph scores its parent and
+// grandparent by length and commas, the scores are weighted by class names
+// and discounted by link density, and the best-scoring element wins.
+// Siblings that score close to it are kept with it in a new div.
+func extractContent(doc *html.Node) *html.Node {
+	var body *html.Node
+	var prune func(*html.Node)
+	prune = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.ElementNode {
+				if c.DataAtom == atom.Body {
+					body = c
+				}
+				if unlikelyNode(c) {
+					n.RemoveChild(c)
+				} else {
+					prune(c)
+				}
+			} else if c.Type == html.CommentNode {
+				n.RemoveChild(c)
+			}
+			c = next
+		}
+	}
+	prune(doc)
+	if body == nil {
+		return nil
+	}
+
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	score := func(n *html.Node, points float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += points
+	}
+	walk(body, func(n *html.Node) {
+		if n.DataAtom != atom.P && n.DataAtom != atom.Pre && n.DataAtom != atom.Td {
+			return
+		}
+		text := innerText(n)
+		length := utf8.RuneCountInString(text)
+		if length < 25 {
+			return
+		}
+		points := 1 + float64(strings.Count(text, ",")) + min(float64(length/100), 3)
+		score(n.Parent, points)
+		if n.Parent != nil {
+			score(n.Parent.Parent, points/2)
+		}
+	})
+
+	var top *html.Node
+	for _, n := range candidates {
+		scores[n] *= 1 - linkDensity(n)
+		if top == nil || scores[n] > scores[top] {
+			top = n
+		}
+	}
+	if top == nil {
+		for _, a := range []atom.Atom{atom.Article, atom.Main} {
+			if n := find(body, a); n != nil {
+				return n
+			}
+		}
+		if innerText(body) == "" {
+			return nil
+		}
+		return body
+	}
+	if top.Parent == nil {
+		return top
+	}
+
+	// Keep siblings that look like part of the same article, such as
+	// paragraphs split across several divs.
+	threshold := max(10, scores[top]*0.2)
+	article := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	for c := top.Parent.FirstChild; c != nil; {
+		next := c.NextSibling
+		keep := c == top
+		if !keep && c.Type == html.ElementNode {
+			if s, ok := scores[c]; ok && s >= threshold {
+				keep = true
+			} else if c.DataAtom == atom.P {
+				text := innerText(c)
+				length := utf8.RuneCountInString(text)
+				density := linkDensity(c)
+				keep = (length > 80 && density < 0.25) ||
+					(length > 0 && density == 0 && strings.ContainsAny(text, ".!?"))
+			}
+		}
+		if keep {
+			top.Parent.RemoveChild(c)
+			article.AppendChild(c)
+		}
+		c = next
+	}
+	return article
+}
+
+// unlikelyNode reports whether n is page furniture rather than content.
+func unlikelyNode(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Iframe, atom.Form,
+		atom.Nav, atom.Header, atom.Footer, atom.Aside, atom.Button, atom.Select, atom.Svg:
+		return true
+	case atom.Html, atom.Body, atom.Article, atom.Main:
+		return false
+	}
+	if attr(n, "hidden") != "" || attr(n, "aria-hidden") == "true" || attr(n, "role") == "navigation" {
+		return true
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyContent.MatchString(names) && !likelyContent.MatchString(names)
+}
+
+func initialScore(n *html.Node) float64 {
+	var s float64
+	switch n.DataAtom {
+	case atom.Article:
+		s = 10
+	case atom.Div, atom.Main, atom.Section:
+		s = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		s = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li:
+		s = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		s = -5
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	if likelyContent.MatchString(names) {
+		s += 25
+	}
+	if unlikelyContent.MatchString(names) {
+		s -= 25
+	}
+	return s
+}
+
+// linkDensity is the share of n's text that is inside links.
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(innerText(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	walk(n, func(c *html.Node) {
+		if c.DataAtom == atom.A {
+			linked += utf8.RuneCountInString(innerText(c))
+		}
+	})
+	return min(float64(linked)/float64(total), 1)
+}
+
+// innerText is n's text with whitespace collapsed.
+func innerText(n *html.Node) string {
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// walk calls fn for every element below n, in document order.
+func walk(n *html.Node, fn func(*html.Node)) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			fn(c)
+		}
+		walk(c, fn)
+	}
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(n, func(c *html.Node) {
+		if found == nil && c.DataAtom == a {
+			found = c
+		}
+	})
+	return found
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// postBody is the HTML to show for a post: its full content when it was
+// fetched, else the description from the feed.
+func postBody(post database.GetPostsForUserFilteredRow) string {
+	if post.Content.Valid {
+		return post.Content.String
+	}
+	return post.Description.String
+}
+
+// withContent adds the text of each post's body as a content column.
+func withContent(columns []string, rows [][]any, posts []database.GetPostsForUserFilteredRow) ([]string, [][]any) {
+	for i, post := range posts {
+		rows[i] = append(rows[i], htmlText(postBody(post)))
+	}
+	return append(columns, "content"), rows
+}
+
+// printFullPosts is browse --full in the table format: each post with its
+// whole text wrapped for the terminal, as tables truncate long cells.
+func printFullPosts(w io.Writer, posts []database.GetPostsForUserFilteredRow) error {
+	var b strings.Builder
+	for _, post := range posts {
+		b.WriteString(strings.Join(postLines(post, 80), "\n") + "\n")
+		b.WriteString(strings.Repeat("─", 80) + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
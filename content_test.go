@@ -0,0 +1,173 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const testArticle = `<!doctype html>
+<html><head><title>Newer post</title><style>body { color: red }</style></head>
+<body>
+<header class="site-header"><a href="/">Test Blog</a></header>
+<nav><a href="/about">About</a> <a href="/archive">Archive</a></nav>
+<div class="layout">
+  <div id="sidebar" class="sidebar"><p>Subscribe to the newsletter, it is free, weekly and full of links.</p></div>
+  <article class="post">
+    <h1>Newer post</h1>
+    <p>The gopher turned up again, this time with a patch, a test and a long explanation of why the old code was wrong.</p>
+    <p onclick="steal()">Read the <a href="/docs/patch" onclick="x()">patch notes</a>, then the <a href="javascript:alert(1)">bad link</a>, and keep going.</p>
+    <script>track()</script>
+    <img src="/img/gopher.png" alt="a gopher"><img src="data:image/png;base64,AAAA" alt="inline">
+    <div class="share-buttons"><a href="https://social.example/share">Share</a></div>
+  </article>
+  <div class="comments"><p>First, great post, thanks, really, I mean it, a lot.</p></div>
+</div>
+<footer>© Test Blog</footer>
+</body></html>`
+
+func TestExtractContent(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testArticle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://blog.example/2026/newer")
+	got := sanitizeHTML(extractContent(doc), base)
+
+	for _, want := range []string{
+		"<h1>Newer post</h1>",
+		"<p>The gopher turned up again",
+		`<p>Read the <a href="https://blog.example/docs/patch">patch notes</a>, then the <a>bad link</a>`,
+		`<img src="https://blog.example/img/gopher.png" alt="a gopher">`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("content lacks %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"Archive", "newsletter", "track()", "Share", "great post", "©", "onclick", "javascript:", "data:", "color: red"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("content has %q:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestPublicAddressOnly(t *testing.T) {
+	for address, public := range map[string]bool{
+		"93.184.216.34:443":     true,
+		"[2606:4700::1111]:443": true,
+		"127.0.0.1:80":          false,
+		"[::1]:80":              false,
+		"[::ffff:127.0.0.1]:80": false,
+		"10.1.2.3:80":           false,
+		"192.168.0.1:80":        false,
+		"169.254.169.254:80":    false,
+		"[fe80::1]:80":          false,
+		"[fd00::1]:80":          false,
+		"100.64.0.1:80":         false,
+		"0.0.0.0:80":            false,
+		"224.0.0.1:80":          false,
+	} {
+		if err := publicAddressOnly("tcp", address, nil); (err == nil) != public {
+			t.Errorf("publicAddressOnly(%s) = %v", address, err)
+		}
+	}
+
+	// webhooks on other hosts are refused when added, not just when sent
+	for _, rawURL := range []string{"http://127.0.0.1:8080/hook", "http://[::1]/hook", "http://169.254.169.254/latest", "http://localhost/hook"} {
+		if _, err := newWebhook(context.Background(), nil, newFetcher(), database.User{}, rawURL, "", "", ""); err == nil {
+			t.Errorf("newWebhook(%s) succeeded", rawURL)
+		}
+	}
+}
+
+func TestFullContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss":
+			fmt.Fprintf(w, testRSS, "http://"+r.Host)
+		case "/newer":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, testArticle)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+	h.mustRun("", "fullcontent", "Test Blog")
+	if !strings.Contains(h.out.String(), `"full_content": false`) {
+		t.Errorf("fullcontent = %q", h.out.String())
+	}
+	h.mustRun("", "fullcontent", "Test Blog", "on")
+	if err := h.run("", "fullcontent", "Test Blog", "maybe"); err == nil {
+		t.Error("fullcontent maybe succeeded")
+	}
+	if err := h.run("", "fullcontent", "Other Blog", "on"); err == nil {
+		t.Error("fullcontent on a feed not followed succeeded")
+	}
+	h.mustRun("correct horse\ncorrect horse\n", "register", "bob")
+	h.mustRun("", "follow", srv.URL+"/rss")
+	h.mustRun("", "fullcontent", "Test Blog")
+	if err := h.run("", "fullcontent", "Test Blog", "off"); err == nil {
+		t.Error("fullcontent by a follower who didn't add the feed succeeded")
+	}
+	h.mustRun("hunter2!\n", "login", "alice")
+	h.mustRun("", "watch", "add", "--notify", "local", "gophers", "(?i)gopher")
+
+	// agg only fetches feeds and articles from public addresses; the test
+	// server is on loopback, hence testFetcher
+	if _, err := fetchContent(t.Context(), newFetcher(), srv.URL+"/newer"); err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Errorf("fetching an article from loopback: %v", err)
+	}
+	if _, err := fetchFeed(t.Context(), newFetcher(), srv.URL+"/rss"); err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Errorf("fetching a feed from loopback: %v", err)
+	}
+
+	// /older is a 404, which is reported without losing the post
+	feed, err := h.s.db.GetFeedByURL(t.Context(), srv.URL+"/rss")
+	if err != nil || !feed.FetchFullContent {
+		t.Fatalf("feed = %+v, %v", feed, err)
+	}
+	if created, err := scrapeFeed(h.s.db, h.s.fetcher, feed, nil); created != 2 || err == nil || !strings.Contains(err.Error(), "/older") {
+		t.Errorf("scrapeFeed = %d, %v", created, err)
+	}
+
+	h.mustRun("", "alerts")
+	var alerts []struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &alerts); err != nil || len(alerts) != 1 || alerts[0].Title != "Newer post" {
+		t.Errorf("alerts = %s, %v", h.out.String(), err)
+	}
+
+	h.mustRun("", "browse", "--search", "EXPLANATION", "--full")
+	var posts []struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &posts); err != nil || len(posts) != 1 || posts[0].Title != "Newer post" {
+		t.Fatalf("browse --search: %v, %s", err, h.out.String())
+	}
+	if !strings.Contains(posts[0].Content, "The gopher turned up again") || strings.Contains(posts[0].Content, "<p>") {
+		t.Errorf("content = %q", posts[0].Content)
+	}
+
+	h.s.out.format = formatTable
+	h.mustRun("", "browse", "--full", "--limit", "1")
+	for _, want := range []string{"Newer post\nTest Blog · Tue Oct 6 2026 · Ann\n" + srv.URL + "/newer\n", "\nThe gopher turned up again, this time with a patch, a test and a long\nexplanation"} {
+		if !strings.Contains(h.out.String(), want) {
+			t.Errorf("browse --full lacks %q:\n%s", want, h.out.String())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"blog/internal/database"
+	"blog/internal/memstore"
+	"blog/internal/migrate"
+	"blog/internal/sqlite"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql sql/sqlite/queries/*.sql
+var sqlFS embed.FS
+
+// dbConn is an open database with the queries and migrations for its
+// backend, chosen by the db_url scheme: postgres://... or sqlite://<path>.
+type dbConn struct {
+	*sql.DB
+	queries  *database.Queries
+	migrator *migrate.Migrator
+}
+
+func openDatabase(dbURL string) (*dbConn, error) {
+	if path, ok := sqlitePath(dbURL); ok {
+		return openSQLite(path)
+	}
+
+	// Open database connection
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open database: %w", err)
+	}
+
+	// Test the database connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Failed to ping database: %w", err)
+	}
+
+	migrations, err := migrate.Load(sqlFS, "sql/schema")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &dbConn{
+		DB:       db,
+		queries:  database.New(db),
+		migrator: &migrate.Migrator{DB: db, Dialect: migrate.Postgres, Migrations: migrations},
+	}, nil
+}
+
+func openSQLite(path string) (*dbConn, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open database: %w", err)
+	}
+
+	queries, err := fs.Sub(sqlFS, "sql/sqlite/queries")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	dbtx, err := sqlite.New(db, queries)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	migrations, err := migrate.Load(sqlFS, "sql/sqlite/schema")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &dbConn{
+		DB:       db,
+		queries:  database.New(dbtx),
+		migrator: &migrate.Migrator{DB: db, Dialect: migrate.SQLite, Migrations: migrations},
+	}, nil
+}
+
+// sqlitePath returns the file path of a sqlite:// or sqlite: URL, with a
+// leading ~/ expanded to the home directory.
+func sqlitePath(dbURL string) (string, bool) {
+	path, ok := strings.CutPrefix(dbURL, "sqlite:")
+	if !ok {
+		return "", false
+	}
+	path = strings.TrimPrefix(path, "//")
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	return path, true
+}
+
+// isUniqueViolation reports a duplicate key on any backend.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return sqlite.IsUniqueViolation(err) || errors.Is(err, memstore.ErrDuplicate)
+}
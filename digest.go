@@ -0,0 +1,474 @@
+package main
+
+import (
+	"blog/internal/database"
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Digests mail a user the unread posts fetched since their last digest,
+// grouped by feed, as a multipart message with a plain text and an HTML
+// part. The end of each digest's window is stored as last_sent_at and the
+// next one starts there, so `gator digest --send` can run from cron as often
+// as it likes: it does nothing until the user's schedule is due, never
+// repeats a post and doesn't lose any when a run is missed. A window with
+// more posts than the limit lists the oldest ones and ends where the rest
+// begin, so they open the next digest.
+
+var digestSchedules = map[string]time.Duration{
+	"hourly": time.Hour,
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+const (
+	defaultDigestSchedule = "daily"
+	// digestLimit caps the posts listed in one message; the rest are counted
+	// and left to the next one.
+	digestLimit = 200
+	// digestSummary is how many characters of a description are included.
+	digestSummary = 280
+)
+
+func digestFlags(fs *flag.FlagSet) {
+	fs.String("to", "", "set: address to mail digests to; otherwise overrides it for this digest")
+	fs.String("schedule", "", "set: how often digests are due: hourly|daily|weekly")
+	fs.String("since", "", "only posts fetched in this duration (default: since the last digest, or the schedule's interval for the first)")
+	fs.String("out", "-", "file to write the message to, or - for stdout")
+	fs.Bool("send", false, "mail the digest over SMTP instead of writing it")
+	fs.Bool("force", false, "make a digest even if the schedule isn't due yet")
+	fs.Bool("dry-run", false, "don't record the digest as sent")
+	fs.Int("limit", digestLimit, "number of posts to list")
+}
+
+func handlerDigest(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+	settings, err := digestSettings(ctx, s.db, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(cmd.Args) == 0 {
+		return makeDigest(ctx, s, cmd, user, settings)
+	}
+	switch action := cmd.Args[0]; action {
+	case "set":
+		to, schedule := cmd.flagString("to"), cmd.flagString("schedule")
+		if to == "" && schedule == "" {
+			return fmt.Errorf("usage: gator digest set [--to <email>] [--schedule hourly|daily|weekly]")
+		}
+		if to != "" {
+			addr, err := mail.ParseAddress(to)
+			if err != nil {
+				return fmt.Errorf("invalid --to %q: %w", to, err)
+			}
+			settings.Email = addr.Address
+		}
+		if schedule != "" {
+			if _, ok := digestSchedules[schedule]; !ok {
+				return fmt.Errorf("invalid --schedule %q: expected hourly, daily or weekly", schedule)
+			}
+			settings.Schedule = schedule
+		}
+		err := s.db.SetDigestSettings(ctx, database.SetDigestSettingsParams{
+			UserID:   user.ID,
+			Email:    settings.Email,
+			Schedule: settings.Schedule,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save digest settings: %w", err)
+		}
+		s.out.notef("Digests are %s, to %s\n", settings.Schedule, cmp.Or(settings.Email, "(no address)"))
+		return nil
+
+	case "status":
+		return s.out.render(digestTable(settings))
+
+	default:
+		return fmt.Errorf("unknown digest action %q: expected set or status", action)
+	}
+}
+
+// digestSettings returns the user's settings, or the defaults if they never
+// set any.
+func digestSettings(ctx context.Context, db database.Querier, userID uuid.UUID) (database.DigestSetting, error) {
+	settings, err := db.GetDigestSettings(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.DigestSetting{UserID: userID, Schedule: defaultDigestSchedule}, nil
+	}
+	if err != nil {
+		return settings, fmt.Errorf("failed to get digest settings: %w", err)
+	}
+	return settings, nil
+}
+
+func makeDigest(ctx context.Context, s *State, cmd Command, user database.User, settings database.DigestSetting) error {
+	now := time.Now()
+	interval := digestSchedules[settings.Schedule]
+	if due := settings.LastSentAt.Time.Add(interval); settings.LastSentAt.Valid && now.Before(due) && !cmd.flagBool("force") {
+		s.out.notef("The next digest is due at %s; use --force to make one now\n", due.Format(time.RFC3339))
+		return nil
+	}
+
+	// The window starts where the last digest ended, however long ago that
+	// was; --since overrides it, and sets how far back the first one goes
+	start := now.Add(-interval)
+	if settings.LastSentAt.Valid {
+		start = settings.LastSentAt.Time
+	}
+	if arg := cmd.flagString("since"); arg != "" {
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid --since %q: expected a duration such as 24h", arg)
+		}
+		start = now.Add(-d)
+	}
+	limit := cmd.flagInt("limit")
+	if limit <= 0 {
+		return fmt.Errorf("invalid limit: %d", limit)
+	}
+	to := settings.Email
+	if arg := cmd.flagString("to"); arg != "" {
+		addr, err := mail.ParseAddress(arg)
+		if err != nil {
+			return fmt.Errorf("invalid --to %q: %w", arg, err)
+		}
+		to = addr.Address
+	}
+	d, err := collectDigest(ctx, s.db, user, start, now, limit)
+	if err != nil {
+		return err
+	}
+	if d.total == 0 {
+		s.out.notef("No unread posts since %s: nothing to send\n", start.Format(time.RFC3339))
+		return nil
+	}
+
+	send := cmd.flagBool("send")
+	d.to = to
+	d.from = s.sStruct.SMTPFrom
+	if send {
+		if d.to == "" {
+			return fmt.Errorf("no address to mail the digest to: set one with 'gator digest set --to <email>'")
+		}
+		if s.sStruct.SMTPAddr == "" || d.from == "" {
+			return fmt.Errorf("sending digests needs smtp_addr and smtp_from: see 'gator config set'")
+		}
+	} else if d.from == "" {
+		d.from = "gator@localhost"
+	}
+
+	var msg bytes.Buffer
+	if err := d.write(&msg); err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+
+	verb, dest := "Wrote", ""
+	if send {
+		if err := sendMail(s.sStruct.SMTPAddr, s.sStruct.SMTPUsername, s.sStruct.SMTPPassword, d.from, d.to, msg.Bytes()); err != nil {
+			return fmt.Errorf("failed to send digest: %w", err)
+		}
+		verb, dest = "Sent", d.to
+	} else if out := cmd.flagString("out"); out == "-" {
+		if _, err := s.out.w.Write(msg.Bytes()); err != nil {
+			return err
+		}
+		dest = "stdout"
+	} else {
+		if err := os.WriteFile(filepath.Clean(out), msg.Bytes(), 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", out, err)
+		}
+		dest = out
+	}
+
+	if !cmd.flagBool("dry-run") {
+		err := s.db.SetDigestSent(ctx, database.SetDigestSentParams{UserID: user.ID, LastSentAt: sql.NullTime{Time: d.end, Valid: true}})
+		if err != nil {
+			return fmt.Errorf("digest sent, but failed to record it: %w", err)
+		}
+	}
+	s.out.notef("%s a digest of %d posts from %d feeds to %s\n", verb, d.listed(), len(d.feeds), dest)
+	return nil
+}
+
+type digest struct {
+	from, to   string
+	start, end time.Time
+	date       time.Time // when it was made; end is earlier if it was cut
+	feeds      []digestFeed
+	total      int // posts in the window, including those over the limit
+}
+
+type digestFeed struct {
+	Name, URL string
+	Posts     []digestPost
+}
+
+type digestPost struct {
+	Title, URL, Author, Summary string
+}
+
+// collectDigest lists the unread posts fetched in [start, end), which is what
+// makes consecutive windows disjoint, and groups up to limit of them by feed.
+// When there are more, the digest's window is cut short by cutDigest.
+func collectDigest(ctx context.Context, db database.Querier, user database.User, start, end time.Time, limit int) (digest, error) {
+	d := digest{start: start, end: end, date: end}
+	opts := browseOptions{
+		limit:  100,
+		sortBy: "fetched",
+		unread: true,
+		since:  sql.NullTime{Time: start, Valid: true},
+		until:  sql.NullTime{Time: end, Valid: true},
+	}
+	var all []database.GetPostsForUserFilteredRow
+	for {
+		posts, next, err := listPosts(ctx, db, user, opts)
+		if err != nil {
+			return d, err
+		}
+		all = append(all, posts...)
+		if next == "" {
+			break
+		}
+		opts.cursor = next
+	}
+
+	d.total = len(all)
+	listed, cut, ok := cutDigest(all, limit)
+	if ok {
+		d.end = cut
+	}
+	byFeed := map[string]int{}
+	for _, post := range listed {
+		i, ok := byFeed[post.FeedUrl]
+		if !ok {
+			i = len(d.feeds)
+			byFeed[post.FeedUrl] = i
+			d.feeds = append(d.feeds, digestFeed{Name: post.FeedName, URL: post.FeedUrl})
+		}
+		d.feeds[i].Posts = append(d.feeds[i].Posts, digestPost{
+			Title:   cmp.Or(post.Title, post.Url),
+			URL:     post.Url,
+			Author:  post.Author.String,
+			Summary: summarize(htmlText(post.Description.String), digestSummary),
+		})
+	}
+	slices.SortStableFunc(d.feeds, func(a, b digestFeed) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return d, nil
+}
+
+// cutDigest picks the posts to list from a window's posts, newest first: the
+// oldest limit of them, and where the window must end for the rest to open
+// the next digest, the fetch time of the oldest one left out. Posts fetched
+// at that very instant are left out too, or, if they alone fill the limit,
+// listed with it. ok is false when everything is listed.
+func cutDigest(posts []database.GetPostsForUserFilteredRow, limit int) (listed []database.GetPostsForUserFilteredRow, end time.Time, ok bool) {
+	if len(posts) <= limit {
+		return posts, time.Time{}, false
+	}
+	i := len(posts) - limit
+	end = posts[i-1].CreatedAt
+	for i < len(posts) && !posts[i].CreatedAt.Before(end) {
+		i++
+	}
+	if i == len(posts) {
+		for i = len(posts) - limit - 1; i >= 0 && posts[i].CreatedAt.Equal(end); i-- {
+		}
+		if i < 0 {
+			return posts, time.Time{}, false
+		}
+		end = posts[i].CreatedAt
+		i++
+	}
+	return posts[i:], end, true
+}
+
+// summarize collapses whitespace and shortens s to n characters on a word
+// boundary.
+func summarize(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		s = string(r[:n])
+		if i := strings.LastIndexByte(s, ' '); i > 0 {
+			s = s[:i]
+		}
+		s += "…"
+	}
+	return s
+}
+
+func (d digest) listed() int {
+	n := 0
+	for _, f := range d.feeds {
+		n += len(f.Posts)
+	}
+	return n
+}
+
+func (d digest) subject() string {
+	feeds := "feeds"
+	if len(d.feeds) == 1 {
+		feeds = "feed"
+	}
+	posts := "posts"
+	if d.total == 1 {
+		posts = "post"
+	}
+	return fmt.Sprintf("Gator digest: %d new %s from %d %s", d.total, posts, len(d.feeds), feeds)
+}
+
+// write renders d as an RFC 5322 message with CRLF line endings, ready for
+// SMTP.
+func (d digest) write(w io.Writer) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	parts := []struct {
+		contentType string
+		render      func(io.Writer) error
+	}{
+		{"text/plain; charset=utf-8", d.writeText},
+		{"text/html; charset=utf-8", d.writeHTML},
+	}
+	for _, p := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		// the text mode writer turns the parts' line breaks into CRLF
+		qw := quotedprintable.NewWriter(pw)
+		if err := p.render(qw); err != nil {
+			return err
+		}
+		if err := qw.Close(); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	headers := [][2]string{
+		{"From", d.from},
+		{"To", d.to},
+		{"Subject", mime.QEncoding.Encode("utf-8", d.subject())},
+		{"Date", d.date.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + uuid.NewString() + "@gator>"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()})},
+	}
+	var head strings.Builder
+	for _, h := range headers {
+		if h[1] != "" {
+			fmt.Fprintf(&head, "%s: %s\r\n", h[0], h[1])
+		}
+	}
+	head.WriteString("\r\n")
+	if _, err := io.WriteString(w, head.String()); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+func (d digest) writeText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", d.subject())
+	fmt.Fprintf(&b, "Fetched between %s and %s.\n", d.start.Format(time.RFC1123), d.end.Format(time.RFC1123))
+	for _, f := range d.feeds {
+		fmt.Fprintf(&b, "\n%s\n%s\n", f.Name, strings.Repeat("=", len([]rune(f.Name))))
+		for _, p := range f.Posts {
+			fmt.Fprintf(&b, "\n* %s\n  %s\n", p.Title, p.URL)
+			if p.Author != "" {
+				fmt.Fprintf(&b, "  by %s\n", p.Author)
+			}
+			if p.Summary != "" {
+				fmt.Fprintf(&b, "  %s\n", p.Summary)
+			}
+		}
+	}
+	if more := d.total - d.listed(); more > 0 {
+		fmt.Fprintf(&b, "\n...and %d more, left for the next digest.\n", more)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var digestHTML = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: sans-serif; max-width: 40em">
+<h1 style="font-size: 1.3em">{{.Subject}}</h1>
+<p style="color: #666">Fetched between {{.Start}} and {{.End}}.</p>
+{{range .Feeds}}<h2 style="font-size: 1.1em; border-bottom: 1px solid #ccc"><a href="{{.URL}}">{{.Name}}</a></h2>
+<ul>
+{{range .Posts}}<li style="margin-bottom: 0.8em"><a href="{{.URL}}">{{.Title}}</a>{{if .Author}} <span style="color: #666">by {{.Author}}</span>{{end}}{{if .Summary}}<br>{{.Summary}}{{end}}</li>
+{{end}}</ul>
+{{end}}{{if .More}}<p>...and {{.More}} more, left for the next digest.</p>
+{{end}}</body>
+</html>
+`))
+
+func (d digest) writeHTML(w io.Writer) error {
+	return digestHTML.Execute(w, map[string]any{
+		"Subject": d.subject(),
+		"Start":   d.start.Format(time.RFC1123),
+		"End":     d.end.Format(time.RFC1123),
+		"Feeds":   d.feeds,
+		"More":    d.total - d.listed(),
+	})
+}
+
+// sendMail delivers msg through the SMTP server at addr, authenticating when
+// a username is configured. smtp.SendMail upgrades to TLS when the server
+// offers STARTTLS, and refuses to send the password unencrypted to anything
+// but localhost.
+func sendMail(addr, username, password, from, to string, msg []byte) error {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid smtp_from %q: %w", from, err)
+	}
+	var auth smtp.Auth
+	if username != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp_addr %q: %w", addr, err)
+		}
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return smtp.SendMail(addr, auth, sender.Address, []string{to}, msg)
+}
+
+func digestTable(settings database.DigestSetting) ([]string, [][]any) {
+	var next any
+	if settings.LastSentAt.Valid {
+		next = settings.LastSentAt.Time.Add(digestSchedules[settings.Schedule])
+	}
+	return []string{"email", "schedule", "last_sent_at", "next_due_at"},
+		[][]any{{settings.Email, settings.Schedule, settings.LastSentAt, next}}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// smtpStandIn speaks just enough SMTP for smtp.SendMail and keeps the
+// messages it receives.
+type smtpStandIn struct {
+	addr string
+
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data []byte
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	srv := &smtpStandIn{addr: l.Addr().String()}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(textproto.NewConn(c))
+		}
+	}()
+	return srv
+}
+
+func (srv *smtpStandIn) serve(c *textproto.Conn) {
+	defer c.Close()
+	c.PrintfLine("220 localhost ESMTP stand-in")
+	var msg smtpMessage
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 localhost")
+		case "MAIL":
+			msg = smtpMessage{from: strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")}
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			if msg.data, err = c.ReadDotBytes(); err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.messages = append(srv.messages, msg)
+			srv.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (srv *smtpStandIn) received() []smtpMessage {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]smtpMessage(nil), srv.messages...)
+}
+
+// readDigest parses a digest message and returns its headers and the
+// decoded text and HTML parts.
+func readDigest(t *testing.T, data []byte) (mail.Header, string, string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("reading message: %v\n%s", err, data)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type %q: %v", msg.Header.Get("Content-Type"), err)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mediaType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts[mediaType] = string(body)
+	}
+	return msg.Header, parts["text/plain"], parts["text/html"]
+}
+
+func TestDigest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer srv.Close()
+	smtpSrv := newSMTPStandIn(t)
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+	scrapeFeeds(h.s)
+
+	if err := h.run("", "digest", "--send"); err == nil {
+		t.Error("digest --send without an address succeeded")
+	}
+	if err := h.run("", "digest", "set", "--schedule", "monthly"); err == nil {
+		t.Error("digest set --schedule monthly succeeded")
+	}
+	h.mustRun("", "digest", "set", "--to", "Alice <alice@example.com>", "--schedule", "daily")
+	if err := h.run("", "digest", "--to", "alice at example"); err == nil {
+		t.Error("digest --to with an invalid address succeeded")
+	}
+	if err := h.run("", "digest", "--send"); err == nil {
+		t.Error("digest --send without smtp settings succeeded")
+	}
+	h.s.sStruct.SMTPAddr = smtpSrv.addr
+	h.s.sStruct.SMTPFrom = "Gator <gator@example.com>"
+
+	// a dry run writes the message without recording it
+	h.mustRun("", "digest", "--dry-run")
+	if header, text, _ := readDigest(t, h.out.Bytes()); header.Get("To") != "alice@example.com" || !strings.Contains(text, "Newer post") {
+		t.Errorf("dry run wrote %q", h.out.String())
+	}
+
+	h.mustRun("", "digest", "--send")
+	sent := smtpSrv.received()
+	if len(sent) != 1 || sent[0].from != "gator@example.com" || sent[0].to[0] != "alice@example.com" {
+		t.Fatalf("sent %+v", sent)
+	}
+	header, text, html := readDigest(t, sent[0].data)
+	if got := header.Get("Subject"); got != "Gator digest: 2 new posts from 1 feed" {
+		t.Errorf("subject = %q", got)
+	}
+	for _, want := range []string{"Test Blog\n=========", "* Newer post\n  " + srv.URL + "/newer\n  by Ann", "* Older post"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text part lacks %q:\n%s", want, text)
+		}
+	}
+	if !strings.Contains(html, `<a href="`+srv.URL+`/newer">Newer post</a>`) {
+		t.Errorf("html part:\n%s", html)
+	}
+
+	// cron runs before the schedule is due do nothing, and forced ones only
+	// include posts fetched since the last digest
+	h.mustRun("", "digest", "--send")
+	h.mustRun("", "digest", "--send", "--force")
+	if n := len(smtpSrv.received()); n != 1 {
+		t.Fatalf("sent %d digests, want 1", n)
+	}
+	ctx := context.Background()
+	feed, err := h.s.db.GetFeedByURL(ctx, srv.URL+"/rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := h.s.db.GetUserByName(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addPost := func(title string, fetched time.Time) database.Post {
+		t.Helper()
+		post, err := h.s.db.CreatePost(ctx, database.CreatePostParams{
+			ID: uuid.New(), CreatedAt: fetched, UpdatedAt: fetched, Title: title, Url: srv.URL + "/" + uuid.NewString(), FeedID: feed.ID, Categories: []string{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return post
+	}
+	now := time.Now()
+	addPost("Latest post", now)
+	read := addPost("Read post", now)
+	if err := h.s.db.SetPostRead(ctx, database.SetPostReadParams{UserID: user.ID, PostID: read.ID, Read: true}); err != nil {
+		t.Fatal(err)
+	}
+	h.mustRun("", "digest", "--send", "--force")
+	if sent = smtpSrv.received(); len(sent) != 2 {
+		t.Fatalf("sent %d digests, want 2", len(sent))
+	}
+	if _, text, _ := readDigest(t, sent[1].data); !strings.Contains(text, "Latest post") || strings.Contains(text, "Newer post") || strings.Contains(text, "Read post") {
+		t.Errorf("second digest:\n%s", text)
+	}
+
+	// a missed cron run doesn't lose the posts fetched in the meantime
+	if err := h.s.db.SetDigestSent(ctx, database.SetDigestSentParams{UserID: user.ID, LastSentAt: sql.NullTime{Time: now.Add(-72 * time.Hour), Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	addPost("Missed post", now.Add(-48*time.Hour))
+	h.mustRun("", "digest", "--send")
+	if sent = smtpSrv.received(); len(sent) != 3 {
+		t.Fatalf("sent %d digests, want 3", len(sent))
+	}
+	if _, text, _ := readDigest(t, sent[2].data); !strings.Contains(text, "Missed post") {
+		t.Errorf("digest after a missed run:\n%s", text)
+	}
+
+	// posts over the limit open the next digest rather than being skipped
+	if err := h.s.db.SetDigestSent(ctx, database.SetDigestSentParams{UserID: user.ID, LastSentAt: sql.NullTime{Time: now.Add(-time.Hour), Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	for i, title := range []string{"Backlog one", "Backlog two", "Backlog three"} {
+		addPost(title, now.Add(time.Duration(i-3)*10*time.Minute))
+	}
+	h.mustRun("", "digest", "--send", "--force", "--limit", "2")
+	h.mustRun("", "digest", "--send", "--force", "--limit", "2")
+	if sent = smtpSrv.received(); len(sent) != 5 {
+		t.Fatalf("sent %d digests, want 5", len(sent))
+	}
+	if _, text, _ := readDigest(t, sent[3].data); !strings.Contains(text, "Backlog one") || !strings.Contains(text, "Backlog two") ||
+		strings.Contains(text, "Backlog three") || !strings.Contains(text, "more, left for the next digest") {
+		t.Errorf("digest over the limit:\n%s", text)
+	}
+	if _, text, _ := readDigest(t, sent[4].data); !strings.Contains(text, "Backlog three") || strings.Contains(text, "Backlog two") {
+		t.Errorf("digest after one over the limit:\n%s", text)
+	}
+
+	h.mustRun("", "digest", "status")
+	var status []struct {
+		Email      string  `json:"email"`
+		Schedule   string  `json:"schedule"`
+		LastSentAt *string `json:"last_sent_at"`
+		NextDueAt  *string `json:"next_due_at"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &status); err != nil || len(status) != 1 || status[0].LastSentAt == nil || status[0].NextDueAt == nil {
+		t.Errorf("status %q: %v", h.out.String(), err)
+	}
+}
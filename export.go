@@ -0,0 +1,310 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// exportPage is how many posts export-posts asks listPosts for at a time.
+const exportPage = 500
+
+var archivePage = template.Must(template.New("archive.html").ParseFS(templateFS, "templates/archive.html"))
+
+func exportFlags(fs *flag.FlagSet) {
+	fs.String("format", "md", "md|json|csv|html")
+	fs.String("out", "", "file to write, or - for stdout; a directory for html (default gator-posts.<format>, gator-archive for html)")
+	fs.String("since", "", "only posts newer than a duration (24h) or date (2006-01-02)")
+	fs.String("until", "", "only posts older than a duration (24h) or date (2006-01-02)")
+	fs.String("feed", "", "only posts from the feed with this name or url")
+	fs.String("category", "", "only posts the feed tagged with this category")
+	fs.String("folder", "", "only posts from feeds in this 'gator category' or its subcategories")
+	fs.String("tag", "", "only posts you tagged with this tag")
+	fs.Bool("starred", false, "only starred posts")
+	fs.Bool("unread", false, "only posts not marked as read")
+	fs.Bool("hidden", false, "include posts hidden by rules")
+}
+
+func handlerExportPosts(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+	format := cmd.flagString("format")
+	out := cmd.flagString("out")
+	switch format {
+	case "md", formatJSON, formatCSV:
+		if out == "" {
+			out = "gator-posts." + format
+		}
+	case "html":
+		if out == "" {
+			out = "gator-archive"
+		}
+		if out == "-" {
+			return fmt.Errorf("--format html writes a directory: pass --out <dir>")
+		}
+	default:
+		return fmt.Errorf("unknown export format %q: expected md, json, csv or html", format)
+	}
+
+	opts := browseOptions{
+		limit:    exportPage,
+		sortBy:   "published",
+		feed:     cmd.flagString("feed"),
+		category: cmd.flagString("category"),
+		folder:   cmd.flagString("folder"),
+		tag:      normalizeTag(cmd.flagString("tag")),
+		starred:  cmd.flagBool("starred"),
+		unread:   cmd.flagBool("unread"),
+		hidden:   cmd.flagBool("hidden"),
+	}
+	var err error
+	if opts.since, err = parseTimeArg(cmd.flagString("since")); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if opts.until, err = parseTimeArg(cmd.flagString("until")); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+	posts, err := exportPosts(ctx, s.db, user, opts)
+	if err != nil {
+		return err
+	}
+
+	write := func(w io.Writer) error { return writeMarkdown(w, posts) }
+	switch format {
+	case "html":
+		if err := writeArchive(out, posts, time.Now()); err != nil {
+			return err
+		}
+		s.out.notef("Exported %d posts to %s\n", len(posts), filepath.Join(out, "index.html"))
+		return nil
+	case formatJSON, formatCSV:
+		highlighted, err := highlightedPosts(ctx, s.db, user, posts)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error {
+			return (&Output{format: format, w: w}).render(postsTable(posts, highlighted))
+		}
+	}
+	if out == "-" {
+		return write(s.out.w)
+	}
+	if err := writeFileAtomic(out, write); err != nil {
+		return err
+	}
+	s.out.notef("Exported %d posts to %s\n", len(posts), out)
+	return nil
+}
+
+// exportPosts pages through every post matching opts, newest first.
+func exportPosts(ctx context.Context, db database.Querier, user database.User, opts browseOptions) ([]database.GetPostsForUserFilteredRow, error) {
+	var all []database.GetPostsForUserFilteredRow
+	for {
+		posts, next, err := listPosts(ctx, db, user, opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, posts...)
+		if next == "" {
+			return all, nil
+		}
+		opts.cursor = next
+	}
+}
+
+// exportGroup is the posts of one feed or month, in export order.
+type exportGroup struct {
+	Name  string
+	Href  string // page of the group in the html archive
+	Posts []database.GetPostsForUserFilteredRow
+}
+
+// groupPosts groups posts by key, keeping the order in which the groups
+// first appear.
+func groupPosts(posts []database.GetPostsForUserFilteredRow, key func(database.GetPostsForUserFilteredRow) string) []exportGroup {
+	var groups []exportGroup
+	index := map[string]int{}
+	for _, post := range posts {
+		k := key(post)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, exportGroup{Name: k})
+		}
+		groups[i].Posts = append(groups[i].Posts, post)
+	}
+	return groups
+}
+
+// feedsByName groups posts by feed url, since names needn't be unique, and
+// sorts the feeds by name.
+func feedsByName(posts []database.GetPostsForUserFilteredRow) []exportGroup {
+	feeds := groupPosts(posts, func(p database.GetPostsForUserFilteredRow) string { return p.FeedUrl })
+	for i := range feeds {
+		feeds[i].Name = feeds[i].Posts[0].FeedName
+	}
+	slices.SortStableFunc(feeds, func(a, b exportGroup) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return feeds
+}
+
+// writeMarkdown writes one section per feed, with the posts newest first.
+func writeMarkdown(w io.Writer, posts []database.GetPostsForUserFilteredRow) error {
+	var b strings.Builder
+	b.WriteString("# Posts exported from gator\n")
+	for _, feed := range feedsByName(posts) {
+		fmt.Fprintf(&b, "\n## %s\n", markdownEscape(feed.Name))
+		for _, post := range feed.Posts {
+			fmt.Fprintf(&b, "\n### [%s](<%s>)\n\n", markdownEscape(post.Title), post.Url)
+			meta := []string{postSortKey("published", post).Format("2006-01-02 15:04")}
+			if post.Author.Valid && post.Author.String != "" {
+				meta = append(meta, markdownEscape(post.Author.String))
+			}
+			if post.StarredAt.Valid {
+				meta = append(meta, "★")
+			}
+			for _, tag := range post.Tags {
+				meta = append(meta, "#"+tag)
+			}
+			fmt.Fprintf(&b, "_%s_\n", strings.Join(meta, " · "))
+			if post.Note.Valid && post.Note.String != "" {
+				b.WriteString("\n> " + strings.ReplaceAll(strings.TrimSpace(post.Note.String), "\n", "\n> ") + "\n")
+			}
+			if text := strings.Join(strings.Fields(htmlText(postBody(post))), " "); text != "" {
+				fmt.Fprintf(&b, "\n%s\n", markdownEscape(text))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, "#", `\#`,
+)
+
+// markdownEscape keeps titles and descriptions from turning into markup.
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+type archiveData struct {
+	Title     string
+	Root      string // relative path from the page to the archive root
+	Generated string
+	Feeds     []archiveLink
+	Months    []archiveLink
+	Posts     []archivePost
+}
+
+type archiveLink struct {
+	Name  string
+	Href  string
+	Count int
+}
+
+type archivePost struct {
+	database.GetPostsForUserFilteredRow
+	FeedHref  string
+	MonthHref string
+	Date      string
+	Text      string
+}
+
+// writeArchive builds a static site in dir: index.html lists the feeds and
+// months, and feeds/<feed>.html and months/<yyyy-mm>.html list their posts.
+// Every link is relative, so the directory can be opened from disk or served
+// from anywhere.
+func writeArchive(dir string, posts []database.GetPostsForUserFilteredRow, now time.Time) error {
+	for _, sub := range []string{"feeds", "months"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	feeds := feedsByName(posts)
+	feedHref := map[string]string{}
+	used := map[string]bool{}
+	for i := range feeds {
+		slug := slugify(feeds[i].Name)
+		for n := 2; used[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", slugify(feeds[i].Name), n)
+		}
+		used[slug] = true
+		feeds[i].Href = "feeds/" + slug + ".html"
+		feedHref[feeds[i].Posts[0].FeedUrl] = feeds[i].Href
+	}
+	month := func(p database.GetPostsForUserFilteredRow) string {
+		return postSortKey("published", p).Format("2006-01")
+	}
+	months := groupPosts(posts, month)
+	slices.SortFunc(months, func(a, b exportGroup) int { return strings.Compare(b.Name, a.Name) })
+
+	page := func(title string, posts []database.GetPostsForUserFilteredRow) archiveData {
+		data := archiveData{Title: title, Root: "../", Generated: now.Format("Jan 2, 2006 15:04")}
+		for _, p := range posts {
+			data.Posts = append(data.Posts, archivePost{
+				GetPostsForUserFilteredRow: p,
+				FeedHref:                   feedHref[p.FeedUrl],
+				MonthHref:                  "months/" + month(p) + ".html",
+				Date:                       postSortKey("published", p).Format("Jan 2, 2006"),
+				Text:                       strings.Join(strings.Fields(htmlText(postBody(p))), " "),
+			})
+		}
+		return data
+	}
+	write := func(name string, data archiveData) error {
+		return writeFileAtomic(filepath.Join(dir, name), func(w io.Writer) error {
+			return archivePage.Execute(w, data)
+		})
+	}
+
+	index := archiveData{Title: fmt.Sprintf("%d posts", len(posts)), Generated: now.Format("Jan 2, 2006 15:04")}
+	for _, feed := range feeds {
+		index.Feeds = append(index.Feeds, archiveLink{Name: feed.Name, Href: feed.Href, Count: len(feed.Posts)})
+		if err := write(feed.Href, page(feed.Name, feed.Posts)); err != nil {
+			return err
+		}
+	}
+	for _, m := range months {
+		t, _ := time.Parse("2006-01", m.Name)
+		href := "months/" + m.Name + ".html"
+		index.Months = append(index.Months, archiveLink{Name: t.Format("January 2006"), Href: href, Count: len(m.Posts)})
+		if err := write(href, page(t.Format("January 2006"), m.Posts)); err != nil {
+			return err
+		}
+	}
+	return write("index.html", index)
+}
+
+// slugify turns a feed name into a file name: lowercase letters and digits
+// joined by dashes.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "feed"
+	}
+	return b.String()
+}
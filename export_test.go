@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportPosts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer srv.Close()
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+	h.mustRun("", "rule", "add", "--title-matches", "^older", "--action", "star")
+	scrapeFeeds(h.s)
+	h.mustRun("", "browse")
+	var posts []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &posts); err != nil || len(posts) != 2 {
+		t.Fatalf("browse: %v, %s", err, h.out.String())
+	}
+	h.mustRun("Worth a *read*.\n", "note", posts[0].ID)
+	h.mustRun("", "tag", posts[0].ID, "go")
+
+	h.mustRun("", "export-posts", "--out", "-")
+	for _, want := range []string{
+		"\n## Test Blog\n",
+		"\n### [Newer post](<" + srv.URL + "/newer>)\n\n_2026-10-06 10:00 · Ann · #go_\n\n> Worth a *read*.\n",
+		"\n### [Older post](<" + srv.URL + "/older>)\n\n_2026-10-05 10:00 · ★_\n",
+	} {
+		if !strings.Contains(h.out.String(), want) {
+			t.Errorf("markdown lacks %q:\n%s", want, h.out.String())
+		}
+	}
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "starred.json")
+	h.mustRun("", "export-posts", "--format", "json", "--starred", "--out", out)
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var starred []struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(data, &starred); err != nil || len(starred) != 1 || starred[0].Title != "Older post" {
+		t.Errorf("starred export %s: %v", data, err)
+	}
+
+	out = filepath.Join(dir, "posts.csv")
+	h.mustRun("", "export-posts", "--format", "csv", "--feed", "Test Blog", "--out", out)
+	if data, err := os.ReadFile(out); err != nil || strings.Count(string(data), "\n") != 3 || !strings.HasPrefix(string(data), "id,title,url,") {
+		t.Errorf("csv export %q: %v", data, err)
+	}
+
+	// the archive links every page with relative paths
+	site := filepath.Join(dir, "site")
+	h.mustRun("", "export-posts", "--format", "html", "--out", site)
+	for name, wants := range map[string][]string{
+		"index.html":           {`href="feeds/test-blog.html">Test Blog</a> <span class="meta">2</span>`, `href="months/2026-10.html">October 2026</a>`},
+		"feeds/test-blog.html": {`<a href="../index.html">`, `<a href="../months/2026-10.html">Oct 6, 2026</a>`, "Worth a *read*."},
+		"months/2026-10.html":  {"<h1>October 2026</h1>", `<a href="../feeds/test-blog.html">Test Blog</a>`, "Older post"},
+	} {
+		data, err := os.ReadFile(filepath.Join(site, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s lacks %q:\n%s", name, want, data)
+			}
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--format", "html", "--out", "-"},
+		{"--format", "pdf"},
+		{"--since", "yesterday"},
+	} {
+		if err := h.run("", append([]string{"export-posts"}, args...)...); err == nil {
+			t.Errorf("export-posts %q succeeded", args)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	for name, want := range map[string]string{
+		"Test Blog":      "test-blog",
+		"  Go: News!! ":  "go-news",
+		"Ünïcode Feed 2": "ünïcode-feed-2",
+		"???":            "feed",
+	} {
+		if got := slugify(name); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Feed tokens work like API keys, but only unlock the feeds served at
+// /users/{name}/feed.atom and feed.rss. They end up in feed reader
+// subscriptions, so they get a scheme of their own and grant nothing else.
+const feedTokenScheme = "gtf_"
+
+func createFeedToken(ctx context.Context, db database.Querier, user database.User, name string) (string, database.FeedToken, error) {
+	token, body, err := newSecret(feedTokenScheme)
+	if err != nil {
+		return "", database.FeedToken{}, fmt.Errorf("failed to generate feed token: %w", err)
+	}
+
+	feedToken, err := db.CreateFeedToken(ctx, database.CreateFeedTokenParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    body[:apiKeyPrefixLen],
+		TokenHash: hashAPIKey(token),
+	})
+	if err != nil {
+		return "", feedToken, fmt.Errorf("failed to store feed token: %w", err)
+	}
+	return token, feedToken, nil
+}
+
+// checkFeedToken reports whether token is a live feed token of user, and
+// records that it was used.
+func checkFeedToken(ctx context.Context, db database.Querier, user database.User, token string) (bool, error) {
+	if !strings.HasPrefix(token, feedTokenScheme) {
+		return false, nil
+	}
+	feedToken, err := db.GetFeedTokenByHash(ctx, hashAPIKey(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check feed token: %w", err)
+	}
+	if feedToken.UserID != user.ID {
+		return false, nil
+	}
+	if err := db.TouchFeedToken(ctx, feedToken.ID); err != nil {
+		return false, fmt.Errorf("failed to record feed token use: %w", err)
+	}
+	return true, nil
+}
+
+func handlerFeedToken(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+
+	switch cmd.Args[0] {
+	case "create":
+		name := "reader"
+		if len(cmd.Args) == 2 {
+			name = cmd.Args[1]
+		}
+		token, feedToken, err := createFeedToken(ctx, s.db, user, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out.w, "Created feed token %s (%s) for %s. Subscribe to\n\n", feedToken.Prefix, feedToken.Name, user.Name)
+		fmt.Fprintf(s.out.w, "    http://<gator serve address>/users/%s/feed.atom?token=%s\n\n", user.Name, token)
+		fmt.Fprintln(s.out.w, "or feed.rss. Store it now, it can't be shown again.")
+		return nil
+
+	case "list":
+		tokens, err := s.db.ListFeedTokensForUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list feed tokens: %w", err)
+		}
+		rows := make([][]any, 0, len(tokens))
+		for _, t := range tokens {
+			rows = append(rows, []any{t.Prefix, t.Name, t.CreatedAt, t.LastUsedAt, t.RevokedAt})
+		}
+		return s.out.render([]string{"prefix", "name", "created_at", "last_used_at", "revoked_at"}, rows)
+
+	case "revoke":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator feedtoken revoke <prefix>")
+		}
+		n, err := s.db.RevokeFeedToken(ctx, database.RevokeFeedTokenParams{
+			UserID: user.ID,
+			Prefix: cmd.Args[1],
+		})
+		if err != nil {
+			return fmt.Errorf("failed to revoke feed token: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no active feed token with prefix %s", cmd.Args[1])
+		}
+		s.out.notef("Revoked feed token %s\n", cmd.Args[1])
+		return nil
+	}
+
+	return fmt.Errorf("unknown feedtoken action %q: expected create, list or revoke", cmd.Args[0])
+}
@@ -4,65 +4,96 @@ import (
 	"blog/internal/database"
 	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/net/html"
 )
 
-func helperHTMLP(input string) string {
-	doc, _ := html.Parse(strings.NewReader(input))
-	var f func(*html.Node)
-	var buf strings.Builder
-	f = func(n *html.Node) {
-		if n.Type == html.TextNode {
-			buf.WriteString(n.Data)
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
-		}
+func handlerBrowse(s *State, cmd Command, user database.User) error {
+	opts, err := parseBrowseArgs(cmd)
+	if err != nil {
+		return err
 	}
-	f(doc)
-	return buf.String()
-}
 
-func handlerBrowse(s *State, cmd Command, user database.User) error {
-	limit := 2
-	if len(cmd.Args) == 1 {
-		if specifiedLimit, err := strconv.Atoi(cmd.Args[0]); err == nil {
-			limit = specifiedLimit
-		} else {
-			return fmt.Errorf("invalid limit: %w", err)
-		}
+	ctx := context.Background()
+	posts, nextCursor, err := listPosts(ctx, s.db, user, opts)
+	if err != nil {
+		return err
+	}
+	highlighted, err := highlightedPosts(ctx, s.db, user, posts)
+	if err != nil {
+		return err
 	}
 
-	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
-		UserID: uuid.NullUUID{
-			UUID:  user.ID,
-			Valid: true,
-		},
-		Limit: int32(limit),
-	})
+	s.out.notef("Found %d posts for user %s:\n", len(posts), user.Name)
+	if opts.full && s.out.format == formatTable {
+		err = printFullPosts(s.out.w, posts)
+	} else if opts.full {
+		columns, rows := postsTable(posts, highlighted)
+		err = s.out.render(withContent(columns, rows, posts))
+	} else {
+		err = s.out.render(postsTable(posts, highlighted))
+	}
 	if err != nil {
-		return fmt.Errorf("couldn't get posts for user: %w", err)
+		return err
 	}
 
-	fmt.Printf("Found %d posts for user %s:\n", len(posts), user.Name)
-	for _, post := range posts {
-		fmt.Printf("%s from %s\n", post.PublishedAt.Time.Format("Mon Jan 2"), post.FeedID)
-		fmt.Printf("--- %s ---\n", post.Title)
-		fmt.Printf("    %v\n", helperHTMLP(post.Description.String))
-		fmt.Printf("Link: %s\n", post.Url)
-		fmt.Println("=====================================")
+	if nextCursor != "" {
+		s.out.notef("Next page: %s\n", opts.nextPageCommand(nextCursor))
 	}
 
 	return nil
 }
 
+// listPosts runs a browse query. nextCursor is empty when there is no
+// further page.
+func listPosts(ctx context.Context, db database.Querier, user database.User, opts browseOptions) (posts []database.GetPostsForUserFilteredRow, nextCursor string, err error) {
+	params, err := opts.params(user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	posts, err = db.GetPostsForUserFiltered(ctx, params)
+	if err != nil {
+		return nil, "", fmt.Errorf("couldn't get posts for user: %w", err)
+	}
+
+	if len(posts) == opts.limit {
+		last := posts[len(posts)-1]
+		nextCursor = encodeCursor(opts.sortBy, postSortKey(opts.sortBy, last), last.ID)
+	}
+	return posts, nextCursor, nil
+}
+
+func postsTable(posts []database.GetPostsForUserFilteredRow, highlighted map[uuid.UUID]bool) ([]string, [][]any) {
+	rows := make([][]any, 0, len(posts))
+	for _, post := range posts {
+		rows = append(rows, []any{
+			post.ID,
+			post.Title,
+			post.Url,
+			post.FeedName,
+			post.FeedUrl,
+			post.Author,
+			post.Categories,
+			post.PublishedAt,
+			post.CreatedAt,
+			post.ReadAt,
+			post.StarredAt,
+			post.HiddenAt,
+			highlighted[post.ID],
+			post.Tags,
+			post.Note,
+			htmlText(post.Description.String),
+		})
+	}
+	return []string{"id", "title", "url", "feed_name", "feed_url", "author", "categories", "published_at", "fetched_at", "read_at", "starred_at", "hidden_at", "highlighted", "tags", "note", "description"}, rows
+}
+
 func handlerFeeds(s *State, cmd Command) error {
 	feeds, err := s.db.GetAllFeeds(context.Background())
 	if err != nil {
@@ -70,26 +101,32 @@ func handlerFeeds(s *State, cmd Command) error {
 	}
 
 	if len(feeds) == 0 {
-		fmt.Println("no feed found")
-		return nil
+		s.out.notef("no feed found\n")
 	}
 
-	fmt.Println("Feeds in database")
+	return s.out.render(feedsTable(feeds))
+}
+
+func feedsTable(feeds []database.GetAllFeedsRow) ([]string, [][]any) {
+	rows := make([][]any, 0, len(feeds))
 	for _, f := range feeds {
-		fmt.Printf("-Name: %s\n, -URL: %s\n, -User: %s\n", f.FeedName, f.FeedUrl, f.UserName)
+		rows = append(rows, []any{f.FeedName, f.FeedUrl, f.UserName})
 	}
-	return nil
+	return []string{"name", "url", "user_name"}, rows
 }
 
 func handlerAddFeed(s *State, cmd Command, user database.User) error {
-	if len(cmd.Args) != 2 {
-		return fmt.Errorf("usage: addfeed <name> <url>")
+	feed, err := addFeed(context.Background(), s.db, user, cmd.Args[0], cmd.Args[1])
+	if err != nil {
+		return err
 	}
-	name := cmd.Args[0]
-	url := cmd.Args[1]
 
-	ctx := context.Background()
+	s.out.notef("Added %s and followed it\n", feed.Name)
+	return s.out.render([]string{"id", "name", "url", "created_at"}, [][]any{{feed.ID, feed.Name, feed.Url, feed.CreatedAt}})
+}
 
+// addFeed creates a feed and makes its creator follow it.
+func addFeed(ctx context.Context, db database.Querier, user database.User, name, url string) (database.CreateFeedRow, error) {
 	params := database.CreateFeedParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
@@ -99,35 +136,23 @@ func handlerAddFeed(s *State, cmd Command, user database.User) error {
 		UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
 	}
 
-	feed, err := s.db.CreateFeed(ctx, params)
+	feed, err := db.CreateFeed(ctx, params)
 	if err != nil {
-		return fmt.Errorf("failed to create feed: %w", err)
+		return feed, fmt.Errorf("failed to create feed: %w", err)
 	}
 
-	_, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+	_, err = db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
 		UserID: user.ID,
 		Url:    url,
 	})
 	if err != nil {
-		return fmt.Errorf("Failed to create feedFollow: %w", err)
+		return feed, fmt.Errorf("Failed to create feedFollow: %w", err)
 	}
 
-	fmt.Printf("Feed created:\nID: %s\nName: %s\nURL: %s\nUserID: %s\nCreatedAt: %s\nUpdatedAt: %s\n",
-		feed.ID.String(),
-		feed.Name,
-		feed.Url,
-		feed.UserID.UUID,
-		feed.CreatedAt.String(),
-		feed.UpdatedAt.String(),
-	)
-
-	return nil
+	return feed, nil
 }
 
 func handlerFollow(s *State, cmd Command, user database.User) error {
-	if len(cmd.Args) != 1 {
-		return fmt.Errorf("usage: follow <feed_url>")
-	}
 	url := cmd.Args[0]
 
 	feedFollow, err := s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
@@ -138,7 +163,7 @@ func handlerFollow(s *State, cmd Command, user database.User) error {
 		return fmt.Errorf("Failed to Create Folowfeed :%w", err)
 	}
 
-	fmt.Printf("%s is now following %s\n", feedFollow.UserName, feedFollow.FeedName)
+	s.out.notef("%s is now following %s\n", feedFollow.UserName, feedFollow.FeedName)
 	return nil
 }
 
@@ -150,50 +175,37 @@ func handlerFollowing(s *State, cmd Command, user database.User) error {
 	}
 
 	if len(follows) == 0 {
-		fmt.Println("This user does not Follow anyone")
-		return nil
+		s.out.notef("This user does not Follow anyone\n")
 	}
 
-	fmt.Println("Feeds Followed")
+	return s.out.render(followsTable(follows))
+}
+
+func followsTable(follows []database.GetFeedFollowsForUserRow) ([]string, [][]any) {
+	rows := make([][]any, 0, len(follows))
 	for _, f := range follows {
-		fmt.Printf("- %s\n", f.FeedName)
+		rows = append(rows, []any{f.FeedName, f.FeedUrl, f.FeedID, f.CreatedAt, f.FolderName})
 	}
-
-	return nil
+	return []string{"feed_name", "feed_url", "feed_id", "followed_at", "category"}, rows
 }
 
 func handlerUnfollowFeed(s *State, cmd Command, user database.User) error {
-	if len(cmd.Args) != 1 {
-		return fmt.Errorf("usage: unfollow <feed_id>")
-	}
 	ctx := context.Background()
 
-	feedID, err := s.db.GetFeedByURL(ctx, cmd.Args[0])
+	feed, err := s.db.GetFeedByURL(ctx, cmd.Args[0])
 	if err != nil {
 		return fmt.Errorf("invalid feed ID: %w", err)
 	}
 
 	err = s.db.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{
 		UserID: user.ID,
-		FeedID: feedID.ID,
+		FeedID: feed.ID,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to unfollow feed: %w", err)
 	}
 
-	fmt.Println("Successfully unfollowed feed:", feedID)
-	return nil
-}
-
-func handlerFetchRss(s *State, cmd Command) error {
-	feedURL := "https://www.wagslane.dev/index.xml"
-
-	feed, err := fetchFeed(context.Background(), feedURL)
-	if err != nil {
-		return fmt.Errorf("Failed to fetch RssFeed: %w", err)
-	}
-
-	fmt.Printf("%+v\n", feed)
+	s.out.notef("Unfollowed %s\n", feed.Name)
 	return nil
 }
 
@@ -203,32 +215,27 @@ func handlerGetUsers(s *State, cmd Command) error {
 		return fmt.Errorf("Failed to get Users: %v\n", err)
 	}
 
+	return s.out.render(usersTable(users, s.sStruct.CurrentUser))
+}
+
+func usersTable(users []string, current string) ([]string, [][]any) {
+	rows := make([][]any, 0, len(users))
 	for _, u := range users {
-		if u == s.sStruct.CurrentUser {
-			fmt.Printf("* %s (current)\n", u)
-		} else {
-			fmt.Printf("* %s\n", u)
-		}
+		rows = append(rows, []any{u, u == current})
 	}
-	return nil
+	return []string{"name", "current"}, rows
 }
 
 func handlerReset(s *State, cmd Command) error {
 	ctx := context.Background()
-	err := s.db.DeleteAllUsers(ctx)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to reset users: %v\n", err)
-		os.Exit(1)
+	if err := s.db.DeleteAllUsers(ctx); err != nil {
+		return fmt.Errorf("failed to reset users: %w", err)
 	}
-	fmt.Println("All users have been successfully removed.")
-	os.Exit(0)
+	s.out.notef("All users have been successfully removed.\n")
 	return nil
 }
 
 func handlerRegister(s *State, cmd Command) error {
-	if len(cmd.Args) < 1 {
-		return fmt.Errorf("Register handler expects a single argument: the username")
-	}
 	username := cmd.Args[0]
 
 	// Check if user already exists
@@ -245,36 +252,51 @@ func handlerRegister(s *State, cmd Command) error {
 	}
 
 	// err == sql.ErrNoRows means user doesn't exist, so we can create them
-	fmt.Printf("User %s doesn't exist, creating...\n", username)
+	s.out.notef("User %s doesn't exist, creating...\n", username)
+
+	pw, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(pw)
+	if err != nil {
+		return err
+	}
 
 	now := time.Now()
 	user, err := s.db.CreateUser(context.Background(), database.CreateUserParams{
-		ID:        uuid.New(),
-		CreatedAt: now,
-		UpdatedAt: now,
-		Name:      username,
+		ID:           uuid.New(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Name:         username,
+		PasswordHash: hash,
 	})
 	if err != nil {
 		return fmt.Errorf("could not create user: %w", err)
 	}
 
-	fmt.Printf("User created successfully: %s\n", user.Name)
-	fmt.Printf("User ID: %s\n", user.ID)
-	fmt.Printf("Created at: %s\n", user.CreatedAt.Format("2006-01-02 15:04:05"))
+	s.out.notef("Created user %s (ID: %s)\n", user.Name, user.ID)
+
+	key, _, err := createAPIKey(context.Background(), s.db, user, "cli")
+	if err != nil {
+		return fmt.Errorf("user created but %w", err)
+	}
+	s.out.notef("API key: %s\n", key)
 
 	// Set the newly created user as current user (persist to config)
-	if err := s.sStruct.SetUser(username); err != nil {
+	if err := s.sStruct.SetLogin(username, key); err != nil {
 		return fmt.Errorf("user created but failed to set as current user: %w", err)
 	}
-	fmt.Printf("Current user set to: %s\n", username)
+	s.out.notef("Logged in as %s with a new API key\n", username)
 
 	return nil
 }
 
+func loginFlags(fs *flag.FlagSet) {
+	fs.String("key", "", "API key of the user (default $GATOR_API_KEY)")
+}
+
 func handlerLogin(s *State, cmd Command) error {
-	if len(cmd.Args) < 1 {
-		return fmt.Errorf("login handler expects a single argument: the username")
-	}
 	username := cmd.Args[0]
 
 	// Check if user exists before logging in
@@ -286,20 +308,61 @@ func handlerLogin(s *State, cmd Command) error {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
+	// Users from before passwords can't log in until they set one with a
+	// reset token from whoever runs gator, whatever keys they still hold
+	if !user.PasswordHash.Valid {
+		return errNoPassword(user.Name)
+	}
+
+	// Without an API key, log in with the password; that issues a new key
+	key := cmd.flagString("key")
+	if key == "" {
+		key = os.Getenv("GATOR_API_KEY")
+	}
+
+	if key != "" {
+		keyUser, err := authenticate(context.Background(), s.db, key)
+		if err != nil {
+			return err
+		}
+		if keyUser.ID != user.ID {
+			return fmt.Errorf("api key does not belong to %s", username)
+		}
+	} else {
+		pw, err := promptPassword("Password: ")
+		if err != nil {
+			return err
+		}
+		if err := checkPassword(context.Background(), s.db, user, pw); err != nil {
+			return err
+		}
+		if key, _, err = createAPIKey(context.Background(), s.db, user, "login"); err != nil {
+			return err
+		}
+	}
+
 	// Set the user in config
-	if err := s.sStruct.SetUser(username); err != nil {
+	if err := s.sStruct.SetLogin(username, key); err != nil {
 		return fmt.Errorf("failed to set user: %w", err)
 	}
 
-	fmt.Printf("Successfully logged in as: %s (ID: %s)\n", user.Name, user.ID)
+	s.out.notef("Successfully logged in as: %s (ID: %s)\n", user.Name, user.ID)
 	return nil
 }
 
+// handlerWhoami names the owner of the API key in use, which is who every
+// other command runs as, whatever current_user_name says.
 func handlerWhoami(s *State, cmd Command) error {
-	if s.sStruct.CurrentUser == "" {
-		fmt.Println("No user currently logged in")
+	key := currentAPIKey(s)
+	if key == "" {
+		s.out.notef("Not logged in\n")
 		return nil
 	}
-	fmt.Printf("Current user: %s\n", s.sStruct.CurrentUser)
-	return nil
+	user, err := authenticate(context.Background(), s.db, key)
+	if err != nil {
+		return fmt.Errorf("User not logged in: %w", err)
+	}
+	prefix := strings.TrimPrefix(key, apiKeyScheme)
+	prefix = prefix[:min(len(prefix), apiKeyPrefixLen)]
+	return s.out.render([]string{"name", "id", "api_key_prefix"}, [][]any{{user.Name, user.ID, prefix}})
 }
@@ -0,0 +1,863 @@
+package main
+
+import (
+	"blog/internal/config"
+	"blog/internal/database"
+	"blog/internal/memstore"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const testRSS = `<?xml version="1.0"?>
+<rss version="2.0"><channel>
+<title>Test Blog</title>
+<item><title>Older post</title><link>%[1]s/older</link><pubDate>Mon, 05 Oct 2026 10:00:00 +0000</pubDate><category>go</category></item>
+<item><title>Newer post</title><link>%[1]s/newer</link><pubDate>Tue, 06 Oct 2026 10:00:00 +0000</pubDate><author>Ann</author></item>
+</channel></rss>`
+
+// handlerTest runs commands through the registry against an in-memory store,
+// with the config written to a temporary home directory.
+type handlerTest struct {
+	t    *testing.T
+	cmds *Commands
+	s    *State
+	out  bytes.Buffer
+}
+
+// testFetcher reaches the loopback servers the tests serve feeds from.
+var testFetcher = fetcher{client: http.DefaultClient, timeout: defaultFetchTimeout}
+
+func newHandlerTest(t *testing.T) *handlerTest {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GATOR_CONFIG", "")
+	t.Setenv("GATOR_API_KEY", "")
+
+	h := &handlerTest{t: t, cmds: &Commands{}}
+	registerCommands(h.cmds)
+	h.s = &State{
+		sStruct: &config.Config{},
+		db:      memstore.New(),
+		out:     &Output{format: formatJSON, w: &h.out, errW: io.Discard},
+		fetcher: testFetcher,
+	}
+	return h
+}
+
+// run executes a command line, feeding stdin to any password prompts.
+func (h *handlerTest) run(stdin string, args ...string) error {
+	stdinLines = bufio.NewReader(strings.NewReader(stdin))
+	h.out.Reset()
+	return h.cmds.run(h.s, Command{Name: args[0], Args: args[1:]})
+}
+
+func (h *handlerTest) mustRun(stdin string, args ...string) {
+	h.t.Helper()
+	if err := h.run(stdin, args...); err != nil {
+		h.t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+}
+
+// titles runs browse and returns the titles it lists.
+func (h *handlerTest) titles(args ...string) []string {
+	h.t.Helper()
+	h.mustRun("", append([]string{"browse"}, args...)...)
+	var posts []struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &posts); err != nil {
+		h.t.Fatalf("decoding browse output %q: %v", h.out.String(), err)
+	}
+	titles := []string{}
+	for _, p := range posts {
+		titles = append(titles, p.Title)
+	}
+	return titles
+}
+
+func TestHandlersEndToEnd(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer srv.Close()
+
+	h := newHandlerTest(t)
+
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	if err := h.run("hunter2!\nhunter2!\n", "register", "alice"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("registering twice: err = %v", err)
+	}
+	h.mustRun("correct horse\ncorrect horse\n", "register", "bob")
+	if h.s.sStruct.CurrentUser != "bob" {
+		t.Fatalf("current user = %q after register, want bob", h.s.sStruct.CurrentUser)
+	}
+
+	if err := h.run("wrong pass\n", "login", "alice"); err != errWrongPassword {
+		t.Fatalf("login with wrong password: err = %v, want %v", err, errWrongPassword)
+	}
+	h.mustRun("hunter2!\n", "login", "alice")
+	if h.s.sStruct.CurrentUser != "alice" || h.s.sStruct.APIKey == "" {
+		t.Fatalf("config after login = %+v", h.s.sStruct)
+	}
+	aliceKey := h.s.sStruct.APIKey
+
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+	if err := h.run("", "addfeed", "Again", srv.URL+"/rss"); err == nil {
+		t.Fatal("adding the same feed url twice succeeded")
+	}
+	if got := h.titles(); len(got) != 0 {
+		t.Fatalf("browse before agg = %q, want nothing", got)
+	}
+
+	// agg collects one feed per tick; a second tick finds only duplicates
+	scrapeFeeds(h.s)
+	scrapeFeeds(h.s)
+
+	if got, want := h.titles("--limit", "10"), []string{"Newer post", "Older post"}; !slices.Equal(got, want) {
+		t.Errorf("browse = %q, want %q", got, want)
+	}
+	if got, want := h.titles("--limit", "1", "--offset", "1"), []string{"Older post"}; !slices.Equal(got, want) {
+		t.Errorf("browse second page = %q, want %q", got, want)
+	}
+	if got, want := h.titles("--category", "go"), []string{"Older post"}; !slices.Equal(got, want) {
+		t.Errorf("browse --category go = %q, want %q", got, want)
+	}
+	if got, want := h.titles("--author", "ann"), []string{"Newer post"}; !slices.Equal(got, want) {
+		t.Errorf("browse --author ann = %q, want %q", got, want)
+	}
+
+	h.mustRun("", "category", "create", "reading")
+	h.mustRun("", "category", "create", "--parent", "reading", "blogs")
+	if got := h.titles("--folder", "reading"); len(got) != 0 {
+		t.Errorf("browse --folder reading before add = %q", got)
+	}
+	h.mustRun("", "category", "add", srv.URL+"/rss", "blogs")
+	if got := h.titles("--folder", "reading", "--limit", "10"); len(got) != 2 {
+		t.Errorf("browse --folder reading = %q, want the posts of its subcategory", got)
+	}
+	if err := h.run("", "category", "add", srv.URL+"/rss", "nope"); err == nil {
+		t.Error("adding to a missing category succeeded")
+	}
+
+	// bob sees the posts only after following the feed
+	h.mustRun("correct horse\n", "login", "bob")
+	if got := h.titles(); len(got) != 0 {
+		t.Errorf("browse before follow = %q, want nothing", got)
+	}
+	h.mustRun("", "follow", srv.URL+"/rss")
+	if err := h.run("", "follow", srv.URL+"/rss"); err == nil {
+		t.Error("following twice succeeded")
+	}
+	if got := h.titles("--limit", "10"); len(got) != 2 {
+		t.Errorf("browse after follow = %q, want 2 posts", got)
+	}
+	h.mustRun("", "unfollow", srv.URL+"/rss")
+	if got := h.titles(); len(got) != 0 {
+		t.Errorf("browse after unfollow = %q, want nothing", got)
+	}
+
+	// alice's key from the earlier login still works, and the feed is still
+	// filed under alice's category
+	h.mustRun("", "login", "alice", "--key", aliceKey)
+	h.mustRun("", "following")
+	if out := h.out.String(); !strings.Contains(out, `"feed_name": "Test Blog"`) || !strings.Contains(out, `"category": "blogs"`) {
+		t.Errorf("following output = %s", out)
+	}
+}
+
+func TestBrowseNextPage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer srv.Close()
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+	scrapeFeeds(h.s)
+
+	// the hint repeats every filter, with --since as the time it resolved to
+	h.s.out.format = formatTable
+	h.mustRun("", "browse", "--limit", "1", "--feed", srv.URL+"/rss", "--since", "2026-10-01", "--unread")
+	_, hint, ok := strings.Cut(h.out.String(), "Next page: ")
+	if !ok {
+		t.Fatalf("no next page hint in %s", h.out.String())
+	}
+	hint = strings.TrimSpace(hint)
+	for _, want := range []string{"--feed " + srv.URL + "/rss", "--since 2026-10-01T00:00:00Z", "--unread", "--limit 1"} {
+		if !strings.Contains(hint, want) {
+			t.Errorf("hint %q lacks %q", hint, want)
+		}
+	}
+	h.s.out.format = formatJSON
+	if got := h.titles(strings.Fields(hint)[1:]...); !slices.Equal(got, []string{"Older post"}) {
+		t.Errorf("following the hint = %q", got)
+	}
+
+	_, cursor, _ := strings.Cut(hint, "--cursor ")
+	for _, args := range [][]string{{"--cursor", cursor, "--offset", "1"}, {"--cursor", cursor, "--page", "2"}} {
+		if err := h.run("", append([]string{"browse"}, args...)...); err == nil {
+			t.Errorf("browse %q succeeded", args)
+		}
+	}
+	if got := shellQuote("Bob's Blog"); got != `'Bob'\''s Blog'` {
+		t.Errorf("shellQuote = %s", got)
+	}
+}
+
+func TestHandlersRequireLogin(t *testing.T) {
+	h := newHandlerTest(t)
+	if err := h.run("", "browse"); err != errNotLoggedIn {
+		t.Errorf("browse without login: err = %v, want %v", err, errNotLoggedIn)
+	}
+	if err := h.run("", "login", "nobody"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("login of unknown user: err = %v", err)
+	}
+}
+
+// whoami names the owner of the API key, not whoever the config names.
+func TestWhoami(t *testing.T) {
+	h := newHandlerTest(t)
+	h.mustRun("", "whoami")
+	if h.out.Len() != 0 {
+		t.Errorf("whoami without a key printed %q", h.out.String())
+	}
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.s.sStruct.CurrentUser = "mallory"
+	h.mustRun("", "whoami")
+	var rows []struct {
+		Name   string `json:"name"`
+		Prefix string `json:"api_key_prefix"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &rows); err != nil || len(rows) != 1 || rows[0].Name != "alice" ||
+		!strings.HasPrefix(h.s.sStruct.APIKey, apiKeyScheme+rows[0].Prefix) {
+		t.Errorf("whoami = %s, %v", h.out.String(), err)
+	}
+	h.s.sStruct.APIKey = apiKeyScheme + "0123456789abcdef"
+	if err := h.run("", "whoami"); err == nil {
+		t.Error("whoami with an unknown key succeeded")
+	}
+}
+
+// Users from before passwords can't claim their account by choosing a
+// password; they need a token from 'gator migrate reset-tokens'.
+func TestLegacyUserReset(t *testing.T) {
+	h := newHandlerTest(t)
+	ctx := context.Background()
+	user, err := h.s.db.CreateUser(ctx, database.CreateUserParams{
+		ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Name: "carol",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, _, err := createAPIKey(ctx, h.s.db, user, "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.run("mine now!\nmine now!\n", "login", "carol"); err == nil || !strings.Contains(err.Error(), "no password") {
+		t.Fatalf("login without a password: err = %v", err)
+	}
+	if err := h.run("", "login", "carol", "--key", oldKey); err == nil || !strings.Contains(err.Error(), "no password") {
+		t.Fatalf("login with the key of a user without a password: err = %v", err)
+	}
+	t.Setenv("GATOR_API_KEY", oldKey)
+	if err := h.run("mine now!\nmine now!\n", "passwd"); err == nil || !strings.Contains(err.Error(), "no password") {
+		t.Fatalf("passwd without a password: err = %v", err)
+	}
+	t.Setenv("GATOR_API_KEY", "")
+	if err := h.run("guess\nmine now!\nmine now!\n", "reset-password", "carol"); err != errWrongResetToken {
+		t.Fatalf("reset-password without a token: err = %v", err)
+	}
+
+	h.mustRun("", "migrate", "reset-tokens")
+	var tokens []struct {
+		User  string `json:"user"`
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &tokens); err != nil || len(tokens) != 1 || tokens[0].User != "carol" {
+		t.Fatalf("reset-tokens output %s: %v", h.out.String(), err)
+	}
+	token := tokens[0].Token
+
+	if err := h.run("wrong token\ncorrect horse\ncorrect horse\n", "reset-password", "carol"); err != errWrongResetToken {
+		t.Fatalf("reset-password with a wrong token: err = %v", err)
+	}
+	h.mustRun(token+"\ncorrect horse\ncorrect horse\n", "reset-password", "carol")
+	if err := h.run(token+"\nagain again\nagain again\n", "reset-password", "carol"); err != errWrongResetToken {
+		t.Errorf("reusing the reset token: err = %v", err)
+	}
+	if err := h.run("", "login", "carol", "--key", oldKey); err == nil {
+		t.Error("the key from before the reset still logs in")
+	}
+	h.mustRun("correct horse\n", "login", "carol")
+	h.mustRun("", "migrate", "reset-tokens")
+	if h.out.Len() != 0 {
+		t.Errorf("reset-tokens after every user has a password = %s", h.out.String())
+	}
+}
+
+func TestCategoryOPML(t *testing.T) {
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Go Blog", "https://go.dev/blog/feed.atom")
+	h.mustRun("", "addfeed", "Loose", "https://example.com/loose.rss")
+	h.mustRun("", "category", "create", "tech")
+	h.mustRun("", "category", "create", "--parent", "tech", "go")
+	h.mustRun("", "category", "add", "https://go.dev/blog/feed.atom", "go")
+
+	h.mustRun("", "category", "export")
+	var doc opmlDocument
+	if err := xml.Unmarshal(h.out.Bytes(), &doc); err != nil {
+		t.Fatalf("export is not valid XML: %v\n%s", err, h.out.String())
+	}
+	if len(doc.Body) != 2 || doc.Body[0].Text != "tech" || len(doc.Body[0].Outlines) != 1 ||
+		doc.Body[0].Outlines[0].Text != "go" || doc.Body[0].Outlines[0].Outlines[0].XMLURL != "https://go.dev/blog/feed.atom" ||
+		doc.Body[1].XMLURL != "https://example.com/loose.rss" {
+		t.Fatalf("exported outlines = %+v", doc.Body)
+	}
+
+	// bob gets the same tree, plus a feed gator didn't know
+	exported := strings.Replace(h.out.String(), `<outline text="go">`,
+		`<outline text="go"><outline text="New" type="rss" xmlUrl="https://example.com/new.rss"></outline>`, 1)
+	path := filepath.Join(t.TempDir(), "subs.opml")
+	if err := os.WriteFile(path, []byte(exported), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h.mustRun("correct horse\ncorrect horse\n", "register", "bob")
+	h.mustRun("", "category", "import", path)
+	h.mustRun("", "category", "import", path)
+	h.mustRun("", "category", "list")
+	if out := h.out.String(); !strings.Contains(out, `"path": "tech/go"`) {
+		t.Errorf("bob's categories = %s", out)
+	}
+	h.mustRun("", "following")
+	var follows []struct {
+		FeedURL  string `json:"feed_url"`
+		Category string `json:"category"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &follows); err != nil || len(follows) != 3 {
+		t.Fatalf("bob's follows %s: %v", h.out.String(), err)
+	}
+	for _, f := range follows {
+		want := "go"
+		if f.FeedURL == "https://example.com/loose.rss" {
+			want = ""
+		}
+		if f.Category != want {
+			t.Errorf("%s filed under %q, want %q", f.FeedURL, f.Category, want)
+		}
+	}
+
+	// outlines gator can't store are skipped, and the rest still imported
+	writeOPML := func(body string) string {
+		path := filepath.Join(t.TempDir(), "subs.opml")
+		doc := `<opml version="2.0"><head><title>t</title></head><body>` + body + `</body></opml>`
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	h.mustRun("", "category", "import", writeOPML(
+		`<outline text=""><outline text="X" type="rss" xmlUrl="https://example.com/x.rss"></outline></outline>`+
+			`<outline text="a/b"></outline>`+
+			`<outline text="Bad" type="rss" xmlUrl="ftp://example.com/bad"></outline>`+
+			`<outline text="news"><outline text="N" type="rss" xmlUrl="https://example.com/n.rss"></outline></outline>`))
+	h.mustRun("", "following")
+	if out := h.out.String(); !strings.Contains(out, "https://example.com/n.rss") ||
+		strings.Contains(out, "x.rss") || strings.Contains(out, "ftp://") {
+		t.Errorf("follows after importing bad outlines = %s", out)
+	}
+
+	// go is bob's tech/go, so a personal/go fails the import before any change
+	err := h.run("", "category", "import", writeOPML(
+		`<outline text="personal"><outline text="go"><outline text="P" type="rss" xmlUrl="https://example.com/p.rss"></outline></outline></outline>`))
+	if err == nil || !strings.Contains(err.Error(), `"tech/go"`) {
+		t.Fatalf("importing personal/go: err = %v", err)
+	}
+	err = h.run("", "category", "import", writeOPML(
+		`<outline text="a"><outline text="dup"></outline></outline><outline text="b"><outline text="dup"></outline></outline>`))
+	if err == nil || !strings.Contains(err.Error(), `"a/dup"`) {
+		t.Fatalf("importing dup twice: err = %v", err)
+	}
+	h.mustRun("", "category", "list")
+	if out := h.out.String(); strings.Contains(out, "personal") || strings.Contains(out, `"a"`) {
+		t.Errorf("a rejected import left categories behind: %s", out)
+	}
+}
+
+func TestTagsAndNotes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer srv.Close()
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+	scrapeFeeds(h.s)
+
+	h.mustRun("", "browse", "--limit", "10")
+	var posts []struct {
+		ID    string   `json:"id"`
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+		Note  *string  `json:"note"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &posts); err != nil || len(posts) != 2 {
+		t.Fatalf("browse: %v, %s", err, h.out.String())
+	}
+	newer, older := posts[0].ID, posts[1].ID
+
+	h.mustRun("", "tag", newer, "Team-Reading", "#go")
+	h.mustRun("", "tag", older, "go")
+	if err := h.run("", "tag", older, "two words"); err == nil {
+		t.Error("tag with a space accepted")
+	}
+	if err := h.run("", "tag", "not-an-id", "go"); err == nil || !strings.Contains(err.Error(), "invalid post id") {
+		t.Errorf("tag of a bad id: err = %v", err)
+	}
+	if got, want := h.titles("--tag", "team-reading"), []string{"Newer post"}; !slices.Equal(got, want) {
+		t.Errorf("browse --tag team-reading = %q, want %q", got, want)
+	}
+	if got := h.titles("--tag", "go", "--limit", "10"); len(got) != 2 {
+		t.Errorf("browse --tag go = %q", got)
+	}
+	h.mustRun("", "tag", "--remove", newer, "go")
+	if got, want := h.titles("--tag", "go", "--limit", "10"), []string{"Older post"}; !slices.Equal(got, want) {
+		t.Errorf("browse --tag go after remove = %q, want %q", got, want)
+	}
+	h.mustRun("", "tag")
+	if out := h.out.String(); !strings.Contains(out, `"tag": "team-reading"`) || !strings.Contains(out, `"posts": 1`) {
+		t.Errorf("tag list = %s", out)
+	}
+
+	// Without a terminal the note is read from stdin; an empty one deletes it
+	h.mustRun("Worth a read.\n\n- discuss on Friday\n", "note", newer)
+	h.mustRun("", "note", "--print", newer)
+	if got := h.out.String(); got != "Worth a read.\n\n- discuss on Friday\n" {
+		t.Errorf("note --print = %q", got)
+	}
+	h.mustRun("", "browse", "--limit", "1")
+	if err := json.Unmarshal(h.out.Bytes(), &posts); err != nil || posts[0].Note == nil || !slices.Equal(posts[0].Tags, []string{"team-reading"}) {
+		t.Errorf("browse output = %s", h.out.String())
+	}
+	h.mustRun("", "note", newer)
+	if err := h.run("", "note", "--print", newer); err == nil {
+		t.Error("note still there after saving an empty one")
+	}
+
+	// Tags and notes belong to the user who wrote them
+	h.mustRun("correct horse\ncorrect horse\n", "register", "bob")
+	h.mustRun("", "follow", srv.URL+"/rss")
+	if got := h.titles("--tag", "go"); len(got) != 0 {
+		t.Errorf("bob sees alice's tags: %q", got)
+	}
+}
+
+func TestRules(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer srv.Close()
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+
+	for _, args := range [][]string{
+		{"--title-matches", "(", "--action", "hide"},
+		{"--title-matches", "post", "--action", "mute"},
+		{"--title-matches", "post", "--action", "tag:two words"},
+		{"--feed", "Other Blog", "--title-matches", "post", "--action", "hide"},
+		{"--action", "hide"},
+	} {
+		if err := h.run("", append([]string{"rule", "add"}, args...)...); err == nil {
+			t.Errorf("rule add %q succeeded", args)
+		}
+	}
+
+	h.mustRun("", "rule", "add", "--title-matches", "^OLDER", "--action", "hide")
+	h.mustRun("", "rule", "add", "--feed", "Test Blog", "--title-matches", "newer", "--action", "tag:Fresh")
+	h.mustRun("", "rule", "add", "--title-matches", "post$", "--action", "highlight")
+	h.mustRun("", "rule", "list")
+	var rules []struct {
+		ID     string `json:"id"`
+		Feed   string `json:"feed"`
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &rules); err != nil || len(rules) != 3 {
+		t.Fatalf("rule list: %v, %s", err, h.out.String())
+	}
+	if rules[0].Feed != "(all)" || rules[1].Feed != "Test Blog" || rules[1].Action != "tag:fresh" {
+		t.Errorf("rules = %+v", rules)
+	}
+	hide := rules[0].ID
+
+	// Rules act on posts as they are saved
+	scrapeFeeds(h.s)
+	if got, want := h.titles("--limit", "10"), []string{"Newer post"}; !slices.Equal(got, want) {
+		t.Errorf("browse = %q, want the older post hidden", got)
+	}
+	if got := h.titles("--limit", "10", "--hidden"); len(got) != 2 {
+		t.Errorf("browse --hidden = %q", got)
+	}
+	if got, want := h.titles("--tag", "fresh"), []string{"Newer post"}; !slices.Equal(got, want) {
+		t.Errorf("browse --tag fresh = %q, want %q", got, want)
+	}
+	h.mustRun("", "browse")
+	if !strings.Contains(h.out.String(), `"highlighted": true`) {
+		t.Errorf("browse output = %s", h.out.String())
+	}
+
+	h.mustRun("", "rule", "test", hide)
+	if out := h.out.String(); !strings.Contains(out, "Older post") || strings.Contains(out, "Newer post") {
+		t.Errorf("rule test = %s", out)
+	}
+	h.mustRun("", "rule", "test", "--title-matches", "POST")
+	if n := strings.Count(h.out.String(), `"title"`); n != 2 {
+		t.Errorf("rule test --title-matches matched %d posts, want 2", n)
+	}
+
+	// Removing the hide rule shows the posts it hid again, and --existing
+	// applies a new rule to posts already fetched
+	h.mustRun("", "rule", "remove", hide)
+	if got := h.titles("--limit", "10"); len(got) != 2 {
+		t.Errorf("browse after removing the hide rule = %q", got)
+	}
+	h.mustRun("", "rule", "add", "--existing", "--title-matches", "older", "--action", "star")
+	if got, want := h.titles("--starred"), []string{"Older post"}; !slices.Equal(got, want) {
+		t.Errorf("browse --starred = %q, want %q", got, want)
+	}
+	if err := h.run("", "rule", "remove", hide); err == nil {
+		t.Error("removing a rule twice succeeded")
+	}
+}
+
+func TestWatchesAndAlerts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer srv.Close()
+
+	var hooks []alertMessage
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var msg alertMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("webhook body: %v", err)
+		}
+		hooks = append(hooks, msg)
+	}))
+	defer hook.Close()
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+
+	logPath := t.TempDir() + "/alerts.log"
+	h.mustRun("", "watch", "add", "--notify", "local", "newer", "NEWER")
+	h.mustRun("", "watch", "add", "--feed", "Test Blog", "--notify", hook.URL, "posts", `\bpost\b`)
+	h.mustRun("", "watch", "add", "--notify", hook.URL+"/fail", "broken", "older")
+	h.mustRun("", "watch", "add", "quiet", "Newer")
+	for _, args := range [][]string{
+		{"newer", "again"},
+		{"bad", "("},
+		{"--notify", "pager", "paged", "post"},
+		{"--notify", "stdout", "printed", "post"},
+		{"--notify", "exec:touch " + logPath, "cmd", "post"},
+		{"--notify", "file:" + logPath, "log", "post"},
+		{"--feed", "Other Blog", "other", "post"},
+	} {
+		if err := h.run("", append([]string{"watch", "add"}, args...)...); err == nil {
+			t.Errorf("watch add %q succeeded", args)
+		}
+	}
+
+	// Local watches of other users are recorded but don't reach the
+	// operator's alert_notify
+	h.mustRun("correct horse\ncorrect horse\n", "register", "bob")
+	h.mustRun("", "follow", srv.URL+"/rss")
+	h.mustRun("", "watch", "add", "--notify", "local", "bobs", "NEWER")
+	h.mustRun("hunter2!\n", "login", "alice")
+	if err := h.run("", "config", "set", "alert_notify", "local"); err == nil {
+		t.Error("alert_notify local succeeded")
+	}
+	h.mustRun("", "config", "set", "alert_notify", "stdout")
+	h.s.sStruct.AlertNotify = "file:" + logPath
+	local, err := loadLocalNotifier(context.Background(), h.s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failing notifier is reported, but its alert is still recorded
+	feed, err := h.s.db.GetFeedByURL(context.Background(), srv.URL+"/rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scrapeFeed(h.s.db, h.s.fetcher, feed, local); err == nil || !strings.Contains(err.Error(), "watch broken") {
+		t.Errorf("scrapeFeed err = %v, want the failing webhook to be reported", err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil || strings.Count(string(data), "\n") != 1 || !strings.Contains(string(data), "[newer] Test Blog: Newer post <"+srv.URL+"/newer>") {
+		t.Errorf("alert log = %q, %v", data, err)
+	}
+	if len(hooks) != 2 || hooks[0].Watch != "posts" || hooks[0].Feed != "Test Blog" || hooks[0].PublishedAt == nil {
+		t.Errorf("webhook got %+v", hooks)
+	}
+
+	listAlerts := func(args ...string) []struct {
+		ID    string `json:"id"`
+		Watch string `json:"watch"`
+		Title string `json:"title"`
+	} {
+		t.Helper()
+		h.mustRun("", append([]string{"alerts"}, args...)...)
+		var alerts []struct {
+			ID    string `json:"id"`
+			Watch string `json:"watch"`
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal(h.out.Bytes(), &alerts); err != nil {
+			t.Fatalf("alerts output %q: %v", h.out.String(), err)
+		}
+		return alerts
+	}
+	alerts := listAlerts()
+	if len(alerts) != 5 {
+		t.Fatalf("alerts = %+v, want 5", alerts)
+	}
+	if err := h.run("", "watch", "test", "quiet"); err == nil {
+		t.Error("watch test of a record-only watch succeeded")
+	}
+	h.mustRun("", "watch", "remove", "quiet")
+	if alerts = listAlerts(); len(alerts) != 4 {
+		t.Fatalf("alerts after removing quiet = %+v, want 4", alerts)
+	}
+
+	h.mustRun("", "alerts", "ack", alerts[0].ID)
+	if err := h.run("", "alerts", "ack", alerts[0].ID); err == nil {
+		t.Error("acknowledging an alert twice succeeded")
+	}
+	if got := listAlerts(); len(got) != 3 {
+		t.Errorf("alerts after ack = %+v", got)
+	}
+	h.mustRun("", "watch", "list")
+	if !strings.Contains(h.out.String(), `"unacknowledged": 1`) {
+		t.Errorf("watch list = %s", h.out.String())
+	}
+	h.mustRun("", "alerts", "ack", "all")
+	if got := listAlerts(); len(got) != 0 {
+		t.Errorf("alerts after ack all = %+v", got)
+	}
+	if got := listAlerts("--acknowledged"); len(got) != 4 {
+		t.Errorf("alerts --acknowledged = %+v", got)
+	}
+
+	// Removing a watch removes its alerts
+	h.mustRun("", "watch", "remove", "posts")
+	if got := listAlerts("--acknowledged"); len(got) != 2 {
+		t.Errorf("alerts after removing a watch = %+v", got)
+	}
+	if err := h.run("", "watch", "remove", "posts"); err == nil {
+		t.Error("removing a watch twice succeeded")
+	}
+
+	h.mustRun("", "watch", "test", "newer")
+	if data, _ := os.ReadFile(logPath); !strings.Contains(string(data), "Test alert for newer") {
+		t.Errorf("watch test didn't notify: %q", data)
+	}
+
+	// Watches also look at the text of the description
+	watch, err := compileWatch(database.Watch{Name: "cve", Pattern: `CVE-\d{4}-\d+`, Notify: recordOnly}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := sql.NullString{String: "<p>Fixes <b>CVE-2026-1234</b></p>", Valid: true}
+	if !watch.matches(database.Post{Title: "Security release", Description: desc}) {
+		t.Error("watch didn't match the description")
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer srv.Close()
+
+	// receiver checks signatures against the secret of the webhook it serves
+	type receiver struct {
+		url, secret string
+		fail        bool
+		mu          sync.Mutex
+		posts       []string
+	}
+	newReceiver := func(secret string, fail bool) *receiver {
+		rcv := &receiver{secret: secret, fail: fail}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			timestamp := r.Header.Get("X-Gator-Timestamp")
+			if sent, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+				t.Errorf("X-Gator-Timestamp = %q", timestamp)
+			}
+			if got := r.Header.Get("X-Gator-Signature"); got != signWebhook(rcv.secret, timestamp, body) {
+				t.Errorf("signature %q doesn't match the timestamp and body", got)
+			}
+			if rcv.fail {
+				http.Error(w, "down", http.StatusInternalServerError)
+				return
+			}
+			var payload webhookPayload
+			if err := json.Unmarshal(body, &payload); err != nil || payload.Event != "post.created" || payload.Feed.Name != "Test Blog" {
+				t.Errorf("payload %s: %v", body, err)
+			}
+			rcv.mu.Lock()
+			rcv.posts = append(rcv.posts, payload.Post.Title)
+			rcv.mu.Unlock()
+		}))
+		t.Cleanup(ts.Close)
+		rcv.url = ts.URL
+		return rcv
+	}
+	scoped, all, unused := newReceiver("", false), newReceiver("s3cret", true), newReceiver("", false)
+	old := webhookMaxAttempts
+	webhookMaxAttempts = 2
+	t.Cleanup(func() { webhookMaxAttempts = old })
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+	h.mustRun("", "category", "create", "tech")
+	h.mustRun("", "category", "create", "--parent", "tech", "go")
+	h.mustRun("", "category", "create", "empty")
+	h.mustRun("", "category", "add", srv.URL+"/rss", "go")
+
+	// add prints the id and secret; receivers without a --secret learn theirs
+	// from it
+	add := func(rcv *receiver, args ...string) string {
+		t.Helper()
+		if rcv.secret != "" {
+			args = append(args, "--secret", rcv.secret)
+		}
+		h.mustRun("", append(append([]string{"webhook", "add"}, args...), rcv.url)...)
+		fields := strings.Fields(h.out.String())
+		rcv.secret = fields[6]
+		return strings.TrimSuffix(fields[2], ",")
+	}
+	add(scoped, "--folder", "tech")
+	allID := add(all)
+	add(unused, "--folder", "empty")
+	if all.secret != "s3cret" {
+		t.Errorf("--secret printed %q", all.secret)
+	}
+	for _, args := range [][]string{
+		{"ftp://example.com/hook"},
+		{"--folder", "nope", "https://example.com/hook"},
+		{"--feed", "Other Blog", "https://example.com/hook"},
+	} {
+		if err := h.run("", append([]string{"webhook", "add"}, args...)...); err == nil {
+			t.Errorf("webhook add %q succeeded", args)
+		}
+	}
+
+	deliveries := func(args ...string) []struct {
+		ID             string `json:"id"`
+		Status         string `json:"status"`
+		Attempts       int    `json:"attempts"`
+		ResponseStatus *int   `json:"response_status"`
+	} {
+		t.Helper()
+		h.mustRun("", append([]string{"webhook", "deliveries"}, args...)...)
+		var rows []struct {
+			ID             string `json:"id"`
+			Status         string `json:"status"`
+			Attempts       int    `json:"attempts"`
+			ResponseStatus *int   `json:"response_status"`
+		}
+		if err := json.Unmarshal(h.out.Bytes(), &rows); err != nil {
+			t.Fatalf("deliveries output %q: %v", h.out.String(), err)
+		}
+		return rows
+	}
+
+	// agg queues and sends in the same run; the category webhook covers
+	// subcategories, and failures are retried later
+	scrapeFeeds(h.s)
+	if len(scoped.posts) != 2 || len(all.posts) != 0 || len(unused.posts) != 0 {
+		t.Fatalf("received %q, %q, %q", scoped.posts, all.posts, unused.posts)
+	}
+	delivered := deliveries("--status", "delivered")
+	if len(delivered) != 2 || delivered[0].Attempts != 1 || *delivered[0].ResponseStatus != 200 {
+		t.Errorf("delivered = %+v", delivered)
+	}
+	pending := deliveries("--status", "pending")
+	if len(pending) != 2 || pending[0].Attempts != 1 || *pending[0].ResponseStatus != 500 {
+		t.Errorf("pending = %+v", pending)
+	}
+
+	ctx := context.Background()
+	if sent, failed, err := deliverWebhooks(ctx, h.s.db, h.s.fetcher.client, time.Now()); sent+failed != 0 || err != nil {
+		t.Errorf("deliveries retried before their backoff: %d, %d, %v", sent, failed, err)
+	}
+	if _, failed, err := deliverWebhooks(ctx, h.s.db, h.s.fetcher.client, time.Now().Add(webhookBackoff)); failed != 2 || err != nil {
+		t.Errorf("retry: %d failed, %v", failed, err)
+	}
+	failed := deliveries("--status", "failed")
+	if len(failed) != 2 || failed[0].Attempts != 2 {
+		t.Fatalf("failed = %+v", failed)
+	}
+
+	all.fail = false
+	h.mustRun("", "webhook", "retry", failed[0].ID)
+	if err := h.run("", "webhook", "retry", delivered[0].ID); err == nil {
+		t.Error("retrying a delivery that didn't fail succeeded")
+	}
+
+	// Deliveries not sent before the deadline go back to pending as they were
+	webhookDeadline = 0
+	if sent, failed, err := deliverWebhooks(ctx, h.s.db, h.s.fetcher.client, time.Now()); sent+failed != 0 || err != nil {
+		t.Errorf("past the deadline: %d sent, %d failed, %v", sent, failed, err)
+	}
+	webhookDeadline = time.Minute
+	if pending := deliveries("--status", "pending"); len(pending) != 1 || pending[0].Attempts != 2 {
+		t.Errorf("released = %+v", pending)
+	}
+	if sent, _, err := deliverWebhooks(ctx, h.s.db, h.s.fetcher.client, time.Now()); sent != 1 || err != nil || len(all.posts) != 1 {
+		t.Errorf("manual retry: %d sent, %v, received %q", sent, err, all.posts)
+	}
+
+	h.mustRun("", "webhook", "remove", allID)
+	if got := deliveries(); len(got) != 2 {
+		t.Errorf("deliveries after removing a webhook = %+v", got)
+	}
+	h.mustRun("", "webhook", "list")
+	if n := strings.Count(h.out.String(), `"url"`); n != 2 {
+		t.Errorf("webhook list = %s", h.out.String())
+	}
+
+	for attempts, want := range map[int]time.Duration{1: webhookBackoff, 3: 4 * webhookBackoff, 30: webhookMaxBackoff} {
+		if got := webhookRetryDelay(attempts); got != want {
+			t.Errorf("retry delay after %d attempts = %s, want %s", attempts, got, want)
+		}
+	}
+}
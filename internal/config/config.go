@@ -2,17 +2,90 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
+// DefaultProfile is the profile stored at the top level of the file, where
+// configs from before profiles keep their settings.
+const DefaultProfile = "default"
+
+// Profile is one database with the user logged in to it.
+type Profile struct {
+	DBURL         string `json:"db_url"`
+	CurrentUser   string `json:"current_user_name"`
+	APIKey        string `json:"api_key,omitempty"`
+	FetchInterval string `json:"fetch_interval,omitempty"`
+	FetchTimeout  string `json:"fetch_timeout,omitempty"`
+	SMTPAddr      string `json:"smtp_addr,omitempty"`
+	SMTPUsername  string `json:"smtp_username,omitempty"`
+	SMTPPassword  string `json:"smtp_password,omitempty"`
+	SMTPFrom      string `json:"smtp_from,omitempty"`
+	AlertNotify   string `json:"alert_notify,omitempty"`
+}
+
+// Config is the active profile, with environment overrides applied.
 type Config struct {
-	DBURL       string `json:"db_url"`
-	CurrentUser string `json:"current_user_name"`
+	Profile
+	Name string
+
+	path string
+	// file holds the values as stored on disk. Writes start from it, so
+	// environment overrides never end up in the file.
+	file *fileConfig
+	// env maps each overridden key to the variable that set it.
+	env map[string]string
+}
+
+type fileConfig struct {
+	Profile
+	Active   string              `json:"profile,omitempty"`
+	Profiles map[string]*Profile `json:"profiles,omitempty"`
+}
+
+// profile returns the stored profile called name, or nil.
+func (f *fileConfig) profile(name string) *Profile {
+	if name == DefaultProfile || name == "" {
+		return &f.Profile
+	}
+	return f.Profiles[name]
+}
+
+// Key describes a setting that `gator config` can get and set.
+type Key struct {
+	Name string
+	Env  string // variable that overrides the file, if any
+}
+
+var Keys = []Key{
+	{Name: "db_url", Env: "GATOR_DB_URL"},
+	{Name: "current_user_name", Env: "GATOR_USER"},
+	{Name: "api_key", Env: "GATOR_API_KEY"},
+	{Name: "fetch_interval"},
+	{Name: "fetch_timeout"},
+	{Name: "smtp_addr"},
+	{Name: "smtp_username"},
+	{Name: "smtp_password", Env: "GATOR_SMTP_PASSWORD"},
+	{Name: "smtp_from"},
+	{Name: "alert_notify"},
 }
 
-func configPath() (string, error) {
+// placeholderDBURL is what older versions wrote when there was no config.
+const placeholderDBURL = "connection_string_goes_here"
+
+// Path returns the config file to use: the given path (from --config), else
+// $GATOR_CONFIG, else ~/.gatorconfig.json.
+func Path(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	if path := os.Getenv("GATOR_CONFIG"); path != "" {
+		return path, nil
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -20,44 +93,317 @@ func configPath() (string, error) {
 	return filepath.Join(home, ".gatorconfig.json"), nil
 }
 
-func Read() (*Config, error) {
-	path, err := configPath()
+// Read loads the config file at path (see Path) and applies the environment
+// overrides to the selected profile: the given one (from --profile), else
+// $GATOR_PROFILE, else the one picked with `gator profile use`. A missing
+// file or profile is not an error; RequireDBURL reports it when a command
+// needs the database.
+func Read(path, profile string) (*Config, error) {
+	path, err := Path(path)
 	if err != nil {
 		return nil, err
 	}
 
+	file := &fileConfig{}
 	data, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		return &Config{
-			DBURL:       "connection_string_goes_here",
-			CurrentUser: "username_goes here",
-		}, nil
-	}
-	if err != nil {
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return nil, err
 	}
+	if err == nil {
+		if err := json.Unmarshal(data, file); err != nil {
+			return nil, fmt.Errorf("failed to decode config %s: %w", path, err)
+		}
+	}
+
+	if profile == "" {
+		profile = os.Getenv("GATOR_PROFILE")
+	}
+	if profile == "" {
+		profile = file.Active
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+
+	cfg := &Config{Name: profile, path: path, file: file, env: make(map[string]string)}
+	if p := file.profile(profile); p != nil {
+		cfg.Profile = *p
+	}
+	for _, key := range Keys {
+		if value := os.Getenv(key.Env); key.Env != "" && value != "" {
+			*cfg.field(key.Name) = value
+			cfg.env[key.Name] = key.Env
+		}
+	}
+	return cfg, nil
+}
+
+// File returns the path the config is read from and written to.
+func (c *Config) File() string {
+	if c.path == "" {
+		c.path, _ = Path("")
+	}
+	return c.path
+}
+
+// Exists reports whether the config file is there.
+func (c *Config) Exists() bool {
+	_, err := os.Stat(c.File())
+	return err == nil
+}
+
+// stored returns the file contents. A Config that wasn't Read starts a file
+// with its own values as the default profile.
+func (c *Config) stored() *fileConfig {
+	if c.file == nil {
+		c.file = &fileConfig{}
+		if p := c.file.profile(c.Name); p != nil {
+			*p = c.Profile
+		}
+	}
+	return c.file
+}
+
+// storedProfile returns the profile in use as stored in the file, or nil if
+// the file doesn't have it.
+func (c *Config) storedProfile() *Profile {
+	return c.stored().profile(c.Name)
+}
+
+// RequireDBURL explains how to configure the database when it isn't.
+func (c *Config) RequireDBURL() error {
+	if c.DBURL != "" && c.DBURL != placeholderDBURL {
+		return nil
+	}
+	if c.storedProfile() == nil {
+		return fmt.Errorf("no profile %q in %s: see 'gator profile list'", c.Name, c.File())
+	}
+	if !c.Exists() {
+		return fmt.Errorf("no config file at %s: run 'gator config init <db_url>' or set GATOR_DB_URL", c.File())
+	}
+	return fmt.Errorf("db_url is not set for profile %q in %s: run 'gator config set db_url <url>' or set GATOR_DB_URL", c.Name, c.File())
+}
+
+func (p *Profile) field(key string) *string {
+	switch key {
+	case "db_url":
+		return &p.DBURL
+	case "current_user_name":
+		return &p.CurrentUser
+	case "api_key":
+		return &p.APIKey
+	case "fetch_interval":
+		return &p.FetchInterval
+	case "fetch_timeout":
+		return &p.FetchTimeout
+	case "smtp_addr":
+		return &p.SMTPAddr
+	case "smtp_username":
+		return &p.SMTPUsername
+	case "smtp_password":
+		return &p.SMTPPassword
+	case "smtp_from":
+		return &p.SMTPFrom
+	case "alert_notify":
+		return &p.AlertNotify
+	}
+	return nil
+}
+
+func unknownKey(key string) error {
+	names := make([]string, len(Keys))
+	for i, k := range Keys {
+		names[i] = k.Name
+	}
+	return fmt.Errorf("unknown config key %q: expected %s", key, strings.Join(names, ", "))
+}
+
+// Get returns the value of key, including environment overrides.
+func (c *Config) Get(key string) (string, error) {
+	f := c.field(key)
+	if f == nil {
+		return "", unknownKey(key)
+	}
+	return *f, nil
+}
+
+// Source says where the value of key comes from: the variable overriding
+// it, "file", or "" when it is unset.
+func (c *Config) Source(key string) string {
+	if env, ok := c.env[key]; ok {
+		return env
+	}
+	if p := c.storedProfile(); p != nil && *p.field(key) != "" {
+		return "file"
+	}
+	return ""
+}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to decode config: %w", err)
+// Set stores a value in the profile in use.
+func (c *Config) Set(key, value string) error {
+	if c.field(key) == nil {
+		return unknownKey(key)
 	}
-	return &cfg, nil
+	return c.update(func(p *Profile) { *p.field(key) = value })
 }
 
 func (c *Config) SetUser(username string) error {
-	c.CurrentUser = username
+	return c.update(func(p *Profile) { p.CurrentUser = username })
+}
 
-	path, err := configPath()
-	if err != nil {
+// SetLogin stores the user together with the API key that authenticates
+// them, adding the profile in use to the file if it isn't there yet.
+func (c *Config) SetLogin(username, apiKey string) error {
+	return c.createOrUpdate(func(p *Profile) {
+		p.CurrentUser = username
+		p.APIKey = apiKey
+	})
+}
+
+// Init stores dbURL in the profile in use, adding the profile to the file if
+// it isn't there yet.
+func (c *Config) Init(dbURL string) error {
+	return c.createOrUpdate(func(p *Profile) { p.DBURL = dbURL })
+}
+
+// createOrUpdate is update for the commands run on a machine that doesn't
+// know the profile yet, `config init` and `login`: they add it to the file
+// rather than send the user to `gator profile add` first.
+func (c *Config) createOrUpdate(fn func(*Profile)) error {
+	if c.storedProfile() == nil {
+		if err := validProfileName(c.Name); err != nil {
+			return err
+		}
+		file := c.stored()
+		if file.Profiles == nil {
+			file.Profiles = make(map[string]*Profile)
+		}
+		file.Profiles[c.Name] = &Profile{}
+	}
+	return c.update(fn)
+}
+
+// update applies fn to the profile in use, both in the file and in c, and
+// writes the file. Other profiles are left alone.
+func (c *Config) update(fn func(*Profile)) error {
+	stored := c.storedProfile()
+	if stored == nil {
+		return fmt.Errorf("no profile %q in %s: add it with 'gator profile add'", c.Name, c.File())
+	}
+	fn(&c.Profile)
+	fn(stored)
+	return c.write()
+}
+
+// Profiles lists the profile names, the default one first.
+func (c *Config) Profiles() []string {
+	names := []string{DefaultProfile}
+	for name := range c.stored().Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// StoredProfile returns a profile as stored in the file, without
+// environment overrides.
+func (c *Config) StoredProfile(name string) (Profile, bool) {
+	if p := c.stored().profile(name); p != nil {
+		return *p, true
+	}
+	return Profile{}, false
+}
+
+// ActiveProfile is the profile used when neither --profile nor
+// GATOR_PROFILE picks one.
+func (c *Config) ActiveProfile() string {
+	if active := c.stored().Active; active != "" {
+		return active
+	}
+	return DefaultProfile
+}
+
+// AddProfile stores a new profile for the database at dbURL.
+func (c *Config) AddProfile(name, dbURL string) error {
+	file := c.stored()
+	if err := validProfileName(name); err != nil {
 		return err
 	}
+	if file.profile(name) != nil {
+		return fmt.Errorf("profile %q already exists", name)
+	}
+	if file.Profiles == nil {
+		file.Profiles = make(map[string]*Profile)
+	}
+	file.Profiles[name] = &Profile{DBURL: dbURL}
+	return c.write()
+}
+
+func validProfileName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	return nil
+}
+
+// UseProfile makes name the active profile.
+func (c *Config) UseProfile(name string) error {
+	file := c.stored()
+	if file.profile(name) == nil {
+		return fmt.Errorf("no profile %q: see 'gator profile list'", name)
+	}
+	file.Active = name
+	if name == DefaultProfile {
+		file.Active = ""
+	}
+	return c.write()
+}
+
+// RemoveProfile deletes a profile. The default profile can't be removed;
+// if the active one is, the default becomes active again.
+func (c *Config) RemoveProfile(name string) error {
+	file := c.stored()
+	if name == DefaultProfile {
+		return fmt.Errorf("the %s profile can't be removed", DefaultProfile)
+	}
+	if file.profile(name) == nil {
+		return fmt.Errorf("no profile %q: see 'gator profile list'", name)
+	}
+	delete(file.Profiles, name)
+	if file.Active == name {
+		file.Active = ""
+	}
+	return c.write()
+}
 
-	data, err := json.MarshalIndent(c, "", " ")
+// write replaces the file atomically, so a crash never leaves it half
+// written, and keeps it private since db_url can hold a password.
+func (c *Config) write() error {
+	path := c.File()
+
+	data, err := json.MarshalIndent(c.stored(), "", " ")
 	if err != nil {
 		return fmt.Errorf("failed to encode config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gatorconfig-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 	return nil
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOverridesAreNotSaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gator.json")
+	if err := os.WriteFile(path, []byte(`{"db_url":"postgres://file","current_user_name":"alice"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GATOR_PROFILE", "")
+	t.Setenv("GATOR_DB_URL", "sqlite://env.db")
+	t.Setenv("GATOR_USER", "")
+	t.Setenv("GATOR_API_KEY", "")
+
+	cfg, err := Read(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBURL != "sqlite://env.db" || cfg.Source("db_url") != "GATOR_DB_URL" {
+		t.Errorf("db_url = %q from %q, want the override", cfg.DBURL, cfg.Source("db_url"))
+	}
+	if cfg.CurrentUser != "alice" || cfg.Source("current_user_name") != "file" {
+		t.Errorf("current_user_name = %q from %q", cfg.CurrentUser, cfg.Source("current_user_name"))
+	}
+
+	if err := cfg.SetLogin("bob", "gtr_key"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CurrentUser != "bob" {
+		t.Errorf("current user after SetLogin = %q", cfg.CurrentUser)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]string
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"db_url": "postgres://file", "current_user_name": "bob", "api_key": "gtr_key"}
+	for k, v := range want {
+		if saved[k] != v {
+			t.Errorf("saved %s = %q, want %q", k, saved[k], v)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("config permissions = %o, want 600", perm)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestRequireDBURL(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GATOR_PROFILE", "")
+	t.Setenv("GATOR_DB_URL", "")
+
+	cfg, err := Read(filepath.Join(dir, "missing.json"), "")
+	if err != nil {
+		t.Fatalf("a missing config must not be an error: %v", err)
+	}
+	if err := cfg.RequireDBURL(); err == nil {
+		t.Error("RequireDBURL accepted a missing config")
+	}
+
+	path := filepath.Join(dir, "old.json")
+	if err := os.WriteFile(path, []byte(`{"db_url":"connection_string_goes_here"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = Read(path, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.RequireDBURL(); err == nil {
+		t.Error("RequireDBURL accepted the placeholder db_url")
+	}
+
+	if err := cfg.Set("db_url", "sqlite://gator.db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.RequireDBURL(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("GATOR_CONFIG", "/etc/gator.json")
+	if got, _ := Path(""); got != "/etc/gator.json" {
+		t.Errorf("Path with GATOR_CONFIG = %q", got)
+	}
+	if got, _ := Path("./flag.json"); got != "./flag.json" {
+		t.Errorf("--config must win over GATOR_CONFIG, got %q", got)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gator.json")
+	if err := os.WriteFile(path, []byte(`{"db_url":"sqlite://personal.db","current_user_name":"alice"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GATOR_PROFILE", "")
+	t.Setenv("GATOR_DB_URL", "")
+	t.Setenv("GATOR_USER", "")
+	t.Setenv("GATOR_API_KEY", "")
+
+	cfg, err := Read(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.AddProfile("team", "postgres://team"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.AddProfile("team", "postgres://other"); err == nil {
+		t.Error("adding a profile twice succeeded")
+	}
+	if err := cfg.UseProfile("team"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The active profile is used unless --profile or GATOR_PROFILE picks one
+	team, err := Read(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Name != "team" || team.DBURL != "postgres://team" || team.CurrentUser != "" {
+		t.Fatalf("active profile = %s %+v", team.Name, team.Profile)
+	}
+	if err := team.SetUser("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GATOR_PROFILE", DefaultProfile)
+	personal, err := Read(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if personal.Name != DefaultProfile || personal.CurrentUser != "alice" {
+		t.Errorf("SetUser changed another profile: %s %+v", personal.Name, personal.Profile)
+	}
+	if flag, _ := Read(path, "team"); flag.CurrentUser != "bob" {
+		t.Errorf("--profile team: current user = %q, want bob", flag.CurrentUser)
+	}
+
+	if err := personal.RemoveProfile(DefaultProfile); err == nil {
+		t.Error("removing the default profile succeeded")
+	}
+	if err := personal.RemoveProfile("team"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GATOR_PROFILE", "")
+	after, _ := Read(path, "")
+	if after.Name != DefaultProfile || len(after.Profiles()) != 1 {
+		t.Errorf("after removing the active profile: %s, profiles %q", after.Name, after.Profiles())
+	}
+
+	missing, _ := Read(path, "nope")
+	if err := missing.RequireDBURL(); err == nil || !strings.Contains(err.Error(), `no profile "nope"`) {
+		t.Errorf("RequireDBURL for a missing profile: %v", err)
+	}
+	if err := missing.SetUser("carol"); err == nil {
+		t.Error("SetUser created a missing profile")
+	}
+
+	// config init and login on a machine without the profile add it
+	if err := missing.Init("sqlite://nope.db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := missing.SetLogin("carol", "gtr_key"); err != nil {
+		t.Fatal(err)
+	}
+	if nope, _ := Read(path, "nope"); nope.DBURL != "sqlite://nope.db" || nope.CurrentUser != "carol" || nope.APIKey != "gtr_key" {
+		t.Errorf("created profile = %+v", nope.Profile)
+	}
+	fresh, _ := Read(filepath.Join(t.TempDir(), "gator.json"), "work")
+	if err := fresh.SetLogin("dave", "gtr_key"); err != nil {
+		t.Fatal(err)
+	}
+	if work, _ := Read(fresh.File(), "work"); work.CurrentUser != "dave" {
+		t.Errorf("login on a fresh machine: %+v", work.Profile)
+	}
+}
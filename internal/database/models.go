@@ -11,14 +11,41 @@ import (
 	"github.com/google/uuid"
 )
 
+type Alert struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	WatchID        uuid.UUID
+	PostID         uuid.UUID
+	AcknowledgedAt sql.NullTime
+}
+
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type DigestSetting struct {
+	UserID     uuid.UUID
+	Email      string
+	Schedule   string
+	LastSentAt sql.NullTime
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.NullUUID
-	LastFetchedAt sql.NullTime
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Name             string
+	Url              string
+	UserID           uuid.NullUUID
+	LastFetchedAt    sql.NullTime
+	FetchFullContent bool
 }
 
 type FeedFollow struct {
@@ -29,6 +56,37 @@ type FeedFollow struct {
 	FeedID    uuid.UUID
 }
 
+type FeedToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	TokenHash  string
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type Folder struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	ParentID  uuid.NullUUID
+}
+
+type FolderFeed struct {
+	UserID   uuid.UUID
+	FeedID   uuid.UUID
+	FolderID uuid.UUID
+}
+
+type PasswordReset struct {
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+}
+
 type Post struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
@@ -38,11 +96,89 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Author      sql.NullString
+	Categories  []string
+	Content     sql.NullString
+}
+
+type PostNote struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Body      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type PostState struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	ReadAt    sql.NullTime
+	StarredAt sql.NullTime
+	HiddenAt  sql.NullTime
+}
+
+type PostTag struct {
+	TagID     uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Rule struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	FeedID       uuid.NullUUID
+	TitlePattern string
+	Action       string
+}
+
+type Tag struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
 }
 
 type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
+	FailedLogins int32
+	LockedUntil  sql.NullTime
+}
+
+type Watch struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.NullUUID
 	Name      string
+	Pattern   string
+	Notify    string
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	FeedID    uuid.NullUUID
+	FolderID  uuid.NullUUID
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	WebhookID      uuid.UUID
+	PostID         uuid.UUID
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	LastError      sql.NullString
+	ResponseStatus sql.NullInt32
 }
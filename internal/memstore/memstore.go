@@ -0,0 +1,1566 @@
+// Package memstore is an in-memory database.Querier. It mirrors what the SQL
+// queries do closely enough for handler tests, including unique constraints,
+// cascading deletes and the browse filters, without needing a database.
+package memstore
+
+import (
+	"blog/internal/database"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrDuplicate is returned where the database would report a unique
+// constraint violation.
+var ErrDuplicate = errors.New("duplicate key value violates unique constraint")
+
+type stateKey struct {
+	userID, postID uuid.UUID
+}
+
+type followKey struct {
+	userID, feedID uuid.UUID
+}
+
+// Store holds every table in slices, in insertion order.
+type Store struct {
+	mu      sync.Mutex
+	users   []database.User
+	feeds   []database.Feed
+	follows []database.FeedFollow
+	posts   []database.Post
+	states  map[stateKey]database.PostState
+	apiKeys []database.ApiKey
+	folders []database.Folder
+	// folderFeeds is keyed by user and feed, like the follow it belongs to
+	folderFeeds map[followKey]uuid.UUID
+	tags        []database.Tag
+	postTags    []database.PostTag
+	notes       map[stateKey]database.PostNote
+	rules       []database.Rule
+	watches     []database.Watch
+	alerts      []database.Alert
+	webhooks    []database.Webhook
+	deliveries  []database.WebhookDelivery
+	digests     map[uuid.UUID]database.DigestSetting
+	resets      map[uuid.UUID]database.PasswordReset
+	feedTokens  []database.FeedToken
+}
+
+var _ database.Querier = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		states:      make(map[stateKey]database.PostState),
+		folderFeeds: make(map[followKey]uuid.UUID),
+		notes:       make(map[stateKey]database.PostNote),
+		digests:     make(map[uuid.UUID]database.DigestSetting),
+		resets:      make(map[uuid.UUID]database.PasswordReset),
+	}
+}
+
+func now() sql.NullTime {
+	return sql.NullTime{Time: time.Now(), Valid: true}
+}
+
+// The find helpers return indexes, or -1, and expect s.mu to be held.
+
+func (s *Store) findUser(match func(database.User) bool) int {
+	return slices.IndexFunc(s.users, match)
+}
+
+func (s *Store) findFeed(match func(database.Feed) bool) int {
+	return slices.IndexFunc(s.feeds, match)
+}
+
+func (s *Store) postByID(id uuid.UUID) int {
+	return slices.IndexFunc(s.posts, func(p database.Post) bool { return p.ID == id })
+}
+
+func (s *Store) userByID(id uuid.UUID) int {
+	return s.findUser(func(u database.User) bool { return u.ID == id })
+}
+
+func (s *Store) feedByID(id uuid.UUID) int {
+	return s.findFeed(func(f database.Feed) bool { return f.ID == id })
+}
+
+func (s *Store) CountAPIKeysForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, k := range s.apiKeys {
+		if k.UserID == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, arg database.CreateAPIKeyParams) (database.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(arg.UserID) < 0 {
+		return database.ApiKey{}, fmt.Errorf("api key for unknown user %s", arg.UserID)
+	}
+	for _, k := range s.apiKeys {
+		if k.ID == arg.ID || k.KeyHash == arg.KeyHash {
+			return database.ApiKey{}, ErrDuplicate
+		}
+	}
+	k := database.ApiKey{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		KeyHash:   arg.KeyHash,
+	}
+	s.apiKeys = append(s.apiKeys, k)
+	return k, nil
+}
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.CreateFeedRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if arg.UserID.Valid && s.userByID(arg.UserID.UUID) < 0 {
+		return database.CreateFeedRow{}, fmt.Errorf("feed for unknown user %s", arg.UserID.UUID)
+	}
+	if s.findFeed(func(f database.Feed) bool { return f.ID == arg.ID || f.Url == arg.Url }) >= 0 {
+		return database.CreateFeedRow{}, ErrDuplicate
+	}
+	s.feeds = append(s.feeds, database.Feed{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+		Url:       arg.Url,
+		UserID:    arg.UserID,
+	})
+	return database.CreateFeedRow(arg), nil
+}
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.userByID(arg.UserID)
+	f := s.findFeed(func(f database.Feed) bool { return f.Url == arg.Url })
+	if u < 0 || f < 0 {
+		return database.CreateFeedFollowRow{}, fmt.Errorf("follow of unknown user or feed")
+	}
+	feed := s.feeds[f]
+	for _, ff := range s.follows {
+		if ff.UserID == arg.UserID && ff.FeedID == feed.ID {
+			return database.CreateFeedFollowRow{}, ErrDuplicate
+		}
+	}
+
+	t := time.Now()
+	follow := database.FeedFollow{ID: uuid.New(), CreatedAt: t, UpdatedAt: t, UserID: arg.UserID, FeedID: feed.ID}
+	s.follows = append(s.follows, follow)
+	return database.CreateFeedFollowRow{
+		ID:        follow.ID,
+		CreatedAt: follow.CreatedAt,
+		UpdatedAt: follow.UpdatedAt,
+		UserID:    follow.UserID,
+		FeedID:    follow.FeedID,
+		UserName:  s.users[u].Name,
+		FeedName:  feed.Name,
+	}, nil
+}
+
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.feedByID(arg.FeedID) < 0 {
+		return database.Post{}, fmt.Errorf("post for unknown feed %s", arg.FeedID)
+	}
+	if arg.Categories == nil {
+		return database.Post{}, fmt.Errorf("null value in column \"categories\"")
+	}
+	for _, p := range s.posts {
+		if p.ID == arg.ID || p.Url == arg.Url {
+			return database.Post{}, ErrDuplicate
+		}
+	}
+	p := database.Post{
+		ID:          arg.ID,
+		CreatedAt:   arg.CreatedAt,
+		UpdatedAt:   arg.UpdatedAt,
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: arg.PublishedAt,
+		FeedID:      arg.FeedID,
+		Author:      arg.Author,
+		Categories:  slices.Clone(arg.Categories),
+	}
+	s.posts = append(s.posts, p)
+	return p, nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findUser(func(u database.User) bool { return u.ID == arg.ID || u.Name == arg.Name }) >= 0 {
+		return database.User{}, ErrDuplicate
+	}
+	u := database.User{
+		ID:           arg.ID,
+		CreatedAt:    arg.CreatedAt,
+		UpdatedAt:    arg.UpdatedAt,
+		Name:         arg.Name,
+		PasswordHash: arg.PasswordHash,
+	}
+	s.users = append(s.users, u)
+	return u, nil
+}
+
+// DeleteAllUsers cascades like the foreign keys do: feeds added by a user go
+// with their posts, and follows, read marks and keys go with their user.
+func (s *Store) DeleteAllUsers(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feeds = slices.DeleteFunc(s.feeds, func(f database.Feed) bool { return f.UserID.Valid })
+	s.posts = slices.DeleteFunc(s.posts, func(p database.Post) bool { return s.feedByID(p.FeedID) < 0 })
+	s.users, s.follows, s.apiKeys, s.folders = nil, nil, nil, nil
+	s.tags, s.postTags, s.rules = nil, nil, nil
+	s.watches, s.alerts = nil, nil
+	s.webhooks, s.deliveries = nil, nil
+	s.feedTokens = nil
+	clear(s.states)
+	clear(s.folderFeeds)
+	clear(s.notes)
+	clear(s.resets)
+	return nil
+}
+
+func (s *Store) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.follows = slices.DeleteFunc(s.follows, func(ff database.FeedFollow) bool {
+		return ff.UserID == arg.UserID && ff.FeedID == arg.FeedID
+	})
+	delete(s.folderFeeds, followKey{arg.UserID, arg.FeedID})
+	return nil
+}
+
+func (s *Store) GetAllFeeds(ctx context.Context) ([]database.GetAllFeedsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.GetAllFeedsRow
+	for _, f := range s.feeds {
+		if u := s.userByID(f.UserID.UUID); f.UserID.Valid && u >= 0 {
+			rows = append(rows, database.GetAllFeedsRow{FeedID: f.ID, FeedName: f.Name, FeedUrl: f.Url, UserName: s.users[u].Name})
+		}
+	}
+	return rows, nil
+}
+
+func (s *Store) GetAllUsers(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, u := range s.users {
+		names = append(names, u.Name)
+	}
+	return names, nil
+}
+
+func (s *Store) ListUsersWithoutPassword(ctx context.Context) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var users []database.User
+	for _, u := range s.users {
+		if !u.PasswordHash.Valid {
+			users = append(users, u)
+		}
+	}
+	slices.SortFunc(users, func(a, b database.User) int { return strings.Compare(a.Name, b.Name) })
+	return users, nil
+}
+
+func (s *Store) GetFeed(ctx context.Context, id uuid.UUID) (database.GetFeedRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.feedByID(id)
+	if i < 0 {
+		return database.GetFeedRow{}, sql.ErrNoRows
+	}
+	f := s.feeds[i]
+	return database.GetFeedRow{ID: f.ID, CreatedAt: f.CreatedAt, UpdatedAt: f.UpdatedAt, Name: f.Name, Url: f.Url, UserID: f.UserID}, nil
+}
+
+func (s *Store) GetFeedByURL(ctx context.Context, url string) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.findFeed(func(f database.Feed) bool { return f.Url == url })
+	if i < 0 {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return s.feeds[i], nil
+}
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.GetFeedFollowsForUserRow
+	for _, ff := range s.follows {
+		if ff.UserID != userID {
+			continue
+		}
+		feed := s.feeds[s.feedByID(ff.FeedID)]
+		var folderName sql.NullString
+		if folderID, ok := s.folderFeeds[followKey{ff.UserID, ff.FeedID}]; ok {
+			folderName = sql.NullString{String: s.folders[s.folderByID(folderID)].Name, Valid: true}
+		}
+		rows = append(rows, database.GetFeedFollowsForUserRow{
+			ID:         ff.ID,
+			CreatedAt:  ff.CreatedAt,
+			UpdatedAt:  ff.UpdatedAt,
+			UserID:     ff.UserID,
+			FeedID:     ff.FeedID,
+			UserName:   s.users[s.userByID(ff.UserID)].Name,
+			FeedName:   feed.Name,
+			FeedUrl:    feed.Url,
+			FolderName: folderName,
+		})
+	}
+	return rows, nil
+}
+
+func (s *Store) GetFeedsByUser(ctx context.Context, userID uuid.NullUUID) ([]database.GetFeedsByUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.GetFeedsByUserRow
+	for _, f := range s.feeds {
+		if userID.Valid && f.UserID == userID {
+			rows = append(rows, database.GetFeedsByUserRow{ID: f.ID, CreatedAt: f.CreatedAt, UpdatedAt: f.UpdatedAt, Name: f.Name, Url: f.Url, UserID: f.UserID})
+		}
+	}
+	return rows, nil
+}
+
+func (s *Store) GetNextFeedToFetch(ctx context.Context) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.feeds) == 0 {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	next := s.feeds[0]
+	for _, f := range s.feeds[1:] {
+		switch {
+		case !next.LastFetchedAt.Valid:
+		case !f.LastFetchedAt.Valid || f.LastFetchedAt.Time.Before(next.LastFetchedAt.Time):
+			next = f
+		}
+	}
+	return next, nil
+}
+
+func (s *Store) GetPost(ctx context.Context, id uuid.UUID) (database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.postByID(id)
+	if i < 0 {
+		return database.Post{}, sql.ErrNoRows
+	}
+	p := s.posts[i]
+	p.Categories = slices.Clone(p.Categories)
+	return p, nil
+}
+
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var posts []database.Post
+	for _, p := range s.posts {
+		if f := s.feeds[s.feedByID(p.FeedID)]; arg.UserID.Valid && f.UserID == arg.UserID {
+			posts = append(posts, p)
+		}
+	}
+	slices.SortStableFunc(posts, func(a, b database.Post) int {
+		switch {
+		case a.PublishedAt.Valid && b.PublishedAt.Valid:
+			return b.PublishedAt.Time.Compare(a.PublishedAt.Time)
+		case a.PublishedAt.Valid:
+			return -1
+		case b.PublishedAt.Valid:
+			return 1
+		}
+		return 0
+	})
+	return posts[:min(len(posts), int(arg.Limit))], nil
+}
+
+// GetPostsForUserFiltered follows sql/queries/posts.sql: posts from followed
+// feeds, filtered, newest first by the sort key and then by id.
+func (s *Store) GetPostsForUserFiltered(ctx context.Context, arg database.GetPostsForUserFilteredParams) ([]database.GetPostsForUserFilteredRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	followed := make(map[uuid.UUID]bool)
+	for _, ff := range s.follows {
+		if ff.UserID == arg.UserID {
+			followed[ff.FeedID] = true
+		}
+	}
+	var inFolder map[uuid.UUID]bool
+	if arg.Folder.Valid {
+		inFolder = s.folderTreeFeeds(arg.UserID, arg.Folder.String)
+	}
+	sortKey := func(r database.GetPostsForUserFilteredRow) time.Time {
+		if arg.SortBy == "fetched" || !r.PublishedAt.Valid {
+			return r.CreatedAt
+		}
+		return r.PublishedAt.Time
+	}
+
+	var rows []database.GetPostsForUserFilteredRow
+	for _, p := range s.posts {
+		if !followed[p.FeedID] {
+			continue
+		}
+		feed := s.feeds[s.feedByID(p.FeedID)]
+		state := s.states[stateKey{arg.UserID, p.ID}]
+		r := database.GetPostsForUserFilteredRow{
+			ID:          p.ID,
+			CreatedAt:   p.CreatedAt,
+			UpdatedAt:   p.UpdatedAt,
+			Title:       p.Title,
+			Url:         p.Url,
+			Description: p.Description,
+			PublishedAt: p.PublishedAt,
+			FeedID:      p.FeedID,
+			Author:      p.Author,
+			Categories:  slices.Clone(p.Categories),
+			Content:     p.Content,
+			FeedName:    feed.Name,
+			FeedUrl:     feed.Url,
+			ReadAt:      state.ReadAt,
+			StarredAt:   state.StarredAt,
+			HiddenAt:    state.HiddenAt,
+			Tags:        s.postTagNames(arg.UserID, p.ID),
+		}
+		if note, ok := s.notes[stateKey{arg.UserID, p.ID}]; ok {
+			r.Note = sql.NullString{String: note.Body, Valid: true}
+		}
+		key := sortKey(r)
+
+		switch {
+		case arg.Feed.Valid && feed.Url != arg.Feed.String && feed.Name != arg.Feed.String:
+		case arg.Author.Valid && !strings.Contains(strings.ToLower(p.Author.String), strings.ToLower(arg.Author.String)):
+		case arg.Author.Valid && !p.Author.Valid:
+		case arg.Category.Valid && !slices.Contains(p.Categories, arg.Category.String):
+		case arg.Since.Valid && key.Before(arg.Since.Time):
+		case arg.Until.Valid && !key.Before(arg.Until.Time):
+		case arg.CursorTime.Valid && !before(key, p.ID, arg.CursorTime.Time, arg.CursorID.UUID):
+		case arg.UnreadOnly && state.ReadAt.Valid:
+		case arg.StarredOnly && !state.StarredAt.Valid:
+		case arg.Folder.Valid && !inFolder[p.FeedID]:
+		case arg.Tag.Valid && !slices.Contains(r.Tags, arg.Tag.String):
+		case !arg.IncludeHidden && state.HiddenAt.Valid:
+		case arg.Search.Valid && !containsFold(arg.Search.String, p.Title, p.Description.String, p.Content.String):
+		default:
+			rows = append(rows, r)
+		}
+	}
+
+	slices.SortFunc(rows, func(a, b database.GetPostsForUserFilteredRow) int {
+		if before(sortKey(a), a.ID, sortKey(b), b.ID) {
+			return 1
+		}
+		if before(sortKey(b), b.ID, sortKey(a), a.ID) {
+			return -1
+		}
+		return 0
+	})
+
+	off := min(int(arg.Off), len(rows))
+	return rows[off:min(off+int(arg.Lim), len(rows))], nil
+}
+
+// containsFold reports whether any of texts contains substr, ignoring case
+// like ILIKE.
+func containsFold(substr string, texts ...string) bool {
+	for _, text := range texts {
+		if strings.Contains(strings.ToLower(text), strings.ToLower(substr)) {
+			return true
+		}
+	}
+	return false
+}
+
+// before compares (t1, id1) < (t2, id2) like a SQL row comparison.
+func before(t1 time.Time, id1 uuid.UUID, t2 time.Time, id2 uuid.UUID) bool {
+	if !t1.Equal(t2) {
+		return t1.Before(t2)
+	}
+	return bytes.Compare(id1[:], id2[:]) < 0
+}
+
+func (s *Store) GetUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userByID(id)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) GetUserByAPIKeyHash(ctx context.Context, keyHash string) (database.GetUserByAPIKeyHashRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.apiKeys {
+		if k.KeyHash != keyHash || k.RevokedAt.Valid {
+			continue
+		}
+		u := s.users[s.userByID(k.UserID)]
+		return database.GetUserByAPIKeyHashRow{
+			ID:           u.ID,
+			CreatedAt:    u.CreatedAt,
+			UpdatedAt:    u.UpdatedAt,
+			Name:         u.Name,
+			PasswordHash: u.PasswordHash,
+			FailedLogins: u.FailedLogins,
+			LockedUntil:  u.LockedUntil,
+			ApiKeyID:     k.ID,
+		}, nil
+	}
+	return database.GetUserByAPIKeyHashRow{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUserByName(ctx context.Context, name string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.findUser(func(u database.User) bool { return u.Name == name })
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) ListAPIKeysForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []database.ApiKey
+	for _, k := range s.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortStableFunc(keys, func(a, b database.ApiKey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return keys, nil
+}
+
+// updateUser applies fn to the user with id, if there is one.
+func (s *Store) updateUser(id uuid.UUID, fn func(*database.User)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.userByID(id); i >= 0 {
+		fn(&s.users[i])
+	}
+}
+
+func (s *Store) LockUser(ctx context.Context, arg database.LockUserParams) error {
+	s.updateUser(arg.ID, func(u *database.User) {
+		u.LockedUntil = arg.LockedUntil
+		u.FailedLogins = 0
+	})
+	return nil
+}
+
+func (s *Store) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.feedByID(id); i >= 0 {
+		t := now()
+		s.feeds[i].LastFetchedAt = t
+		s.feeds[i].UpdatedAt = t.Time
+	}
+	return nil
+}
+
+func (s *Store) SetFeedFullContent(ctx context.Context, arg database.SetFeedFullContentParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.feedByID(arg.ID); i >= 0 {
+		s.feeds[i].FetchFullContent = arg.FetchFullContent
+		s.feeds[i].UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (s *Store) SetPostContent(ctx context.Context, arg database.SetPostContentParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.postByID(arg.ID); i >= 0 {
+		s.posts[i].Content = arg.Content
+		s.posts[i].UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (s *Store) RecordFailedLogin(ctx context.Context, id uuid.UUID) (int32, error) {
+	failed := int32(-1)
+	s.updateUser(id, func(u *database.User) {
+		u.FailedLogins++
+		failed = u.FailedLogins
+	})
+	if failed < 0 {
+		return 0, sql.ErrNoRows
+	}
+	return failed, nil
+}
+
+func (s *Store) ResetFailedLogins(ctx context.Context, id uuid.UUID) error {
+	s.updateUser(id, func(u *database.User) {
+		u.FailedLogins = 0
+		u.LockedUntil = sql.NullTime{}
+	})
+	return nil
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, arg database.RevokeAPIKeyParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for i, k := range s.apiKeys {
+		if k.UserID == arg.UserID && k.Prefix == arg.Prefix && !k.RevokedAt.Valid {
+			s.apiKeys[i].RevokedAt = now()
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) RevokeAllAPIKeys(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.apiKeys {
+		if k.UserID == userID && !k.RevokedAt.Valid {
+			s.apiKeys[i].RevokedAt = now()
+		}
+	}
+	return nil
+}
+
+func (s *Store) RevokeStaleAPIKeys(ctx context.Context, arg database.RevokeStaleAPIKeysParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for i, k := range s.apiKeys {
+		lastUsed := k.CreatedAt
+		if k.LastUsedAt.Valid {
+			lastUsed = k.LastUsedAt.Time
+		}
+		if k.UserID == arg.UserID && k.Name == arg.Name && !k.RevokedAt.Valid && lastUsed.Before(arg.Before) {
+			s.apiKeys[i].RevokedAt = now()
+			n++
+		}
+	}
+	return n, nil
+}
+
+// setPostState upserts the read, starred or hidden mark of a post.
+func (s *Store) setPostState(userID, postID uuid.UUID, fn func(*database.PostState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(userID) < 0 || s.postByID(postID) < 0 {
+		return fmt.Errorf("post state for unknown user or post")
+	}
+	key := stateKey{userID, postID}
+	state := s.states[key]
+	state.UserID, state.PostID = userID, postID
+	fn(&state)
+	s.states[key] = state
+	return nil
+}
+
+func (s *Store) SetPostHidden(ctx context.Context, arg database.SetPostHiddenParams) error {
+	return s.setPostState(arg.UserID, arg.PostID, func(st *database.PostState) {
+		st.HiddenAt = sql.NullTime{}
+		if arg.Hidden {
+			st.HiddenAt = now()
+		}
+	})
+}
+
+func (s *Store) SetPostRead(ctx context.Context, arg database.SetPostReadParams) error {
+	return s.setPostState(arg.UserID, arg.PostID, func(st *database.PostState) {
+		st.ReadAt = sql.NullTime{}
+		if arg.Read {
+			st.ReadAt = now()
+		}
+	})
+}
+
+func (s *Store) SetPostStarred(ctx context.Context, arg database.SetPostStarredParams) error {
+	return s.setPostState(arg.UserID, arg.PostID, func(st *database.PostState) {
+		st.StarredAt = sql.NullTime{}
+		if arg.Starred {
+			st.StarredAt = now()
+		}
+	})
+}
+
+func (s *Store) SetUserPassword(ctx context.Context, arg database.SetUserPasswordParams) error {
+	s.updateUser(arg.ID, func(u *database.User) {
+		u.PasswordHash = arg.PasswordHash
+		u.FailedLogins = 0
+		u.LockedUntil = sql.NullTime{}
+		u.UpdatedAt = time.Now()
+	})
+	return nil
+}
+
+func (s *Store) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.apiKeys {
+		if k.ID == id {
+			s.apiKeys[i].LastUsedAt = now()
+		}
+	}
+	return nil
+}
+
+func (s *Store) folderByID(id uuid.UUID) int {
+	return slices.IndexFunc(s.folders, func(f database.Folder) bool { return f.ID == id })
+}
+
+// folderTreeFeeds returns the feeds the user has put in the named folder or
+// any folder below it.
+func (s *Store) folderTreeFeeds(userID uuid.UUID, name string) map[uuid.UUID]bool {
+	tree := make(map[uuid.UUID]bool)
+	for _, f := range s.folders {
+		if f.UserID == userID && f.Name == name {
+			tree[f.ID] = true
+		}
+	}
+	for grew := true; grew; {
+		grew = false
+		for _, f := range s.folders {
+			if f.ParentID.Valid && tree[f.ParentID.UUID] && !tree[f.ID] {
+				tree[f.ID] = true
+				grew = true
+			}
+		}
+	}
+
+	feeds := make(map[uuid.UUID]bool)
+	for key, folderID := range s.folderFeeds {
+		if key.userID == userID && tree[folderID] {
+			feeds[key.feedID] = true
+		}
+	}
+	return feeds
+}
+
+func (s *Store) CreateFolder(ctx context.Context, arg database.CreateFolderParams) (database.Folder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(arg.UserID) < 0 {
+		return database.Folder{}, fmt.Errorf("folder for unknown user %s", arg.UserID)
+	}
+	if arg.ParentID.Valid && s.folderByID(arg.ParentID.UUID) < 0 {
+		return database.Folder{}, fmt.Errorf("folder with unknown parent %s", arg.ParentID.UUID)
+	}
+	for _, f := range s.folders {
+		if f.ID == arg.ID || (f.UserID == arg.UserID && f.Name == arg.Name) {
+			return database.Folder{}, ErrDuplicate
+		}
+	}
+	f := database.Folder(arg)
+	s.folders = append(s.folders, f)
+	return f, nil
+}
+
+// DeleteFolder moves subfolders to the top level and drops the folder's
+// feeds from it, like the foreign keys do.
+func (s *Store) DeleteFolder(ctx context.Context, arg database.DeleteFolderParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.folders, func(f database.Folder) bool { return f.UserID == arg.UserID && f.Name == arg.Name })
+	if i < 0 {
+		return 0, nil
+	}
+	id := s.folders[i].ID
+	s.folders = slices.Delete(s.folders, i, i+1)
+	for i, f := range s.folders {
+		if f.ParentID.Valid && f.ParentID.UUID == id {
+			s.folders[i].ParentID = uuid.NullUUID{}
+		}
+	}
+	for key, folderID := range s.folderFeeds {
+		if folderID == id {
+			delete(s.folderFeeds, key)
+		}
+	}
+	s.webhooks = slices.DeleteFunc(s.webhooks, func(w database.Webhook) bool { return w.FolderID.Valid && w.FolderID.UUID == id })
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d database.WebhookDelivery) bool { return s.webhookByID(d.WebhookID) < 0 })
+	return 1, nil
+}
+
+func (s *Store) GetFolderByName(ctx context.Context, arg database.GetFolderByNameParams) (database.Folder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.folders, func(f database.Folder) bool { return f.UserID == arg.UserID && f.Name == arg.Name })
+	if i < 0 {
+		return database.Folder{}, sql.ErrNoRows
+	}
+	return s.folders[i], nil
+}
+
+func (s *Store) ListFolders(ctx context.Context, userID uuid.UUID) ([]database.ListFoldersRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.ListFoldersRow
+	for _, f := range s.folders {
+		if f.UserID != userID {
+			continue
+		}
+		var count int64
+		for _, folderID := range s.folderFeeds {
+			if folderID == f.ID {
+				count++
+			}
+		}
+		rows = append(rows, database.ListFoldersRow{ID: f.ID, CreatedAt: f.CreatedAt, UserID: f.UserID, Name: f.Name, ParentID: f.ParentID, FeedCount: count})
+	}
+	slices.SortFunc(rows, func(a, b database.ListFoldersRow) int { return strings.Compare(a.Name, b.Name) })
+	return rows, nil
+}
+
+func (s *Store) SetFeedFolder(ctx context.Context, arg database.SetFeedFolderParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	followed := slices.ContainsFunc(s.follows, func(ff database.FeedFollow) bool {
+		return ff.UserID == arg.UserID && ff.FeedID == arg.FeedID
+	})
+	if !followed || s.folderByID(arg.FolderID) < 0 {
+		return fmt.Errorf("folder feed for unknown follow or folder")
+	}
+	s.folderFeeds[followKey{arg.UserID, arg.FeedID}] = arg.FolderID
+	return nil
+}
+
+// postTagNames returns the names of the user's tags on a post, sorted.
+func (s *Store) postTagNames(userID, postID uuid.UUID) []string {
+	names := []string{}
+	for _, pt := range s.postTags {
+		if pt.PostID != postID {
+			continue
+		}
+		if t := s.tags[s.tagByID(pt.TagID)]; t.UserID == userID {
+			names = append(names, t.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (s *Store) tagByID(id uuid.UUID) int {
+	return slices.IndexFunc(s.tags, func(t database.Tag) bool { return t.ID == id })
+}
+
+func (s *Store) AddPostTag(ctx context.Context, arg database.AddPostTagParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tagByID(arg.TagID) < 0 || s.postByID(arg.PostID) < 0 {
+		return fmt.Errorf("post tag for unknown tag or post")
+	}
+	if slices.ContainsFunc(s.postTags, func(pt database.PostTag) bool { return pt.TagID == arg.TagID && pt.PostID == arg.PostID }) {
+		return nil
+	}
+	s.postTags = append(s.postTags, database.PostTag{TagID: arg.TagID, PostID: arg.PostID, CreatedAt: time.Now()})
+	return nil
+}
+
+func (s *Store) ListPostTags(ctx context.Context, arg database.ListPostTagsParams) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.postTagNames(arg.UserID, arg.PostID), nil
+}
+
+func (s *Store) ListTags(ctx context.Context, userID uuid.UUID) ([]database.ListTagsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.ListTagsRow
+	for _, t := range s.tags {
+		if t.UserID != userID {
+			continue
+		}
+		var count int64
+		for _, pt := range s.postTags {
+			if pt.TagID == t.ID {
+				count++
+			}
+		}
+		rows = append(rows, database.ListTagsRow{ID: t.ID, CreatedAt: t.CreatedAt, UserID: t.UserID, Name: t.Name, PostCount: count})
+	}
+	slices.SortFunc(rows, func(a, b database.ListTagsRow) int { return strings.Compare(a.Name, b.Name) })
+	return rows, nil
+}
+
+func (s *Store) RemovePostTag(ctx context.Context, arg database.RemovePostTagParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.postTags)
+	s.postTags = slices.DeleteFunc(s.postTags, func(pt database.PostTag) bool {
+		t := s.tags[s.tagByID(pt.TagID)]
+		return pt.PostID == arg.PostID && t.UserID == arg.UserID && t.Name == arg.Name
+	})
+	return int64(before - len(s.postTags)), nil
+}
+
+func (s *Store) UpsertTag(ctx context.Context, arg database.UpsertTagParams) (database.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(arg.UserID) < 0 {
+		return database.Tag{}, fmt.Errorf("tag for unknown user %s", arg.UserID)
+	}
+	for _, t := range s.tags {
+		if t.UserID == arg.UserID && t.Name == arg.Name {
+			return t, nil
+		}
+		if t.ID == arg.ID {
+			return database.Tag{}, ErrDuplicate
+		}
+	}
+	t := database.Tag(arg)
+	s.tags = append(s.tags, t)
+	return t, nil
+}
+
+func (s *Store) DeletePostNote(ctx context.Context, arg database.DeletePostNoteParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := stateKey{arg.UserID, arg.PostID}
+	if _, ok := s.notes[key]; !ok {
+		return 0, nil
+	}
+	delete(s.notes, key)
+	return 1, nil
+}
+
+func (s *Store) GetPostNote(ctx context.Context, arg database.GetPostNoteParams) (database.PostNote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	note, ok := s.notes[stateKey{arg.UserID, arg.PostID}]
+	if !ok {
+		return database.PostNote{}, sql.ErrNoRows
+	}
+	return note, nil
+}
+
+func (s *Store) SetPostNote(ctx context.Context, arg database.SetPostNoteParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(arg.UserID) < 0 || s.postByID(arg.PostID) < 0 {
+		return fmt.Errorf("note for unknown user or post")
+	}
+	key := stateKey{arg.UserID, arg.PostID}
+	note, ok := s.notes[key]
+	if !ok {
+		note = database.PostNote{UserID: arg.UserID, PostID: arg.PostID, CreatedAt: time.Now()}
+	}
+	note.Body = arg.Body
+	note.UpdatedAt = time.Now()
+	s.notes[key] = note
+	return nil
+}
+
+func (s *Store) CreateRule(ctx context.Context, arg database.CreateRuleParams) (database.Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(arg.UserID) < 0 || (arg.FeedID.Valid && s.feedByID(arg.FeedID.UUID) < 0) {
+		return database.Rule{}, fmt.Errorf("rule for unknown user or feed")
+	}
+	if slices.ContainsFunc(s.rules, func(r database.Rule) bool { return r.ID == arg.ID }) {
+		return database.Rule{}, ErrDuplicate
+	}
+	r := database.Rule(arg)
+	s.rules = append(s.rules, r)
+	return r, nil
+}
+
+func (s *Store) DeleteRule(ctx context.Context, arg database.DeleteRuleParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.rules)
+	s.rules = slices.DeleteFunc(s.rules, func(r database.Rule) bool { return r.UserID == arg.UserID && r.ID == arg.ID })
+	return int64(before - len(s.rules)), nil
+}
+
+func (s *Store) GetRule(ctx context.Context, arg database.GetRuleParams) (database.Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.rules, func(r database.Rule) bool { return r.UserID == arg.UserID && r.ID == arg.ID })
+	if i < 0 {
+		return database.Rule{}, sql.ErrNoRows
+	}
+	return s.rules[i], nil
+}
+
+func (s *Store) ListRules(ctx context.Context, userID uuid.UUID) ([]database.ListRulesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.ListRulesRow
+	for _, r := range s.rules {
+		if r.UserID != userID {
+			continue
+		}
+		var feedName sql.NullString
+		if f := s.feedByID(r.FeedID.UUID); r.FeedID.Valid && f >= 0 {
+			feedName = sql.NullString{String: s.feeds[f].Name, Valid: true}
+		}
+		rows = append(rows, database.ListRulesRow{
+			ID:           r.ID,
+			CreatedAt:    r.CreatedAt,
+			UserID:       r.UserID,
+			FeedID:       r.FeedID,
+			TitlePattern: r.TitlePattern,
+			Action:       r.Action,
+			FeedName:     feedName,
+		})
+	}
+	return rows, nil
+}
+
+func (s *Store) ListRulesForFeed(ctx context.Context, feedID uuid.UUID) ([]database.Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rules []database.Rule
+	for _, r := range s.rules {
+		following := slices.ContainsFunc(s.follows, func(ff database.FeedFollow) bool {
+			return ff.UserID == r.UserID && ff.FeedID == feedID
+		})
+		if following && (!r.FeedID.Valid || r.FeedID.UUID == feedID) {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func (s *Store) watchByID(id uuid.UUID) int {
+	return slices.IndexFunc(s.watches, func(w database.Watch) bool { return w.ID == id })
+}
+
+func (s *Store) CreateWatch(ctx context.Context, arg database.CreateWatchParams) (database.Watch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(arg.UserID) < 0 || (arg.FeedID.Valid && s.feedByID(arg.FeedID.UUID) < 0) {
+		return database.Watch{}, fmt.Errorf("watch for unknown user or feed")
+	}
+	if slices.ContainsFunc(s.watches, func(w database.Watch) bool {
+		return w.ID == arg.ID || (w.UserID == arg.UserID && w.Name == arg.Name)
+	}) {
+		return database.Watch{}, ErrDuplicate
+	}
+	w := database.Watch(arg)
+	s.watches = append(s.watches, w)
+	return w, nil
+}
+
+func (s *Store) DeleteWatch(ctx context.Context, arg database.DeleteWatchParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.watches)
+	s.watches = slices.DeleteFunc(s.watches, func(w database.Watch) bool { return w.UserID == arg.UserID && w.Name == arg.Name })
+	s.alerts = slices.DeleteFunc(s.alerts, func(a database.Alert) bool { return s.watchByID(a.WatchID) < 0 })
+	return int64(before - len(s.watches)), nil
+}
+
+func (s *Store) GetWatchByName(ctx context.Context, arg database.GetWatchByNameParams) (database.Watch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.watches, func(w database.Watch) bool { return w.UserID == arg.UserID && w.Name == arg.Name })
+	if i < 0 {
+		return database.Watch{}, sql.ErrNoRows
+	}
+	return s.watches[i], nil
+}
+
+func (s *Store) ListWatches(ctx context.Context, userID uuid.UUID) ([]database.ListWatchesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.ListWatchesRow
+	for _, w := range s.watches {
+		if w.UserID != userID {
+			continue
+		}
+		var feedName sql.NullString
+		if f := s.feedByID(w.FeedID.UUID); w.FeedID.Valid && f >= 0 {
+			feedName = sql.NullString{String: s.feeds[f].Name, Valid: true}
+		}
+		var unacknowledged int64
+		for _, a := range s.alerts {
+			if a.WatchID == w.ID && !a.AcknowledgedAt.Valid {
+				unacknowledged++
+			}
+		}
+		rows = append(rows, database.ListWatchesRow{
+			ID:             w.ID,
+			CreatedAt:      w.CreatedAt,
+			UserID:         w.UserID,
+			FeedID:         w.FeedID,
+			Name:           w.Name,
+			Pattern:        w.Pattern,
+			Notify:         w.Notify,
+			FeedName:       feedName,
+			Unacknowledged: unacknowledged,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.ListWatchesRow) int { return strings.Compare(a.Name, b.Name) })
+	return rows, nil
+}
+
+func (s *Store) ListWatchesForFeed(ctx context.Context, feedID uuid.UUID) ([]database.Watch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var watches []database.Watch
+	for _, w := range s.watches {
+		following := slices.ContainsFunc(s.follows, func(ff database.FeedFollow) bool {
+			return ff.UserID == w.UserID && ff.FeedID == feedID
+		})
+		if following && (!w.FeedID.Valid || w.FeedID.UUID == feedID) {
+			watches = append(watches, w)
+		}
+	}
+	return watches, nil
+}
+
+func (s *Store) CreateAlert(ctx context.Context, arg database.CreateAlertParams) (database.Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchByID(arg.WatchID) < 0 || s.postByID(arg.PostID) < 0 {
+		return database.Alert{}, fmt.Errorf("alert for unknown watch or post")
+	}
+	if slices.ContainsFunc(s.alerts, func(a database.Alert) bool {
+		return a.ID == arg.ID || (a.WatchID == arg.WatchID && a.PostID == arg.PostID)
+	}) {
+		return database.Alert{}, ErrDuplicate
+	}
+	a := database.Alert{ID: arg.ID, CreatedAt: arg.CreatedAt, WatchID: arg.WatchID, PostID: arg.PostID}
+	s.alerts = append(s.alerts, a)
+	return a, nil
+}
+
+func (s *Store) ListAlerts(ctx context.Context, arg database.ListAlertsParams) ([]database.ListAlertsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.ListAlertsRow
+	for _, a := range s.alerts {
+		w := s.watchByID(a.WatchID)
+		p := s.postByID(a.PostID)
+		if w < 0 || p < 0 || s.watches[w].UserID != arg.UserID || (a.AcknowledgedAt.Valid && !arg.IncludeAcknowledged) {
+			continue
+		}
+		post := s.posts[p]
+		rows = append(rows, database.ListAlertsRow{
+			ID:             a.ID,
+			CreatedAt:      a.CreatedAt,
+			WatchID:        a.WatchID,
+			PostID:         a.PostID,
+			AcknowledgedAt: a.AcknowledgedAt,
+			WatchName:      s.watches[w].Name,
+			Title:          post.Title,
+			Url:            post.Url,
+			FeedName:       s.feeds[s.feedByID(post.FeedID)].Name,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.ListAlertsRow) int {
+		if before(a.CreatedAt, a.ID, b.CreatedAt, b.ID) {
+			return 1
+		}
+		return -1
+	})
+	return rows, nil
+}
+
+// acknowledgeAlerts marks the user's unacknowledged alerts that match as
+// acknowledged, and expects s.mu to be held.
+func (s *Store) acknowledgeAlerts(userID uuid.UUID, match func(database.Alert) bool) int64 {
+	var n int64
+	for i, a := range s.alerts {
+		w := s.watchByID(a.WatchID)
+		if a.AcknowledgedAt.Valid || w < 0 || s.watches[w].UserID != userID || !match(a) {
+			continue
+		}
+		s.alerts[i].AcknowledgedAt = now()
+		n++
+	}
+	return n
+}
+
+func (s *Store) AcknowledgeAlert(ctx context.Context, arg database.AcknowledgeAlertParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acknowledgeAlerts(arg.UserID, func(a database.Alert) bool { return a.ID == arg.ID }), nil
+}
+
+func (s *Store) AcknowledgeAllAlerts(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acknowledgeAlerts(userID, func(database.Alert) bool { return true }), nil
+}
+
+func (s *Store) webhookByID(id uuid.UUID) int {
+	return slices.IndexFunc(s.webhooks, func(w database.Webhook) bool { return w.ID == id })
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, arg database.CreateWebhookParams) (database.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(arg.UserID) < 0 || (arg.FeedID.Valid && s.feedByID(arg.FeedID.UUID) < 0) ||
+		(arg.FolderID.Valid && s.folderByID(arg.FolderID.UUID) < 0) {
+		return database.Webhook{}, fmt.Errorf("webhook for unknown user, feed or folder")
+	}
+	if s.webhookByID(arg.ID) >= 0 {
+		return database.Webhook{}, ErrDuplicate
+	}
+	w := database.Webhook(arg)
+	s.webhooks = append(s.webhooks, w)
+	return w, nil
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, arg database.DeleteWebhookParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.webhooks)
+	s.webhooks = slices.DeleteFunc(s.webhooks, func(w database.Webhook) bool { return w.UserID == arg.UserID && w.ID == arg.ID })
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d database.WebhookDelivery) bool { return s.webhookByID(d.WebhookID) < 0 })
+	return int64(before - len(s.webhooks)), nil
+}
+
+func (s *Store) ListWebhooks(ctx context.Context, userID uuid.UUID) ([]database.ListWebhooksRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.ListWebhooksRow
+	for _, w := range s.webhooks {
+		if w.UserID != userID {
+			continue
+		}
+		row := database.ListWebhooksRow{
+			ID:        w.ID,
+			CreatedAt: w.CreatedAt,
+			UserID:    w.UserID,
+			Url:       w.Url,
+			Secret:    w.Secret,
+			FeedID:    w.FeedID,
+			FolderID:  w.FolderID,
+		}
+		if f := s.feedByID(w.FeedID.UUID); w.FeedID.Valid && f >= 0 {
+			row.FeedName = sql.NullString{String: s.feeds[f].Name, Valid: true}
+		}
+		if f := s.folderByID(w.FolderID.UUID); w.FolderID.Valid && f >= 0 {
+			row.FolderName = sql.NullString{String: s.folders[f].Name, Valid: true}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (s *Store) ListWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]database.ListWebhooksForFeedRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.ListWebhooksForFeedRow
+	for _, w := range s.webhooks {
+		following := slices.ContainsFunc(s.follows, func(ff database.FeedFollow) bool {
+			return ff.UserID == w.UserID && ff.FeedID == feedID
+		})
+		if !following || (w.FeedID.Valid && w.FeedID.UUID != feedID) {
+			continue
+		}
+		var feedFolderID uuid.NullUUID
+		if id, ok := s.folderFeeds[followKey{w.UserID, feedID}]; ok {
+			feedFolderID = uuid.NullUUID{UUID: id, Valid: true}
+		}
+		rows = append(rows, database.ListWebhooksForFeedRow{
+			ID:           w.ID,
+			CreatedAt:    w.CreatedAt,
+			UserID:       w.UserID,
+			Url:          w.Url,
+			Secret:       w.Secret,
+			FeedID:       w.FeedID,
+			FolderID:     w.FolderID,
+			FeedFolderID: feedFolderID,
+		})
+	}
+	return rows, nil
+}
+
+func (s *Store) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.webhookByID(arg.WebhookID) < 0 || s.postByID(arg.PostID) < 0 {
+		return fmt.Errorf("delivery for unknown webhook or post")
+	}
+	if slices.ContainsFunc(s.deliveries, func(d database.WebhookDelivery) bool { return d.ID == arg.ID }) {
+		return ErrDuplicate
+	}
+	s.deliveries = append(s.deliveries, database.WebhookDelivery{
+		ID:            arg.ID,
+		CreatedAt:     arg.CreatedAt,
+		WebhookID:     arg.WebhookID,
+		PostID:        arg.PostID,
+		Payload:       arg.Payload,
+		Status:        "pending",
+		NextAttemptAt: arg.NextAttemptAt,
+	})
+	return nil
+}
+
+func (s *Store) ClaimDueWebhookDeliveries(ctx context.Context, arg database.ClaimDueWebhookDeliveriesParams) ([]database.ClaimDueWebhookDeliveriesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []int
+	for i, d := range s.deliveries {
+		if (d.Status == "pending" || d.Status == "sending") && !d.NextAttemptAt.After(arg.Now) {
+			due = append(due, i)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b int) int {
+		return s.deliveries[a].NextAttemptAt.Compare(s.deliveries[b].NextAttemptAt)
+	})
+	var rows []database.ClaimDueWebhookDeliveriesRow
+	for _, i := range due[:min(len(due), int(arg.Lim))] {
+		d := &s.deliveries[i]
+		d.Status = "sending"
+		d.NextAttemptAt = arg.LeaseUntil
+		w := s.webhooks[s.webhookByID(d.WebhookID)]
+		rows = append(rows, database.ClaimDueWebhookDeliveriesRow{
+			ID:             d.ID,
+			CreatedAt:      d.CreatedAt,
+			WebhookID:      d.WebhookID,
+			PostID:         d.PostID,
+			Payload:        d.Payload,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			NextAttemptAt:  d.NextAttemptAt,
+			LastAttemptAt:  d.LastAttemptAt,
+			LastError:      d.LastError,
+			ResponseStatus: d.ResponseStatus,
+			Url:            w.Url,
+			Secret:         w.Secret,
+		})
+	}
+	return rows, nil
+}
+
+func (s *Store) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.ListWebhookDeliveriesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.ListWebhookDeliveriesRow
+	for _, d := range s.deliveries {
+		w := s.webhookByID(d.WebhookID)
+		p := s.postByID(d.PostID)
+		if w < 0 || p < 0 || s.webhooks[w].UserID != arg.UserID || (arg.Status.Valid && d.Status != arg.Status.String) {
+			continue
+		}
+		rows = append(rows, database.ListWebhookDeliveriesRow{
+			ID:             d.ID,
+			CreatedAt:      d.CreatedAt,
+			WebhookID:      d.WebhookID,
+			PostID:         d.PostID,
+			Payload:        d.Payload,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			NextAttemptAt:  d.NextAttemptAt,
+			LastAttemptAt:  d.LastAttemptAt,
+			LastError:      d.LastError,
+			ResponseStatus: d.ResponseStatus,
+			Url:            s.webhooks[w].Url,
+			Title:          s.posts[p].Title,
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.ListWebhookDeliveriesRow) int {
+		if before(a.CreatedAt, a.ID, b.CreatedAt, b.ID) {
+			return 1
+		}
+		return -1
+	})
+	return rows[:min(len(rows), int(arg.Lim))], nil
+}
+
+func (s *Store) RetryWebhookDelivery(ctx context.Context, arg database.RetryWebhookDeliveryParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, d := range s.deliveries {
+		w := s.webhookByID(d.WebhookID)
+		if d.ID != arg.ID || d.Status != "failed" || w < 0 || s.webhooks[w].UserID != arg.UserID {
+			continue
+		}
+		s.deliveries[i].Status = "pending"
+		s.deliveries[i].NextAttemptAt = arg.NextAttemptAt
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, arg database.UpdateWebhookDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.deliveries, func(d database.WebhookDelivery) bool { return d.ID == arg.ID })
+	if i < 0 {
+		return nil
+	}
+	d := &s.deliveries[i]
+	d.Status, d.Attempts, d.NextAttemptAt = arg.Status, arg.Attempts, arg.NextAttemptAt
+	d.LastAttemptAt, d.LastError, d.ResponseStatus = arg.LastAttemptAt, arg.LastError, arg.ResponseStatus
+	return nil
+}
+
+func (s *Store) GetDigestSettings(ctx context.Context, userID uuid.UUID) (database.DigestSetting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.digests[userID]
+	if !ok {
+		return database.DigestSetting{}, sql.ErrNoRows
+	}
+	return d, nil
+}
+
+// digestSetting returns the user's row, or the column defaults for a new one.
+func (s *Store) digestSetting(userID uuid.UUID) (database.DigestSetting, error) {
+	if s.userByID(userID) < 0 {
+		return database.DigestSetting{}, fmt.Errorf("digest settings for unknown user %s", userID)
+	}
+	d, ok := s.digests[userID]
+	if !ok {
+		d = database.DigestSetting{UserID: userID, Schedule: "daily"}
+	}
+	return d, nil
+}
+
+func (s *Store) SetDigestSent(ctx context.Context, arg database.SetDigestSentParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.digestSetting(arg.UserID)
+	if err != nil {
+		return err
+	}
+	d.LastSentAt = arg.LastSentAt
+	s.digests[arg.UserID] = d
+	return nil
+}
+
+func (s *Store) SetDigestSettings(ctx context.Context, arg database.SetDigestSettingsParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.digestSetting(arg.UserID)
+	if err != nil {
+		return err
+	}
+	d.Email, d.Schedule = arg.Email, arg.Schedule
+	s.digests[arg.UserID] = d
+	return nil
+}
+
+func (s *Store) DeletePasswordReset(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.resets, userID)
+	return nil
+}
+
+func (s *Store) GetPasswordReset(ctx context.Context, userID uuid.UUID) (database.PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.resets[userID]
+	if !ok {
+		return database.PasswordReset{}, sql.ErrNoRows
+	}
+	return r, nil
+}
+
+func (s *Store) SetPasswordReset(ctx context.Context, arg database.SetPasswordResetParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(arg.UserID) < 0 {
+		return fmt.Errorf("password reset for unknown user %s", arg.UserID)
+	}
+	s.resets[arg.UserID] = database.PasswordReset{UserID: arg.UserID, TokenHash: arg.TokenHash, ExpiresAt: arg.ExpiresAt}
+	return nil
+}
+
+func (s *Store) CreateFeedToken(ctx context.Context, arg database.CreateFeedTokenParams) (database.FeedToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByID(arg.UserID) < 0 {
+		return database.FeedToken{}, fmt.Errorf("feed token for unknown user %s", arg.UserID)
+	}
+	for _, t := range s.feedTokens {
+		if t.ID == arg.ID || t.TokenHash == arg.TokenHash {
+			return database.FeedToken{}, ErrDuplicate
+		}
+	}
+	t := database.FeedToken{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		TokenHash: arg.TokenHash,
+	}
+	s.feedTokens = append(s.feedTokens, t)
+	return t, nil
+}
+
+func (s *Store) GetFeedTokenByHash(ctx context.Context, tokenHash string) (database.FeedToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.feedTokens {
+		if t.TokenHash == tokenHash && !t.RevokedAt.Valid {
+			return t, nil
+		}
+	}
+	return database.FeedToken{}, sql.ErrNoRows
+}
+
+func (s *Store) ListFeedTokensForUser(ctx context.Context, userID uuid.UUID) ([]database.FeedToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tokens []database.FeedToken
+	for _, t := range s.feedTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	slices.SortStableFunc(tokens, func(a, b database.FeedToken) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return tokens, nil
+}
+
+func (s *Store) RevokeFeedToken(ctx context.Context, arg database.RevokeFeedTokenParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for i, t := range s.feedTokens {
+		if t.UserID == arg.UserID && t.Prefix == arg.Prefix && !t.RevokedAt.Valid {
+			s.feedTokens[i].RevokedAt = now()
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) TouchFeedToken(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, t := range s.feedTokens {
+		if t.ID == id {
+			s.feedTokens[i].LastUsedAt = now()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,435 @@
+// Package migrate applies goose-annotated SQL files (sql/schema for Postgres,
+// sql/sqlite/schema for SQLite) without needing the goose binary. It keeps its bookkeeping in goose's own
+// goose_db_version table, so databases migrated with goose before keep
+// working and the two tools can be mixed.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const versionTable = "goose_db_version"
+
+// Dialect selects the SQL used for the version table itself; the migrations
+// are written for one dialect and loaded separately for each.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// Migrator applies one set of migrations to a database.
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    Dialect
+	Migrations []Migration
+}
+
+// Migration is one parsed schema file.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      []string
+	Down    []string
+	// NoTx is set by "-- +goose NO TRANSACTION", e.g. for CREATE INDEX
+	// CONCURRENTLY.
+	NoTx bool
+}
+
+// Status is a migration together with when it was applied, if it was.
+type Status struct {
+	Migration
+	AppliedAt sql.NullTime
+}
+
+// Load reads and parses every .sql file in dir, sorted by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int64]string)
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		f, err := fsys.Open(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration: %w", err)
+		}
+		m, err := Parse(e.Name(), f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[m.Version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version %d", other, m.Name, m.Version)
+		}
+		seen[m.Version] = m.Name
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Parse splits a goose SQL file into its up and down statements. The version
+// is the number the file name starts with, e.g. 20250929151021_feeds.sql.
+//
+// Outside of StatementBegin/StatementEnd a statement ends at a line ending in
+// a semicolon; inside, everything up to StatementEnd is sent as one
+// statement.
+func Parse(name string, r io.Reader) (Migration, error) {
+	m := Migration{Name: name}
+	digits, _, _ := strings.Cut(name, "_")
+	version, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || version <= 0 {
+		return m, fmt.Errorf("%s: file name must start with a positive version number", name)
+	}
+	m.Version = version
+
+	var (
+		section   *[]string
+		buf       strings.Builder
+		inBlock   bool
+		lineNo    int
+		hasUpDown bool
+	)
+	flush := func() {
+		stmt := strings.TrimSpace(buf.String())
+		buf.Reset()
+		if stmt != "" && section != nil && !onlyComments(stmt) {
+			*section = append(*section, stmt)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if directive, ok := strings.CutPrefix(trimmed, "-- +goose "); ok {
+			switch strings.TrimSpace(directive) {
+			case "Up":
+				flush()
+				section, hasUpDown = &m.Up, true
+			case "Down":
+				flush()
+				section, hasUpDown = &m.Down, true
+			case "StatementBegin":
+				flush()
+				inBlock = true
+			case "StatementEnd":
+				if !inBlock {
+					return m, fmt.Errorf("%s:%d: StatementEnd without StatementBegin", name, lineNo)
+				}
+				flush()
+				inBlock = false
+			case "NO TRANSACTION":
+				m.NoTx = true
+			default:
+				return m, fmt.Errorf("%s:%d: unknown goose directive %q", name, lineNo, directive)
+			}
+			continue
+		}
+
+		if section == nil {
+			if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return m, fmt.Errorf("%s:%d: SQL before the first -- +goose Up/Down", name, lineNo)
+			}
+			continue
+		}
+
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		if !inBlock && strings.HasSuffix(trimmed, ";") && !strings.HasPrefix(trimmed, "--") {
+			flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return m, fmt.Errorf("%s: %w", name, err)
+	}
+	if inBlock {
+		return m, fmt.Errorf("%s: StatementBegin without StatementEnd", name)
+	}
+	if !hasUpDown {
+		return m, fmt.Errorf("%s: no -- +goose Up annotation", name)
+	}
+	flush()
+	return m, nil
+}
+
+func onlyComments(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Migrator) tableExists(ctx context.Context) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = $1)`
+	if m.Dialect == SQLite {
+		query = `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = $1)`
+	}
+
+	var exists bool
+	if err := m.DB.QueryRowContext(ctx, query, versionTable).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look for %s: %w", versionTable, err)
+	}
+	return exists, nil
+}
+
+// applied returns when each applied version was applied. Older goose
+// versions recorded a rollback as a row with is_applied = false, so rows are
+// replayed in order instead of only looking at is_applied.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	exists, err := m.tableExists(ctx)
+	if err != nil || !exists {
+		return map[int64]time.Time{}, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx,
+		`SELECT version_id, is_applied, tstamp FROM `+versionTable+` ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+			tstamp    sql.NullTime
+		)
+		if err := rows.Scan(&version, &isApplied, &tstamp); err != nil {
+			return nil, fmt.Errorf("failed to read schema version: %w", err)
+		}
+		if version == 0 {
+			continue
+		}
+		if isApplied {
+			versions[version] = tstamp.Time
+		} else {
+			delete(versions, version)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return versions, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	exists, err := m.tableExists(ctx)
+	if err != nil || exists {
+		return err
+	}
+
+	// Same layout goose creates, including its version 0 row.
+	create := `CREATE TABLE ` + versionTable + ` (
+		id serial NOT NULL,
+		version_id bigint NOT NULL,
+		is_applied boolean NOT NULL,
+		tstamp timestamp NULL default now(),
+		PRIMARY KEY(id)
+	)`
+	if m.Dialect == SQLite {
+		create = `CREATE TABLE ` + versionTable + ` (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version_id INTEGER NOT NULL,
+		is_applied INTEGER NOT NULL,
+		tstamp TIMESTAMP DEFAULT (datetime('now'))
+	)`
+	}
+	_, err = m.DB.ExecContext(ctx, create)
+	if err == nil {
+		_, err = m.DB.ExecContext(ctx, `INSERT INTO `+versionTable+` (version_id, is_applied) VALUES (0, true)`)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", versionTable, err)
+	}
+	return nil
+}
+
+// Statuses reports every migration and whether it has been applied.
+func (m *Migrator) Statuses(ctx context.Context) ([]Status, error) {
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		s := Status{Migration: mig}
+		if t, ok := versions[mig.Version]; ok {
+			s.AppliedAt = sql.NullTime{Time: t, Valid: true}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order and returns the ones it ran.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.upTo(ctx, len(m.Migrations))
+}
+
+func (m *Migrator) upTo(ctx context.Context, n int) ([]Migration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var current int64
+	for v := range versions {
+		current = max(current, v)
+	}
+
+	var ran []Migration
+	for _, mig := range m.Migrations[:n] {
+		if _, ok := versions[mig.Version]; ok {
+			continue
+		}
+		if mig.Version < current {
+			return ran, fmt.Errorf("migration %s is older than the applied version %d; apply it by hand or renumber it", mig.Name, current)
+		}
+		if err := m.run(ctx, mig, mig.Up, true); err != nil {
+			return ran, err
+		}
+		ran = append(ran, mig)
+	}
+	return ran, nil
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	_, mig, err := m.last(ctx)
+	if err != nil {
+		return mig, err
+	}
+	return mig, m.run(ctx, mig, mig.Down, false)
+}
+
+// Redo rolls back the most recently applied migration and applies it again,
+// leaving any later pending migrations alone.
+func (m *Migrator) Redo(ctx context.Context) (Migration, error) {
+	i, mig, err := m.last(ctx)
+	if err != nil {
+		return mig, err
+	}
+	if err := m.run(ctx, mig, mig.Down, false); err != nil {
+		return mig, err
+	}
+	_, err = m.upTo(ctx, i+1)
+	return mig, err
+}
+
+func (m *Migrator) last(ctx context.Context) (int, Migration, error) {
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return 0, Migration{}, err
+	}
+	for i := len(m.Migrations) - 1; i >= 0; i-- {
+		if _, ok := versions[m.Migrations[i].Version]; ok {
+			return i, m.Migrations[i], nil
+		}
+	}
+	return 0, Migration{}, fmt.Errorf("no migrations to roll back")
+}
+
+// run executes one direction of a migration and records it, inside a single
+// transaction unless the file opted out.
+func (m *Migrator) run(ctx context.Context, mig Migration, statements []string, up bool) error {
+	record := `DELETE FROM ` + versionTable + ` WHERE version_id = $1`
+	if up {
+		record = `INSERT INTO ` + versionTable + ` (version_id, is_applied) VALUES ($1, true)`
+	}
+
+	if mig.NoTx {
+		for _, stmt := range statements {
+			if _, err := m.DB.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("%s: %w", mig.Name, err)
+			}
+		}
+		if _, err := m.DB.ExecContext(ctx, record, mig.Version); err != nil {
+			return fmt.Errorf("%s: failed to record version: %w", mig.Name, err)
+		}
+		return nil
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", mig.Name, err)
+	}
+	defer tx.Rollback()
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%s: %w", mig.Name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, mig.Version); err != nil {
+		return fmt.Errorf("%s: failed to record version: %w", mig.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", mig.Name, err)
+	}
+	return nil
+}
+
+// Check returns an error explaining what to do when the database schema
+// doesn't match the migrations: pending migrations, or versions this binary
+// doesn't know about because the database was migrated by a newer gator.
+func (m *Migrator) Check(ctx context.Context) error {
+	versions, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int64]bool, len(m.Migrations))
+	pending := 0
+	for _, mig := range m.Migrations {
+		known[mig.Version] = true
+		if _, ok := versions[mig.Version]; !ok {
+			pending++
+		}
+	}
+
+	var current, latest int64
+	for v := range versions {
+		current = max(current, v)
+		if !known[v] {
+			latest = max(latest, v)
+		}
+	}
+	if latest > 0 {
+		return fmt.Errorf("database schema is at version %d, newer than this gator knows about; upgrade gator or run 'gator migrate down' with the newer binary", latest)
+	}
+	if pending > 0 {
+		if len(versions) == 0 {
+			return fmt.Errorf("database schema is not set up: run 'gator migrate up'")
+		}
+		return fmt.Errorf("database schema is at version %d with %d pending migration(s): run 'gator migrate up'", current, pending)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package migrate
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	src := `-- +goose Up
+CREATE TABLE a (id INT);
+-- a comment
+CREATE TABLE b (
+    id INT
+);
+
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS INT AS $$
+BEGIN
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION f;
+DROP TABLE b;
+DROP TABLE a;
+`
+	m, err := Parse("20250101000000_things.sql", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Version != 20250101000000 {
+		t.Errorf("version = %d", m.Version)
+	}
+	wantUp := []string{
+		"CREATE TABLE a (id INT);",
+		"-- a comment\nCREATE TABLE b (\n    id INT\n);",
+		"CREATE FUNCTION f() RETURNS INT AS $$\nBEGIN\n    RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;",
+	}
+	if !reflect.DeepEqual(m.Up, wantUp) {
+		t.Errorf("up = %q\nwant %q", m.Up, wantUp)
+	}
+	wantDown := []string{"DROP FUNCTION f;", "DROP TABLE b;", "DROP TABLE a;"}
+	if !reflect.DeepEqual(m.Down, wantDown) {
+		t.Errorf("down = %q\nwant %q", m.Down, wantDown)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, file, src, want string
+	}{
+		{"no version", "users.sql", "-- +goose Up\nSELECT 1;\n", "must start with a positive version number"},
+		{"no annotation", "1_x.sql", "SELECT 1;\n", "SQL before the first"},
+		{"unterminated block", "1_x.sql", "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n", "StatementBegin without StatementEnd"},
+		{"unknown directive", "1_x.sql", "-- +goose Sideways\n", "unknown goose directive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.file, strings.NewReader(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	for _, dir := range []string{"../../sql/schema", "../../sql/sqlite/schema"} {
+		migrations, err := Load(os.DirFS(dir), ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("no migrations in %s", dir)
+		}
+		for i, m := range migrations {
+			if len(m.Up) == 0 || len(m.Down) == 0 {
+				t.Errorf("%s: %d up and %d down statements, want both", m.Name, len(m.Up), len(m.Down))
+			}
+			if i > 0 && m.Version <= migrations[i-1].Version {
+				t.Errorf("%s is not sorted after %s", m.Name, migrations[i-1].Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package sqlite lets the sqlc-generated Postgres queries in
+// internal/database run against SQLite. Each query carries its sqlc name
+// ("-- name: CreateUser :one"), and DB swaps in the query of the same name
+// from the SQLite query files, which take the same parameters and return the
+// same columns, so the generated code and its types are shared.
+package sqlite
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func init() {
+	// pq_array_contains(array, value) stands in for Postgres' value = ANY(array)
+	// on TEXT[] columns, which are stored in Postgres array syntax.
+	sqlite.MustRegisterDeterministicScalarFunction("pq_array_contains", 2,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			var values pq.StringArray
+			if err := values.Scan(args[0]); err != nil {
+				return nil, err
+			}
+			for _, v := range values {
+				if v == args[1] {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+}
+
+// Open opens the SQLite database file at path, creating it if needed.
+func Open(path string) (*sql.DB, error) {
+	dsn := "file:" + path +
+		"?_time_format=sqlite" +
+		"&_pragma=foreign_keys(1)" +
+		"&_pragma=journal_mode(WAL)" +
+		"&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// DB implements database.DBTX on top of a SQLite connection.
+type DB struct {
+	db      *sql.DB
+	queries map[string]string
+}
+
+// New loads the SQLite queries from every .sql file at the root of queries.
+func New(db *sql.DB, queries fs.FS) (*DB, error) {
+	d := &DB{db: db, queries: make(map[string]string)}
+
+	files, err := fs.Glob(queries, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(queries, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sqlite queries: %w", err)
+		}
+		if err := d.parse(file, string(data)); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// parse splits a query file at each "-- name:" comment, like sqlc does.
+func (d *DB) parse(file, data string) error {
+	var name string
+	var buf strings.Builder
+	flush := func() {
+		if name != "" {
+			d.queries[name] = strings.TrimSpace(buf.String())
+		}
+		buf.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if n := queryName(line); n != "" {
+			flush()
+			if _, dup := d.queries[n]; dup {
+				return fmt.Errorf("%s: query %s is defined twice", file, n)
+			}
+			name = n
+		}
+		if name != "" {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+	}
+	flush()
+	return scanner.Err()
+}
+
+// Names lists the queries that have a SQLite version.
+func (d *DB) Names() []string {
+	names := make([]string, 0, len(d.queries))
+	for name := range d.queries {
+		names = append(names, name)
+	}
+	return names
+}
+
+// queryName returns X from a "-- name: X :kind" line.
+func queryName(line string) string {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "-- name:")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func (d *DB) translate(query string, args []any) (string, []any, error) {
+	first, _, _ := strings.Cut(query, "\n")
+	name := queryName(first)
+	sqliteQuery, ok := d.queries[name]
+	if !ok {
+		return "", nil, fmt.Errorf("query %q is not available on SQLite", name)
+	}
+
+	// Times are written in UTC so the stored text compares in time order.
+	converted := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			converted[i] = v.UTC()
+		case sql.NullTime:
+			if v.Valid {
+				v.Time = v.Time.UTC()
+			}
+			converted[i] = v
+		default:
+			converted[i] = arg
+		}
+	}
+	return sqliteQuery, converted, nil
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args, err := d.translate(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return d.db.ExecContext(ctx, query, args...)
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	query, _, err := d.translate(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return d.db.PrepareContext(ctx, query)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query, args, err := d.translate(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return d.db.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext can't return an error of its own, so an unknown query is
+// passed to SQLite unchanged and fails there.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if translated, converted, err := d.translate(query, args); err == nil {
+		query, args = translated, converted
+	}
+	return d.db.QueryRowContext(ctx, query, args...)
+}
+
+// IsUniqueViolation reports whether err is a UNIQUE or PRIMARY KEY
+// constraint failure.
+func IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
@@ -3,96 +3,400 @@ package main
 import (
 	"blog/internal/config"
 	"blog/internal/database"
-	"database/sql"
+	"blog/internal/migrate"
+	"context"
 	"fmt"
 	_ "github.com/lib/pq"
 	"log"
 	"os"
+	"strings"
 )
 
 type State struct {
 	sStruct *config.Config
-	db      *database.Queries
-}
+	db      database.Querier
+	out     *Output
+	fetcher fetcher
 
-type Command struct {
-	Name string
-	Args []string
+	migrator *migrate.Migrator
 }
 
-type Commands struct {
-	Cmap map[string]func(*State, Command) error
+func registerCommands(cmds *Commands) {
+	cmds.register(commandSpec{
+		Name:        "help",
+		Usage:       "[command]",
+		Description: "Show all commands, or the usage and flags of one command",
+		MaxArgs:     1,
+		Offline:     true,
+		Handler:     cmds.handlerHelp,
+	})
+	cmds.register(commandSpec{
+		Name:        "login",
+		Usage:       "<username>",
+		Description: "Log in with your password (prompted) or one of your API keys",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags:       loginFlags,
+		Complete:    completeUsernames,
+		Handler:     handlerLogin,
+	})
+	cmds.register(commandSpec{
+		Name:        "register",
+		Usage:       "<username>",
+		Description: "Create a user with a password (prompted) and log in as them",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Handler:     handlerRegister,
+	})
+	cmds.register(commandSpec{
+		Name:        "whoami",
+		Description: "Print the user your API key logs you in as",
+		Handler:     handlerWhoami,
+	})
+	cmds.register(commandSpec{
+		Name:        "reset",
+		Description: "Delete all users and everything they own",
+		Handler:     handlerReset,
+	})
+	cmds.register(commandSpec{
+		Name:        "users",
+		Description: "List all users",
+		Handler:     handlerGetUsers,
+	})
+	cmds.register(commandSpec{
+		Name:        "agg",
+		Usage:       "[time_between_reqs]",
+		Description: "Fetch feeds forever, one feed every interval (e.g. 1m, default fetch_interval)",
+		MaxArgs:     1,
+		Handler:     handlerAgg,
+	})
+	cmds.register(commandSpec{
+		Name:        "addfeed",
+		Usage:       "<name> <url>",
+		Description: "Add a feed and follow it",
+		MinArgs:     2,
+		MaxArgs:     2,
+		Handler:     middlewareLoggedIn(handlerAddFeed),
+	})
+	cmds.register(commandSpec{
+		Name:        "feeds",
+		Description: "List all feeds",
+		Handler:     handlerFeeds,
+	})
+	cmds.register(commandSpec{
+		Name:        "follow",
+		Usage:       "<feed_url>",
+		Description: "Follow an existing feed",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    completeFeedURLs,
+		Handler:     middlewareLoggedIn(handlerFollow),
+	})
+	cmds.register(commandSpec{
+		Name:        "following",
+		Description: "List the feeds you follow",
+		Handler:     middlewareLoggedIn(handlerFollowing),
+	})
+	cmds.register(commandSpec{
+		Name:        "unfollow",
+		Usage:       "<feed_url>",
+		Description: "Stop following a feed",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    completeFollowedFeedURLs,
+		Handler:     middlewareLoggedIn(handlerUnfollowFeed),
+	})
+	cmds.register(commandSpec{
+		Name:        "category",
+		Usage:       "create <name> | add <feed_url> <name> | list | delete <name> | export | import <file.opml>",
+		Description: "Organize the feeds you follow into nested categories",
+		MinArgs:     1,
+		MaxArgs:     3,
+		Flags:       categoryFlags,
+		Handler:     middlewareLoggedIn(handlerCategory),
+	})
+	cmds.register(commandSpec{
+		Name:        "browse",
+		Usage:       "[limit]",
+		Description: "Show posts from the feeds you follow, newest first",
+		MaxArgs:     1,
+		Flags:       browseFlags,
+		Handler:     middlewareLoggedIn(handlerBrowse),
+	})
+	cmds.register(commandSpec{
+		Name:        "tag",
+		Usage:       "[<post_id> [tag...]]",
+		Description: "Tag a post, show its tags, or list your tags",
+		MaxArgs:     -1,
+		Flags:       tagFlags,
+		Handler:     middlewareLoggedIn(handlerTag),
+	})
+	cmds.register(commandSpec{
+		Name:        "note",
+		Usage:       "<post_id>",
+		Description: "Write a markdown note on a post in $EDITOR",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags:       noteFlags,
+		Handler:     middlewareLoggedIn(handlerNote),
+	})
+	cmds.register(commandSpec{
+		Name:        "rule",
+		Usage:       "add | list | remove <rule_id> | test [rule_id]",
+		Description: "Hide, highlight, star or tag new posts whose title matches a pattern",
+		MinArgs:     1,
+		MaxArgs:     2,
+		Flags:       ruleFlags,
+		Handler:     middlewareLoggedIn(handlerRule),
+	})
+	cmds.register(commandSpec{
+		Name:        "watch",
+		Usage:       "add <name> <regex> | list | remove <name> | test <name>",
+		Description: "Save a search that raises an alert when a new post matches it",
+		MinArgs:     1,
+		MaxArgs:     3,
+		Flags:       watchFlags,
+		Handler:     middlewareLoggedIn(handlerWatch),
+	})
+	cmds.register(commandSpec{
+		Name:        "alerts",
+		Usage:       "[ack <alert_id>... | ack all]",
+		Description: "List or acknowledge the alerts raised by your watches",
+		MaxArgs:     -1,
+		Flags:       alertsFlags,
+		Handler:     middlewareLoggedIn(handlerAlerts),
+	})
+	cmds.register(commandSpec{
+		Name:        "webhook",
+		Usage:       "add <url> | list | remove <webhook_id> | deliveries | retry <delivery_id>",
+		Description: "Send new posts as signed JSON to a URL",
+		MinArgs:     1,
+		MaxArgs:     2,
+		Flags:       webhookFlags,
+		Handler:     middlewareLoggedIn(handlerWebhook),
+	})
+	cmds.register(commandSpec{
+		Name:        "digest",
+		Usage:       "[set | status]",
+		Description: "Write or mail the posts fetched since your last digest",
+		MaxArgs:     1,
+		Flags:       digestFlags,
+		Handler:     middlewareLoggedIn(handlerDigest),
+	})
+	cmds.register(commandSpec{
+		Name:        "export-posts",
+		Description: "Archive your posts as Markdown, JSON, CSV or a static HTML site",
+		Flags:       exportFlags,
+		Handler:     middlewareLoggedIn(handlerExportPosts),
+	})
+	cmds.register(commandSpec{
+		Name:        "fullcontent",
+		Usage:       "<feed> [on|off]",
+		Description: "Show or set whether agg fetches the full article of a feed's new posts",
+		MinArgs:     1,
+		MaxArgs:     2,
+		Complete:    completeFollowedFeedURLs,
+		Handler:     middlewareLoggedIn(handlerFullContent),
+	})
+	cmds.register(commandSpec{
+		Name:        "publish",
+		Description: "Write your followed posts as an Atom or RSS feed",
+		Flags:       publishFlags,
+		Handler:     middlewareLoggedIn(handlerPublish),
+	})
+	cmds.register(commandSpec{
+		Name:        "passwd",
+		Description: "Set or change your password",
+		Handler:     middlewareLoggedIn(handlerPasswd),
+	})
+	cmds.register(commandSpec{
+		Name:        "reset-password",
+		Usage:       "<username>",
+		Description: "Set a password with a reset token from 'gator migrate reset-tokens'",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Complete:    completeUsernames,
+		Handler:     handlerResetPassword,
+	})
+	cmds.register(commandSpec{
+		Name:        "apikey",
+		Usage:       "create [name] | list | revoke <prefix>",
+		Description: "Manage your API keys",
+		MinArgs:     1,
+		MaxArgs:     2,
+		Handler:     middlewareLoggedIn(handlerAPIKey),
+	})
+	cmds.register(commandSpec{
+		Name:        "feedtoken",
+		Usage:       "create [name] | list | revoke <prefix>",
+		Description: "Manage the tokens feed readers use to subscribe to your published feeds",
+		MinArgs:     1,
+		MaxArgs:     2,
+		Handler:     middlewareLoggedIn(handlerFeedToken),
+	})
+	cmds.register(commandSpec{
+		Name:        "read",
+		Description: "Read posts in an interactive terminal view",
+		Flags:       readFlags,
+		Handler:     middlewareLoggedIn(handlerRead),
+	})
+	cmds.register(commandSpec{
+		Name:        "serve",
+		Description: "Serve users, feeds, follows and posts as a JSON REST API",
+		Flags:       serveFlags,
+		Handler:     handlerServe,
+	})
+	cmds.register(commandSpec{
+		Name:        "migrate",
+		Usage:       "<up|down|redo|status|reset-tokens>",
+		Description: "Apply or roll back the database schema built into gator, or issue password reset tokens",
+		MinArgs:     1,
+		MaxArgs:     1,
+		AnySchema:   true,
+		Handler:     handlerMigrate,
+	})
+	cmds.register(commandSpec{
+		Name:        "config",
+		Usage:       "init [db_url] | get <key> | set <key> <value> | show",
+		Description: "Create, read or change the config of the current profile",
+		MinArgs:     1,
+		MaxArgs:     3,
+		Offline:     true,
+		Handler:     handlerConfig,
+	})
+	cmds.register(commandSpec{
+		Name:        "profile",
+		Usage:       "list | use <name> | add <name> <db_url> | remove <name>",
+		Description: "Manage named profiles, each with its own database and login",
+		MinArgs:     1,
+		MaxArgs:     3,
+		Offline:     true,
+		Handler:     handlerProfile,
+	})
+	cmds.register(commandSpec{
+		Name:        "completion",
+		Usage:       "<bash|zsh|fish>",
+		Description: "Print a shell completion script",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Offline:     true,
+		Handler:     handlerCompletion,
+	})
+	cmds.register(commandSpec{
+		Name:        "__complete",
+		Usage:       "[words...]",
+		Description: "Print completion candidates for the last word (used by the completion scripts)",
+		Offline:     true,
+		Hidden:      true,
+		RawArgs:     true,
+		Handler:     cmds.handlerComplete,
+	})
 }
 
-func (c *Commands) run(s *State, cmd Command) error {
-	handler, ok := c.Cmap[cmd.Name]
-	if !ok {
-		return fmt.Errorf("unknown command: %s", cmd.Name)
-	}
-	return handler(s, cmd)
+// globalOptions apply to every command and come before its name, e.g.
+// `gator --format json --profile team feeds`.
+type globalOptions struct {
+	format  string
+	config  string
+	profile string
 }
 
-func (c *Commands) register(name string, f func(*State, Command) error) {
-	if c.Cmap == nil {
-		c.Cmap = make(map[string]func(*State, Command) error)
+// parseGlobalFlags strips the global options from the front of the argument
+// list.
+func parseGlobalFlags(args []string) (opts globalOptions, rest []string, err error) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		switch name {
+		case "format", "config", "profile":
+			if !hasValue {
+				if len(args) < 2 {
+					return opts, nil, fmt.Errorf("--%s needs a value", name)
+				}
+				value = args[1]
+				args = args[1:]
+			}
+			switch name {
+			case "format":
+				opts.format = value
+			case "config":
+				opts.config = value
+			case "profile":
+				opts.profile = value
+			}
+		case "help", "h":
+			return opts, []string{"help"}, nil
+		default:
+			return opts, nil, fmt.Errorf("unknown global option: %s", args[0])
+		}
+		args = args[1:]
 	}
-	c.Cmap[name] = f
+	return opts, args, nil
 }
 
 func main() {
-	cfg, err := config.Read()
+	opts, args, err := parseGlobalFlags(os.Args[1:])
 	if err != nil {
-		log.Fatal("Failed to read config:", err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
 
-	// Open database connection
-	db, err := sql.Open("postgres", cfg.DBURL)
+	out, err := newOutput(opts.format)
 	if err != nil {
-		log.Fatal("Failed to open database:", err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
-	defer db.Close()
 
-	// Test the database connection
-	if err := db.Ping(); err != nil {
-		log.Fatal("Failed to ping database:", err)
+	cfg, err := config.Read(opts.config, opts.profile)
+	if err != nil {
+		log.Fatal("Failed to read config:", err)
 	}
 
-	// Create the database queries instance
-	dbQueries := database.New(db)
+	cmds := &Commands{}
+	registerCommands(cmds)
 
-	// Create state with both config and database queries
+	cmd := Command{Name: "help"}
+	if len(args) > 0 {
+		cmd = Command{
+			Name: args[0],
+			Args: args[1:],
+		}
+	}
+
+	// Create state with the config; the database is only opened for commands
+	// that need it
 	state := &State{
 		sStruct: cfg,
-		db:      dbQueries,
+		out:     out,
+		fetcher: newFetcher(),
 	}
 
-	cmds := &Commands{}
-	cmds.register("login", handlerLogin)
-	cmds.register("register", handlerRegister)
-	cmds.register("whoami", handlerWhoami)
-	cmds.register("reset", handlerReset)
-	cmds.register("users", handlerGetUsers)
-	cmds.register("agg", handlerAgg)
-	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
-	cmds.register("feeds", handlerFeeds)
-	cmds.register("follow", middlewareLoggedIn(handlerFollow))
-	cmds.register("following", middlewareLoggedIn(handlerFollowing))
-	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
-	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollowFeed))
-	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
-
-	if len(os.Args) < 2 {
-		fmt.Println("usage: gator <command> [args]")
-		os.Exit(1)
-	}
+	if cmds.needsDB(cmd.Name) {
+		if err := cfg.RequireDBURL(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+
+		db, err := openDatabase(cfg.DBURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		// Refuse to run against a schema this binary wasn't built for,
+		// instead of failing later with a confusing SQL error
+		if !cmds.Cmap[cmd.Name].AnySchema {
+			if err := db.migrator.Check(context.Background()); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+		}
 
-	cmd := Command{
-		Name: os.Args[1],
-		Args: os.Args[2:],
+		state.db = db.queries
+		state.migrator = db.migrator
 	}
 
 	if err := cmds.run(state, cmd); err != nil {
-		fmt.Println("Error:", err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
 	}
 }
@@ -10,9 +10,12 @@ func middlewareLoggedIn(
 	handler func(s *State, cmd Command, user database.User) error,
 ) func(s *State, cmd Command) error {
 	return func(s *State, cmd Command) error {
-		ctx := context.Background()
+		key := currentAPIKey(s)
+		if key == "" {
+			return errNotLoggedIn
+		}
 
-		user, err := s.db.GetUserByName(ctx, s.sStruct.CurrentUser)
+		user, err := authenticate(context.Background(), s.db, key)
 		if err != nil {
 			return fmt.Errorf("User not logged in: %w", err)
 		}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func handlerMigrate(s *State, cmd Command) error {
+	ctx := context.Background()
+
+	switch cmd.Args[0] {
+	case "up":
+		ran, err := s.migrator.Up(ctx)
+		for _, m := range ran {
+			s.out.notef("Applied %s\n", m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(ran) == 0 {
+			s.out.notef("Database schema is up to date\n")
+		}
+		users, err := s.db.ListUsersWithoutPassword(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list users without a password: %w", err)
+		}
+		if len(users) > 0 {
+			s.out.notef("%d users have no password and can't log in; run 'gator migrate reset-tokens' and give each their token\n", len(users))
+		}
+		return nil
+
+	case "down":
+		m, err := s.migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		s.out.notef("Rolled back %s\n", m.Name)
+		return nil
+
+	case "redo":
+		m, err := s.migrator.Redo(ctx)
+		if err != nil {
+			return err
+		}
+		s.out.notef("Re-applied %s\n", m.Name)
+		return nil
+
+	case "status":
+		statuses, err := s.migrator.Statuses(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([][]any, 0, len(statuses))
+		for _, st := range statuses {
+			rows = append(rows, []any{st.Version, st.Name, st.AppliedAt.Valid, st.AppliedAt})
+		}
+		return s.out.render([]string{"version", "name", "applied", "applied_at"}, rows)
+
+	case "reset-tokens":
+		// Users from before passwords set their first one with these;
+		// running it again replaces tokens that weren't used.
+		users, err := s.db.ListUsersWithoutPassword(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list users without a password: %w", err)
+		}
+		if len(users) == 0 {
+			s.out.notef("Every user has a password\n")
+			return nil
+		}
+		rows := make([][]any, 0, len(users))
+		for _, user := range users {
+			token, expires, err := issueResetToken(ctx, s.db, user)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, []any{user.Name, token, expires})
+		}
+		s.out.notef("Give each user their token; they set a password with 'gator reset-password <name>'\n")
+		return s.out.render([]string{"user", "token", "expires_at"}, rows)
+	}
+
+	return fmt.Errorf("unknown migrate action %q: expected up, down, redo, status or reset-tokens", cmd.Args[0])
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OPML 2.0 subscription lists: categories become outlines holding their
+// subcategories and feeds, so other readers see the same tree.
+
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Created string        `xml:"head>dateCreated,omitempty"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+func (o opmlOutline) name() string {
+	if o.Title != "" {
+		return o.Title
+	}
+	return o.Text
+}
+
+// exportOPML writes the user's follows as OPML, nested by category, with
+// uncategorized feeds after the top-level categories.
+func exportOPML(ctx context.Context, db database.Querier, user database.User, w io.Writer) error {
+	folders, err := db.ListFolders(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list categories: %w", err)
+	}
+	follows, err := db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get follows: %w", err)
+	}
+
+	children := make(map[uuid.NullUUID][]database.ListFoldersRow)
+	for _, f := range folders {
+		children[f.ParentID] = append(children[f.ParentID], f)
+	}
+	feeds := make(map[string][]opmlOutline)
+	for _, f := range follows {
+		feeds[f.FolderName.String] = append(feeds[f.FolderName.String], opmlOutline{
+			Text:   f.FeedName,
+			Title:  f.FeedName,
+			Type:   "rss",
+			XMLURL: f.FeedUrl,
+		})
+	}
+	sortedFeeds := func(category string) []opmlOutline {
+		out := slices.Clone(feeds[category])
+		slices.SortFunc(out, func(a, b opmlOutline) int { return strings.Compare(a.Text, b.Text) })
+		return out
+	}
+
+	// a category holds its subcategories, then its own feeds
+	var outlines func(parent uuid.NullUUID) []opmlOutline
+	outlines = func(parent uuid.NullUUID) []opmlOutline {
+		var out []opmlOutline
+		for _, f := range children[parent] {
+			out = append(out, opmlOutline{
+				Text:     f.Name,
+				Outlines: append(outlines(uuid.NullUUID{UUID: f.ID, Valid: true}), sortedFeeds(f.Name)...),
+			})
+		}
+		slices.SortFunc(out, func(a, b opmlOutline) int { return strings.Compare(a.Text, b.Text) })
+		return out
+	}
+
+	return writeXML(w, opmlDocument{
+		Version: "2.0",
+		Title:   user.Name + "'s gator subscriptions",
+		Created: time.Now().UTC().Format(time.RFC1123Z),
+		Body:    append(outlines(uuid.NullUUID{}), sortedFeeds("")...),
+	})
+}
+
+type opmlImport struct {
+	feeds, categories int
+	// skipped describes the outlines left out, each with the reason
+	skipped []string
+}
+
+// opmlPlan is what an import will do, worked out before anything is written.
+type opmlPlan struct {
+	categories []opmlCategory
+	feeds      []opmlFeed
+	skipped    []string
+}
+
+type opmlCategory struct {
+	name, parent string
+}
+
+type opmlFeed struct {
+	outline  opmlOutline
+	category string
+}
+
+// importOPML follows every feed in an OPML document, adding the ones gator
+// doesn't know yet, and files each under the outline it sits in. Outlines
+// without a feed URL become categories, reusing the user's category of the
+// same name when it sits at the same path. The whole document is checked
+// first: outlines gator can't store are skipped and reported, and a category
+// the user already has elsewhere in the tree fails the import before any
+// change, since category names are unique per user.
+func importOPML(ctx context.Context, db database.Querier, user database.User, r io.Reader) (opmlImport, error) {
+	var doc opmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return opmlImport{}, fmt.Errorf("failed to parse opml: %w", err)
+	}
+	folders, err := db.ListFolders(ctx, user.ID)
+	if err != nil {
+		return opmlImport{}, fmt.Errorf("failed to list categories: %w", err)
+	}
+	plan, err := planOPML(doc, folders)
+	if err != nil {
+		return opmlImport{}, err
+	}
+
+	result := opmlImport{skipped: plan.skipped}
+	for _, c := range plan.categories {
+		created, err := ensureCategory(ctx, db, user, c.name, c.parent)
+		if err != nil {
+			return result, err
+		}
+		if created {
+			result.categories++
+		}
+	}
+	for _, f := range plan.feeds {
+		followed, err := importFeed(ctx, db, user, f.outline)
+		if err != nil {
+			return result, err
+		}
+		if followed {
+			result.feeds++
+		}
+		if f.category != "" {
+			if err := addToCategory(ctx, db, user, f.outline.XMLURL, f.category); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// planOPML walks the document against the user's existing categories. A bad
+// category outline is skipped together with everything inside it.
+func planOPML(doc opmlDocument, folders []database.ListFoldersRow) (opmlPlan, error) {
+	paths := categoryPaths(folders)
+	existing := make(map[string]string, len(folders))
+	for _, f := range folders {
+		existing[f.Name] = paths[f.ID]
+	}
+	seen := make(map[string]string)
+
+	var plan opmlPlan
+	var walk func(outlines []opmlOutline, parent, parentPath string) error
+	walk = func(outlines []opmlOutline, parent, parentPath string) error {
+		for _, o := range outlines {
+			if o.XMLURL != "" {
+				if u, err := url.Parse(o.XMLURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					plan.skipped = append(plan.skipped, fmt.Sprintf("feed %q: expected an http:// or https:// url", o.XMLURL))
+					continue
+				}
+				plan.feeds = append(plan.feeds, opmlFeed{outline: o, category: parent})
+				continue
+			}
+
+			name := strings.TrimSpace(o.name())
+			path := name
+			if parentPath != "" {
+				path = parentPath + "/" + name
+			}
+			if name == "" || strings.Contains(name, "/") {
+				plan.skipped = append(plan.skipped, fmt.Sprintf("category %q and its %d outlines: a name can't be empty or contain /", path, countOutlines(o.Outlines)))
+				continue
+			}
+			if other, ok := seen[name]; ok && other != path {
+				return fmt.Errorf("category %q is both at %q and %q in the file, but category names are unique: rename one and import again", name, other, path)
+			}
+			if other, ok := existing[name]; ok && other != path {
+				return fmt.Errorf("you already have category %q at %q, not %q: rename or move one and import again", name, other, path)
+			}
+			if _, ok := seen[name]; !ok {
+				seen[name] = path
+				plan.categories = append(plan.categories, opmlCategory{name: name, parent: parent})
+			}
+			if err := walk(o.Outlines, name, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(doc.Body, "", ""); err != nil {
+		return opmlPlan{}, err
+	}
+	return plan, nil
+}
+
+func countOutlines(outlines []opmlOutline) int {
+	n := len(outlines)
+	for _, o := range outlines {
+		n += countOutlines(o.Outlines)
+	}
+	return n
+}
+
+// ensureCategory creates the category under parent unless the user already
+// has one by that name.
+func ensureCategory(ctx context.Context, db database.Querier, user database.User, name, parent string) (bool, error) {
+	_, err := db.GetFolderByName(ctx, database.GetFolderByNameParams{UserID: user.ID, Name: name})
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("failed to get category: %w", err)
+	}
+	if _, err := createCategory(ctx, db, user, name, parent); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// importFeed follows the outline's feed, adding it first if needed, and
+// reports whether it wasn't followed already.
+func importFeed(ctx context.Context, db database.Querier, user database.User, o opmlOutline) (bool, error) {
+	_, err := db.GetFeedByURL(ctx, o.XMLURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		name := o.name()
+		if name == "" {
+			name = o.XMLURL
+		}
+		if _, err := addFeed(ctx, db, user, name, o.XMLURL); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get feed: %w", err)
+	}
+
+	_, err = db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{UserID: user.ID, Url: o.XMLURL})
+	if isUniqueViolation(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to follow %s: %w", o.XMLURL, err)
+	}
+	return true, nil
+}
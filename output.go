@@ -0,0 +1,220 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+	formatTSV    = "tsv"
+)
+
+// maxTableCell keeps long values such as post descriptions from wrecking the
+// table layout. Machine-readable formats are never truncated.
+const maxTableCell = 60
+
+// Output renders listing commands in the format selected with --format.
+// Handlers describe their data as columns and rows; the field names are the
+// column names, so they must stay stable.
+type Output struct {
+	format string
+	w      io.Writer
+	errW   io.Writer
+}
+
+func newOutput(format string) (*Output, error) {
+	switch format {
+	case "":
+		format = formatTable
+	case formatTable, formatJSON, formatNDJSON, formatCSV, formatTSV:
+	default:
+		return nil, fmt.Errorf("unknown format %q: expected table, json, ndjson, csv or tsv", format)
+	}
+	return &Output{format: format, w: os.Stdout, errW: os.Stderr}, nil
+}
+
+// notef prints a human-readable message. It goes to stdout next to tables and
+// to stderr otherwise, so it never ends up in a script's parsed output.
+func (o *Output) notef(format string, args ...any) {
+	w := o.w
+	if o.format != formatTable {
+		w = o.errW
+	}
+	fmt.Fprintf(w, format, args...)
+}
+
+func (o *Output) render(columns []string, rows [][]any) error {
+	switch o.format {
+	case formatJSON:
+		return o.renderJSON(columns, rows)
+	case formatNDJSON:
+		return o.renderNDJSON(columns, rows)
+	case formatCSV:
+		return o.renderDelimited(columns, rows, ',')
+	case formatTSV:
+		return o.renderDelimited(columns, rows, '\t')
+	default:
+		return o.renderTable(columns, rows)
+	}
+}
+
+func (o *Output) renderTable(columns []string, rows [][]any) error {
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cell := strings.Join(strings.Fields(formatCell(v)), " ")
+			if r := []rune(cell); len(r) > maxTableCell {
+				cell = string(r[:maxTableCell-3]) + "..."
+			}
+			cells[i] = cell
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (o *Output) renderDelimited(columns []string, rows [][]any, comma rune) error {
+	cw := csv.NewWriter(o.w)
+	cw.Comma = comma
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = formatCell(v)
+		}
+		if err := cw.Write(cells); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (o *Output) renderJSON(columns []string, rows [][]any) error {
+	objects := make([]json.RawMessage, 0, len(rows))
+	for _, row := range rows {
+		obj, err := jsonObject(columns, row)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, obj)
+	}
+	data, err := json.MarshalIndent(objects, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(o.w, string(data))
+	return err
+}
+
+func (o *Output) renderNDJSON(columns []string, rows [][]any) error {
+	for _, row := range rows {
+		obj, err := jsonObject(columns, row)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(o.w, string(obj)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonObject encodes a row by hand so keys keep the column order.
+func jsonObject(columns []string, row []any) (json.RawMessage, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, col := range columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(col)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(jsonValue(row[i]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", col, err)
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return json.RawMessage(b.String()), nil
+}
+
+// jsonValue unwraps the sql null types so they encode as plain values or null.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case sql.NullString:
+		if !v.Valid {
+			return nil
+		}
+		return v.String
+	case sql.NullInt32:
+		if !v.Valid {
+			return nil
+		}
+		return v.Int32
+	case sql.NullTime:
+		if !v.Valid {
+			return nil
+		}
+		return v.Time.Format(time.RFC3339)
+	case uuid.NullUUID:
+		if !v.Valid {
+			return nil
+		}
+		return v.UUID.String()
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case []string:
+		if v == nil {
+			return []string{}
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func formatCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ";")
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	}
+	switch v := jsonValue(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"blog/internal/database"
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+const (
+	minPasswordLen   = 8
+	maxFailedLogins  = 5
+	loginLockoutTime = 15 * time.Minute
+	resetTokenTTL    = 7 * 24 * time.Hour
+)
+
+var (
+	errWrongPassword   = errors.New("wrong username or password")
+	errWrongResetToken = errors.New("invalid or expired reset token")
+)
+
+// errNoPassword is returned for users created before passwords. Letting them
+// pick one themselves would let anyone claim their account, so they need a
+// reset token from whoever runs gator.
+func errNoPassword(name string) error {
+	return fmt.Errorf("user %s has no password yet: ask whoever runs gator for a reset token "+
+		"('gator migrate reset-tokens'), then run 'gator reset-password %s'", name, name)
+}
+
+// stdinLines is shared so several prompts can read piped input one line at a
+// time, e.g. `printf 'old\nnew\nnew\n' | gator passwd`.
+var stdinLines = bufio.NewReader(os.Stdin)
+
+// promptPassword reads a password without echo when stdin is a terminal, and
+// a plain line otherwise so scripts can pipe it in.
+func promptPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		pw, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return string(pw), nil
+	}
+
+	line, err := stdinLines.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// promptNewPassword asks twice and checks the password is usable.
+func promptNewPassword() (string, error) {
+	pw, err := promptPassword("New password: ")
+	if err != nil {
+		return "", err
+	}
+	if len(pw) < minPasswordLen {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLen)
+	}
+	if len(pw) > 72 {
+		return "", fmt.Errorf("password must be at most 72 bytes")
+	}
+	confirm, err := promptPassword("Repeat password: ")
+	if err != nil {
+		return "", err
+	}
+	if pw != confirm {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return pw, nil
+}
+
+func hashPassword(pw string) (sql.NullString, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+	return sql.NullString{String: string(hash), Valid: true}, nil
+}
+
+// checkPassword verifies pw for user, counting failures and locking the
+// account for loginLockoutTime after maxFailedLogins in a row.
+func checkPassword(ctx context.Context, db database.Querier, user database.User, pw string) error {
+	if user.LockedUntil.Valid && time.Now().Before(user.LockedUntil.Time) {
+		return fmt.Errorf("account locked after too many failed logins, try again after %s",
+			user.LockedUntil.Time.Format("15:04"))
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(pw))
+	if err == nil {
+		if user.FailedLogins > 0 || user.LockedUntil.Valid {
+			if err := db.ResetFailedLogins(ctx, user.ID); err != nil {
+				return fmt.Errorf("failed to reset failed logins: %w", err)
+			}
+		}
+		return nil
+	}
+	if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return fmt.Errorf("failed to check password: %w", err)
+	}
+
+	failed, err := db.RecordFailedLogin(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record failed login: %w", err)
+	}
+	if failed >= maxFailedLogins {
+		err := db.LockUser(ctx, database.LockUserParams{
+			ID:          user.ID,
+			LockedUntil: sql.NullTime{Time: time.Now().Add(loginLockoutTime), Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to lock account: %w", err)
+		}
+		return fmt.Errorf("%w; account locked for %s", errWrongPassword, loginLockoutTime)
+	}
+	return errWrongPassword
+}
+
+func handlerPasswd(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+
+	if !user.PasswordHash.Valid {
+		return errNoPassword(user.Name)
+	}
+	current, err := promptPassword("Current password: ")
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(ctx, s.db, user, current); err != nil {
+		return err
+	}
+
+	pw, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(pw)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.SetUserPassword(ctx, database.SetUserPasswordParams{ID: user.ID, PasswordHash: hash}); err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+	s.out.notef("Password updated for %s\n", user.Name)
+	return nil
+}
+
+// issueResetToken stores a one-time token that lets user set a password with
+// 'gator reset-password', replacing any earlier one. Like API keys, only a
+// SHA-256 of the token is stored.
+func issueResetToken(ctx context.Context, db database.Querier, user database.User) (string, time.Time, error) {
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+	expires := time.Now().Add(resetTokenTTL)
+
+	err := db.SetPasswordReset(ctx, database.SetPasswordResetParams{
+		UserID:    user.ID,
+		TokenHash: hashAPIKey(token),
+		ExpiresAt: expires,
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store reset token: %w", err)
+	}
+	return token, expires, nil
+}
+
+// handlerResetPassword sets a password with a token from issueResetToken. The
+// token is used up, and every API key the user had is revoked, since whoever
+// held them before the reset wasn't necessarily the user.
+func handlerResetPassword(s *State, cmd Command) error {
+	ctx := context.Background()
+
+	user, err := s.db.GetUserByName(ctx, cmd.Args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %s not found", cmd.Args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	reset, err := s.db.GetPasswordReset(ctx, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get reset token: %w", err)
+	}
+	token, err := promptPassword("Reset token: ")
+	if err != nil {
+		return err
+	}
+	if reset.TokenHash == "" || time.Now().After(reset.ExpiresAt) ||
+		subtle.ConstantTimeCompare([]byte(hashAPIKey(strings.TrimSpace(token))), []byte(reset.TokenHash)) != 1 {
+		return errWrongResetToken
+	}
+	pw, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(pw)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.DeletePasswordReset(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to use up reset token: %w", err)
+	}
+	if err := s.db.SetUserPassword(ctx, database.SetUserPasswordParams{ID: user.ID, PasswordHash: hash}); err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+	if err := s.db.RevokeAllAPIKeys(ctx, user.ID); err != nil {
+		return fmt.Errorf("password set but failed to revoke old api keys: %w", err)
+	}
+	s.out.notef("Password set for %s and old API keys revoked; log in with 'gator login %s'\n", user.Name, user.Name)
+	return nil
+}
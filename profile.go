@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+)
+
+func handlerProfile(s *State, cmd Command) error {
+	cfg := s.sStruct
+
+	switch action := cmd.Args[0]; action {
+	case "list":
+		if len(cmd.Args) != 1 {
+			return fmt.Errorf("usage: gator profile list")
+		}
+		rows := [][]any{}
+		for _, name := range cfg.Profiles() {
+			p, _ := cfg.StoredProfile(name)
+			rows = append(rows, []any{name, redactConfigValue("db_url", p.DBURL), p.CurrentUser, name == cfg.ActiveProfile(), name == cfg.Name})
+		}
+		return s.out.render([]string{"name", "db_url", "current_user_name", "active", "current"}, rows)
+
+	case "use":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator profile use <name>")
+		}
+		if err := cfg.UseProfile(cmd.Args[1]); err != nil {
+			return err
+		}
+		s.out.notef("Using profile %s\n", cmd.Args[1])
+		return nil
+
+	case "add":
+		if len(cmd.Args) != 3 {
+			return fmt.Errorf("usage: gator profile add <name> <db_url>")
+		}
+		if err := validateDBURL(cmd.Args[2]); err != nil {
+			return err
+		}
+		if err := cfg.AddProfile(cmd.Args[1], cmd.Args[2]); err != nil {
+			return err
+		}
+		s.out.notef("Added profile %s: switch to it with 'gator profile use %s' or --profile %s\n", cmd.Args[1], cmd.Args[1], cmd.Args[1])
+		return nil
+
+	case "remove":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator profile remove <name>")
+		}
+		if err := cfg.RemoveProfile(cmd.Args[1]); err != nil {
+			return err
+		}
+		s.out.notef("Removed profile %s\n", cmd.Args[1])
+		return nil
+
+	default:
+		return fmt.Errorf("unknown profile action %q: expected list, use, add or remove", action)
+	}
+}
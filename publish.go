@@ -0,0 +1,428 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const publishLimit = 50
+
+var errRSSNeedsURL = errors.New("an RSS feed needs --url, the public URL it will be served at, for its channel link")
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Author     *atomPerson    `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Source     atomSource     `xml:"source"`
+	Summary    *atomText      `xml:"summary"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomSource struct {
+	Title string   `xml:"title"`
+	Link  atomLink `xml:"link"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          *rssSelf  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Creator     string        `xml:"dc:creator,omitempty"`
+	Categories  []string      `xml:"category"`
+	Source      rssItemSource `xml:"source"`
+	Description string        `xml:"description,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItemSource struct {
+	URL   string `xml:"url,attr"`
+	Title string `xml:",chardata"`
+}
+
+// publishedFeed describes one output feed: a user's followed posts,
+// optionally narrowed to a category or one of the user's tags. The user's
+// notes are only included when asked for, since whoever has a served feed's
+// URL can read it.
+type publishedFeed struct {
+	user     database.User
+	category string
+	tag      string
+	notes    bool
+	selfURL  string
+	posts    []database.GetPostsForUserFilteredRow
+}
+
+func (f publishedFeed) title() string {
+	var filters []string
+	if f.category != "" {
+		filters = append(filters, f.category)
+	}
+	if f.tag != "" {
+		filters = append(filters, "#"+f.tag)
+	}
+	if len(filters) > 0 {
+		return fmt.Sprintf("%s's gator: %s", f.user.Name, strings.Join(filters, " "))
+	}
+	return fmt.Sprintf("%s's gator", f.user.Name)
+}
+
+// id is derived from the user and filters so it stays the same between
+// renders, as Atom readers expect.
+func (f publishedFeed) id() string {
+	name := "posts/" + f.category
+	if f.tag != "" {
+		name += "#" + f.tag
+	}
+	return "urn:uuid:" + uuid.NewSHA1(f.user.ID, []byte(name)).String()
+}
+
+// postCategories lists the post's own categories followed by the user's tags.
+func postCategories(post database.GetPostsForUserFilteredRow) []string {
+	categories := slices.Clone(post.Categories)
+	for _, tag := range post.Tags {
+		if !slices.Contains(categories, tag) {
+			categories = append(categories, tag)
+		}
+	}
+	return categories
+}
+
+// description returns the post's HTML description, sanitized as posts
+// saved before agg sanitized them may hold anything, after the user's note
+// when notes are published.
+func (f publishedFeed) description(post database.GetPostsForUserFilteredRow) string {
+	base, _ := url.Parse(post.Url)
+	description := sanitizeFragment(post.Description.String, base)
+	if !f.notes || !post.Note.Valid {
+		return description
+	}
+	var b strings.Builder
+	b.WriteString("<blockquote>")
+	for _, para := range strings.Split(post.Note.String, "\n\n") {
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(strings.TrimSpace(para)), "\n", "<br>"))
+		b.WriteString("</p>")
+	}
+	b.WriteString("</blockquote>")
+	return b.String() + description
+}
+
+func (f publishedFeed) updated() time.Time {
+	updated := f.user.CreatedAt
+	for _, post := range f.posts {
+		if post.CreatedAt.After(updated) {
+			updated = post.CreatedAt
+		}
+	}
+	return updated
+}
+
+func postEntryID(post database.GetPostsForUserFilteredRow) string {
+	return "urn:uuid:" + post.ID.String()
+}
+
+func (f publishedFeed) writeAtom(w io.Writer) error {
+	doc := atomFeed{
+		ID:      f.id(),
+		Title:   f.title(),
+		Updated: f.updated().UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: f.user.Name},
+	}
+	if f.selfURL != "" {
+		doc.Links = append(doc.Links, atomLink{Href: f.selfURL, Rel: "self", Type: "application/atom+xml"})
+	}
+
+	for _, post := range f.posts {
+		entry := atomEntry{
+			ID:      postEntryID(post),
+			Title:   post.Title,
+			Link:    atomLink{Href: post.Url, Rel: "alternate"},
+			Updated: postSortKey("published", post).UTC().Format(time.RFC3339),
+			Source:  atomSource{Title: post.FeedName, Link: atomLink{Href: post.FeedUrl, Rel: "self"}},
+		}
+		if post.PublishedAt.Valid {
+			entry.Published = post.PublishedAt.Time.UTC().Format(time.RFC3339)
+		}
+		if post.Author.Valid && post.Author.String != "" {
+			entry.Author = &atomPerson{Name: post.Author.String}
+		}
+		for _, c := range postCategories(post) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		if description := f.description(post); description != "" {
+			entry.Summary = &atomText{Type: "html", Body: description}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return writeXML(w, doc)
+}
+
+func (f publishedFeed) writeRSS(w io.Writer) error {
+	// an RSS 2.0 channel must link somewhere, and the feed's own URL is all
+	// there is
+	if f.selfURL == "" {
+		return errRSSNeedsURL
+	}
+	doc := rssDocument{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         f.title(),
+			Link:          f.selfURL,
+			Description:   "Posts from the feeds " + f.user.Name + " follows, collected by gator",
+			LastBuildDate: f.updated().UTC().Format(time.RFC1123Z),
+		},
+	}
+	doc.Channel.Self = &rssSelf{Href: f.selfURL, Rel: "self", Type: "application/rss+xml"}
+
+	for _, post := range f.posts {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       post.Title,
+			Link:        post.Url,
+			GUID:        rssGUID{Value: postEntryID(post)},
+			PubDate:     postSortKey("published", post).UTC().Format(time.RFC1123Z),
+			Creator:     post.Author.String,
+			Categories:  postCategories(post),
+			Source:      rssItemSource{URL: post.FeedUrl, Title: post.FeedName},
+			Description: f.description(post),
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func (f publishedFeed) write(w io.Writer, kind string) error {
+	switch kind {
+	case "atom":
+		return f.writeAtom(w)
+	case "rss":
+		return f.writeRSS(w)
+	}
+	return fmt.Errorf("unknown feed type %q: expected atom or rss", kind)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode feed: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// loadPublishedFeed collects the newest posts for a published feed. Posts
+// are sorted by fetch time so entries arriving late still show up on top.
+func loadPublishedFeed(ctx context.Context, db database.Querier, user database.User, category, tag string, limit int) (publishedFeed, error) {
+	tag = normalizeTag(tag)
+	posts, _, err := listPosts(ctx, db, user, browseOptions{
+		limit:    limit,
+		category: category,
+		tag:      tag,
+		sortBy:   "fetched",
+	})
+	if err != nil {
+		return publishedFeed{}, err
+	}
+	return publishedFeed{user: user, category: category, tag: tag, posts: posts}, nil
+}
+
+func publishFlags(fs *flag.FlagSet) {
+	fs.String("out", "gator.atom", "file to write, or - for stdout")
+	fs.String("type", "", "feed type: atom|rss (default from the --out extension, else atom)")
+	fs.String("category", "", "only posts in this category")
+	fs.String("tag", "", "only posts you tagged with this tag")
+	fs.Bool("notes", false, "include your notes above the post descriptions")
+	fs.Int("limit", publishLimit, "number of posts to include")
+	fs.String("url", "", "public URL of the written file, used as the feed's self link")
+}
+
+func handlerPublish(s *State, cmd Command, user database.User) error {
+	out := cmd.flagString("out")
+	kind := cmd.flagString("type")
+	if kind == "" {
+		kind = "atom"
+		if ext := strings.ToLower(filepath.Ext(out)); ext == ".rss" || ext == ".xml" {
+			kind = "rss"
+		}
+	}
+	if kind != "atom" && kind != "rss" {
+		return fmt.Errorf("unknown feed type %q: expected atom or rss", kind)
+	}
+	if kind == "rss" && cmd.flagString("url") == "" {
+		return errRSSNeedsURL
+	}
+	limit := cmd.flagInt("limit")
+	if limit <= 0 {
+		return fmt.Errorf("invalid limit: %d", limit)
+	}
+
+	feed, err := loadPublishedFeed(context.Background(), s.db, user, cmd.flagString("category"), cmd.flagString("tag"), limit)
+	if err != nil {
+		return err
+	}
+	feed.selfURL = cmd.flagString("url")
+	feed.notes = cmd.flagBool("notes")
+
+	if out == "-" {
+		return feed.write(os.Stdout, kind)
+	}
+
+	if err := writeFileAtomic(out, func(w io.Writer) error { return feed.write(w, kind) }); err != nil {
+		return err
+	}
+
+	s.out.notef("Published %d posts for %s to %s (%s)\n", len(feed.posts), user.Name, out, kind)
+	return nil
+}
+
+// writeFileAtomic writes beside path and renames, so a web server never
+// serves a half-written file.
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// handlePublishedFeed serves the same documents as `gator publish` at
+// /users/{name}/feed.atom and feed.rss; ?category= and ?tag= narrow them.
+// Feed readers can't send an API key, so ?token= takes one of the user's
+// feed tokens (see `gator feedtoken`) instead. The feeds leave out the
+// user's notes, since subscription URLs get shared.
+func (a *apiServer) handlePublishedFeed(kind string) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		// unknown users and bad tokens look the same, so neither reveals
+		// who has an account
+		notFound := apiErrorf(http.StatusNotFound, "no feed at %s", r.URL.Path)
+		user, err := a.s.db.GetUserByName(r.Context(), r.PathValue("name"))
+		if errors.Is(err, sql.ErrNoRows) {
+			return notFound
+		}
+		if err != nil {
+			return err
+		}
+		query := r.URL.Query()
+		if ok, err := checkFeedToken(r.Context(), a.s.db, user, query.Get("token")); err != nil {
+			return err
+		} else if !ok {
+			return notFound
+		}
+
+		limit := publishLimit
+		if v := query.Get("limit"); v != "" {
+			if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > 500 {
+				return apiErrorf(http.StatusBadRequest, "invalid limit: %s", v)
+			}
+		}
+		feed, err := loadPublishedFeed(r.Context(), a.s.db, user, query.Get("category"), query.Get("tag"), limit)
+		if err != nil {
+			return err
+		}
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		feed.selfURL = scheme + "://" + r.Host + r.URL.RequestURI()
+
+		w.Header().Set("Content-Type", "application/"+kind+"+xml; charset=utf-8")
+		return feed.write(w, kind)
+	}
+}
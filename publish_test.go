@@ -0,0 +1,236 @@
+package main
+
+import (
+	"blog/internal/database"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testPublishedFeed() publishedFeed {
+	user := database.User{ID: uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8"), Name: "alice"}
+	return publishedFeed{
+		user:     user,
+		category: "go",
+		selfURL:  "http://localhost:8080/users/alice/feed.atom?category=go&limit=5",
+		posts: []database.GetPostsForUserFilteredRow{{
+			ID:          uuid.MustParse("0e2a5c1c-4b7e-4d2e-9a59-58d1f3e8a001"),
+			CreatedAt:   time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+			Title:       "Generics & <interfaces>",
+			Url:         "https://example.com/post?a=1&b=2",
+			Description: sql.NullString{String: "<p>Tom &amp; Jerry</p>", Valid: true},
+			PublishedAt: sql.NullTime{Time: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), Valid: true},
+			Author:      sql.NullString{String: "Rob", Valid: true},
+			Categories:  []string{"go", "generics"},
+			FeedName:    "Go Blog",
+			FeedUrl:     "https://go.dev/blog/feed.atom",
+		}},
+	}
+}
+
+func TestPublishAtom(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testPublishedFeed().write(&buf, "atom"); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		ID      string `xml:"id"`
+		Entries []struct {
+			ID    string `xml:"id"`
+			Title string `xml:"title"`
+			Link  struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+			Summary string `xml:"summary"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, buf.String())
+	}
+
+	if again := testPublishedFeed().id(); doc.ID != again || !strings.HasPrefix(doc.ID, "urn:uuid:") {
+		t.Errorf("feed id = %q, want stable urn:uuid (%q)", doc.ID, again)
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(doc.Entries))
+	}
+	e := doc.Entries[0]
+	if e.ID != "urn:uuid:0e2a5c1c-4b7e-4d2e-9a59-58d1f3e8a001" {
+		t.Errorf("entry id = %q", e.ID)
+	}
+	if e.Title != "Generics & <interfaces>" || e.Link.Href != "https://example.com/post?a=1&b=2" {
+		t.Errorf("entry title/link not round-tripped: %q %q", e.Title, e.Link.Href)
+	}
+	if e.Summary != "<p>Tom &amp; Jerry</p>" {
+		t.Errorf("summary = %q", e.Summary)
+	}
+}
+
+func TestPublishRSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testPublishedFeed().write(&buf, "rss"); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Version string   `xml:"version,attr"`
+		Links   []string `xml:"channel>link"` // <link> and the empty <atom:link>
+		Items   []struct {
+			Title   string `xml:"title"`
+			GUID    string `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+			Creator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, buf.String())
+	}
+
+	if doc.Version != "2.0" || len(doc.Items) != 1 {
+		t.Fatalf("version %q with %d items, want 2.0 with 1", doc.Version, len(doc.Items))
+	}
+	if !slices.Contains(doc.Links, testPublishedFeed().selfURL) {
+		t.Errorf("channel links = %q", doc.Links)
+	}
+	item := doc.Items[0]
+	if item.Title != "Generics & <interfaces>" || item.GUID != "urn:uuid:0e2a5c1c-4b7e-4d2e-9a59-58d1f3e8a001" {
+		t.Errorf("item = %+v", item)
+	}
+	if item.PubDate != "Sun, 18 Oct 2026 12:00:00 +0000" {
+		t.Errorf("pubDate = %q", item.PubDate)
+	}
+	if item.Creator != "Rob" {
+		t.Errorf("dc:creator = %q", item.Creator)
+	}
+
+	// without a URL there's nothing for the required channel link
+	feed := testPublishedFeed()
+	feed.selfURL = ""
+	if err := feed.write(io.Discard, "rss"); err != errRSSNeedsURL {
+		t.Errorf("rss without a url: err = %v", err)
+	}
+}
+
+func TestPublishTagsAndNotes(t *testing.T) {
+	feed := testPublishedFeed()
+	feed.posts[0].Tags = []string{"generics", "team-reading"}
+	feed.posts[0].Note = sql.NullString{String: "Read before Friday's <sync>.\n\nSee section 2.", Valid: true}
+
+	rss := func(feed publishedFeed) (categories []string, description string) {
+		var buf bytes.Buffer
+		if err := feed.write(&buf, "rss"); err != nil {
+			t.Fatal(err)
+		}
+		var doc struct {
+			Items []struct {
+				Categories  []string `xml:"category"`
+				Description string   `xml:"description"`
+			} `xml:"channel>item"`
+		}
+		if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("output is not valid XML: %v\n%s", err, buf.String())
+		}
+		return doc.Items[0].Categories, doc.Items[0].Description
+	}
+
+	categories, description := rss(feed)
+	if want := []string{"go", "generics", "team-reading"}; !slices.Equal(categories, want) {
+		t.Errorf("categories = %q, want %q", categories, want)
+	}
+	if description != "<p>Tom &amp; Jerry</p>" {
+		t.Errorf("notes published without --notes: %q", description)
+	}
+
+	feed.notes = true
+	_, description = rss(feed)
+	if want := "<blockquote><p>Read before Friday&#39;s &lt;sync&gt;.</p><p>See section 2.</p></blockquote><p>Tom &amp; Jerry</p>"; description != want {
+		t.Errorf("description = %q, want %q", description, want)
+	}
+
+	feed.tag = "team-reading"
+	if feed.id() == testPublishedFeed().id() || feed.title() != "alice's gator: go #team-reading" {
+		t.Errorf("tagged feed id %q, title %q", feed.id(), feed.title())
+	}
+}
+
+func TestPublishedFeedToken(t *testing.T) {
+	feedSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer feedSrv.Close()
+
+	h := newHandlerTest(t)
+	h.mustRun("correct horse\ncorrect horse\n", "register", "bob")
+	bob, err := h.s.db.GetUserByName(context.Background(), "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobToken, _, err := createFeedToken(context.Background(), h.s.db, bob, "reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", feedSrv.URL+"/rss")
+	scrapeFeeds(h.s)
+	alice, err := h.s.db.GetUserByName(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, feedToken, err := createFeedToken(context.Background(), h.s.db, alice, "reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer((&apiServer{s: h.s}).routes())
+	defer srv.Close()
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	for _, path := range []string{
+		"/users/alice/feed.atom",
+		"/users/alice/feed.rss?token=" + bobToken,
+		"/users/alice/feed.atom?token=gtf_nope",
+		"/users/nobody/feed.atom?token=" + token,
+	} {
+		if status, _ := get(path); status != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, status)
+		}
+	}
+	status, body := get("/users/alice/feed.rss?token=" + token)
+	if status != http.StatusOK || !strings.Contains(body, "Newer post") || !strings.Contains(body, "<link>"+srv.URL+"/users/alice/feed.rss?token=") {
+		t.Errorf("GET feed.rss with the token = %d:\n%s", status, body)
+	}
+
+	h.s.sStruct.APIKey = ""
+	h.mustRun("hunter2!\n", "login", "alice")
+	h.mustRun("", "feedtoken", "revoke", feedToken.Prefix)
+	if status, _ := get("/users/alice/feed.atom?token=" + token); status != http.StatusNotFound {
+		t.Errorf("GET with a revoked token = %d, want 404", status)
+	}
+
+	u, _ := url.Parse("/users/alice/feed.atom?category=go&token=" + token)
+	if got := loggedURI(u); strings.Contains(got, token) {
+		t.Errorf("logged uri %q has the token", got)
+	}
+}
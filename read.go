@@ -0,0 +1,519 @@
+package main
+
+import (
+	"blog/internal/database"
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+const (
+	paneFeeds = iota
+	panePosts
+	paneBody
+)
+
+// readerPostLimit caps how many posts are loaded per feed.
+const readerPostLimit = 200
+
+type readerFeed struct {
+	Name string
+	Url  string // "" selects posts from every followed feed
+}
+
+// readerSource is everything the reader needs from the database, so the
+// screen logic can be driven by a scripted key sequence without a terminal.
+type readerSource interface {
+	Feeds() ([]readerFeed, error)
+	Posts(feedURL string) ([]database.GetPostsForUserFilteredRow, error)
+	SetRead(postID uuid.UUID, read bool) error
+	SetStarred(postID uuid.UUID, starred bool) error
+}
+
+type dbReaderSource struct {
+	db   database.Querier
+	user database.User
+}
+
+func (src dbReaderSource) Feeds() ([]readerFeed, error) {
+	follows, err := src.db.GetFeedFollowsForUser(context.Background(), src.user.ID)
+	if err != nil {
+		return nil, err
+	}
+	feeds := []readerFeed{{Name: "All feeds"}}
+	for _, f := range follows {
+		feeds = append(feeds, readerFeed{Name: f.FeedName, Url: f.FeedUrl})
+	}
+	return feeds, nil
+}
+
+func (src dbReaderSource) Posts(feedURL string) ([]database.GetPostsForUserFilteredRow, error) {
+	return src.db.GetPostsForUserFiltered(context.Background(), database.GetPostsForUserFilteredParams{
+		UserID: src.user.ID,
+		Feed:   sql.NullString{String: feedURL, Valid: feedURL != ""},
+		SortBy: "published",
+		Lim:    readerPostLimit,
+	})
+}
+
+func (src dbReaderSource) SetRead(postID uuid.UUID, read bool) error {
+	return src.db.SetPostRead(context.Background(), database.SetPostReadParams{
+		UserID: src.user.ID,
+		PostID: postID,
+		Read:   read,
+	})
+}
+
+func (src dbReaderSource) SetStarred(postID uuid.UUID, starred bool) error {
+	return src.db.SetPostStarred(context.Background(), database.SetPostStarredParams{
+		UserID:  src.user.ID,
+		PostID:  postID,
+		Starred: starred,
+	})
+}
+
+type reader struct {
+	src     readerSource
+	out     io.Writer
+	newline string // "\r\n" in raw mode
+	clear   bool   // redraw in place instead of appending frames
+	width   int
+	height  int
+	openURL func(string) error
+
+	feeds      []readerFeed
+	posts      []database.GetPostsForUserFilteredRow
+	pane       int
+	feedIdx    int
+	postIdx    int
+	bodyScroll int
+	status     string
+}
+
+func readFlags(fs *flag.FlagSet) {
+	fs.String("script", "", "replay these keys without a terminal and print every frame (for testing)")
+	fs.Int("width", 100, "screen width in --script mode")
+	fs.Int("height", 30, "screen height in --script mode")
+}
+
+func handlerRead(s *State, cmd Command, user database.User) error {
+	r := &reader{
+		src:     dbReaderSource{db: s.db, user: user},
+		out:     s.out.w,
+		newline: "\n",
+		openURL: openInBrowser,
+	}
+
+	var keys io.Reader
+	if cmd.flagPassed("script") {
+		r.width, r.height = cmd.flagInt("width"), cmd.flagInt("height")
+		keys = strings.NewReader(cmd.flagString("script"))
+		r.openURL = func(string) error { return nil }
+	} else {
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			return fmt.Errorf("read needs a terminal; use --script to run it headless")
+		}
+		oldState, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to enter raw mode: %w", err)
+		}
+		defer term.Restore(fd, oldState)
+
+		// Alternate screen, hidden cursor; undone on exit
+		fmt.Print("\x1b[?1049h\x1b[?25l")
+		defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+		r.width, r.height, err = term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			r.width, r.height = 80, 24
+		}
+		r.newline = "\r\n"
+		r.clear = true
+		keys = os.Stdin
+	}
+
+	return r.run(keys)
+}
+
+// run loads the feeds, then draws a frame after every key until q or the end
+// of the input.
+func (r *reader) run(input io.Reader) error {
+	feeds, err := r.src.Feeds()
+	if err != nil {
+		return fmt.Errorf("couldn't load feeds: %w", err)
+	}
+	r.feeds = feeds
+	if err := r.loadPosts(); err != nil {
+		return err
+	}
+
+	in := bufio.NewReader(input)
+	r.render()
+	for {
+		key, err := readKey(in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if quit := r.handleKey(key); quit {
+			return nil
+		}
+		r.render()
+	}
+}
+
+// readKey decodes one key press, turning arrow escape sequences into names.
+func readKey(in *bufio.Reader) (string, error) {
+	b, err := in.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch b {
+	case '\r', '\n':
+		return "enter", nil
+	case '\t':
+		return "tab", nil
+	case ' ':
+		return "space", nil
+	case 3:
+		return "ctrl-c", nil
+	case 0x1b:
+		if in.Buffered() < 2 {
+			return "esc", nil
+		}
+		seq := make([]byte, 2)
+		if _, err := io.ReadFull(in, seq); err != nil {
+			return "", err
+		}
+		if seq[0] == '[' {
+			switch seq[1] {
+			case 'A':
+				return "up", nil
+			case 'B':
+				return "down", nil
+			case 'C':
+				return "right", nil
+			case 'D':
+				return "left", nil
+			case 'Z':
+				return "shift-tab", nil
+			}
+		}
+		return "esc", nil
+	}
+	return string(b), nil
+}
+
+func (r *reader) handleKey(key string) (quit bool) {
+	r.status = ""
+	switch key {
+	case "q", "ctrl-c":
+		return true
+	case "tab", "l", "right":
+		r.pane = min(r.pane+1, paneBody)
+	case "shift-tab", "h", "left", "esc":
+		r.pane = max(r.pane-1, paneFeeds)
+	case "j", "down":
+		r.move(1)
+	case "k", "up":
+		r.move(-1)
+	case "space":
+		if r.pane == paneBody {
+			r.bodyScroll += r.bodyHeight() - 1
+		}
+	case "enter":
+		switch r.pane {
+		case paneFeeds:
+			r.pane = panePosts
+		case panePosts:
+			if post := r.selectedPost(); post != nil {
+				r.pane = paneBody
+				if !post.ReadAt.Valid {
+					r.toggleRead()
+				}
+			}
+		}
+	case "r":
+		r.toggleRead()
+	case "s":
+		r.toggleStar()
+	case "o":
+		if post := r.selectedPost(); post != nil {
+			if err := r.openURL(post.Url); err != nil {
+				r.status = "couldn't open browser: " + err.Error()
+			} else {
+				r.status = "opened " + post.Url
+			}
+		}
+	case "?":
+		r.status = "j/k move  tab/h/l switch pane  enter open  r read  s star  o browser  q quit"
+	}
+	return false
+}
+
+func (r *reader) move(delta int) {
+	switch r.pane {
+	case paneFeeds:
+		idx := clamp(r.feedIdx+delta, 0, len(r.feeds)-1)
+		if idx != r.feedIdx {
+			r.feedIdx = idx
+			if err := r.loadPosts(); err != nil {
+				r.status = err.Error()
+			}
+		}
+	case panePosts:
+		idx := clamp(r.postIdx+delta, 0, len(r.posts)-1)
+		if idx != r.postIdx {
+			r.postIdx = idx
+			r.bodyScroll = 0
+		}
+	case paneBody:
+		r.bodyScroll = max(r.bodyScroll+delta, 0)
+	}
+}
+
+func (r *reader) loadPosts() error {
+	feedURL := ""
+	if r.feedIdx < len(r.feeds) {
+		feedURL = r.feeds[r.feedIdx].Url
+	}
+	posts, err := r.src.Posts(feedURL)
+	if err != nil {
+		return fmt.Errorf("couldn't load posts: %w", err)
+	}
+	r.posts = posts
+	r.postIdx = 0
+	r.bodyScroll = 0
+	return nil
+}
+
+func (r *reader) selectedPost() *database.GetPostsForUserFilteredRow {
+	if r.postIdx < 0 || r.postIdx >= len(r.posts) {
+		return nil
+	}
+	return &r.posts[r.postIdx]
+}
+
+func (r *reader) toggleRead() {
+	post := r.selectedPost()
+	if post == nil {
+		return
+	}
+	read := !post.ReadAt.Valid
+	if err := r.src.SetRead(post.ID, read); err != nil {
+		r.status = "couldn't update post: " + err.Error()
+		return
+	}
+	post.ReadAt.Valid = read
+	if read {
+		r.status = "marked read"
+	} else {
+		r.status = "marked unread"
+	}
+}
+
+func (r *reader) toggleStar() {
+	post := r.selectedPost()
+	if post == nil {
+		return
+	}
+	starred := !post.StarredAt.Valid
+	if err := r.src.SetStarred(post.ID, starred); err != nil {
+		r.status = "couldn't update post: " + err.Error()
+		return
+	}
+	post.StarredAt.Valid = starred
+	if starred {
+		r.status = "starred"
+	} else {
+		r.status = "unstarred"
+	}
+}
+
+// Layout: feeds on the left, posts above the post body on the right, and a
+// status line at the bottom.
+func (r *reader) feedsWidth() int { return clamp(r.width/4, 12, 30) }
+func (r *reader) postsHeight() int {
+	return clamp((r.height-2)/3, 3, max(len(r.posts), 3))
+}
+func (r *reader) bodyHeight() int { return max(r.height-3-r.postsHeight(), 1) }
+
+func (r *reader) render() {
+	leftW := r.feedsWidth()
+	rightW := max(r.width-leftW-3, 10)
+	contentH := max(r.height-2, 4)
+
+	left := []string{paneTitle("Feeds", r.pane == paneFeeds)}
+	for i, f := range r.feeds {
+		left = append(left, selectable(f.Name, i == r.feedIdx))
+	}
+	left = scrollTo(left, r.feedIdx+1, contentH)
+
+	right := []string{paneTitle(fmt.Sprintf("Posts (%d)", len(r.posts)), r.pane == panePosts)}
+	postLines := make([]string, 0, len(r.posts))
+	for i, p := range r.posts {
+		marks := " "
+		if !p.ReadAt.Valid {
+			marks = "•"
+		}
+		if p.StarredAt.Valid {
+			marks += "★"
+		} else {
+			marks += " "
+		}
+		postLines = append(postLines, selectable(marks+" "+p.Title, i == r.postIdx))
+	}
+	right = append(right, padLines(scrollTo(postLines, r.postIdx, r.postsHeight()), r.postsHeight())...)
+
+	right = append(right, paneTitle("Post", r.pane == paneBody))
+	body := r.bodyLines(rightW)
+	r.bodyScroll = clamp(r.bodyScroll, 0, max(len(body)-r.bodyHeight(), 0))
+	right = append(right, body[r.bodyScroll:]...)
+
+	var b strings.Builder
+	if r.clear {
+		b.WriteString("\x1b[H\x1b[2J")
+	}
+	for i := 0; i < contentH; i++ {
+		b.WriteString(fit(lineAt(left, i), leftW))
+		b.WriteString(" │ ")
+		b.WriteString(strings.TrimRight(fit(lineAt(right, i), rightW), " "))
+		b.WriteString(r.newline)
+	}
+	b.WriteString(strings.Repeat("─", r.width) + r.newline)
+	status := r.status
+	if status == "" {
+		status = "? for help"
+	}
+	b.WriteString(strings.TrimRight(fit(status, r.width), " "))
+	if !r.clear {
+		b.WriteString(r.newline)
+	}
+	fmt.Fprint(r.out, b.String())
+}
+
+func (r *reader) bodyLines(width int) []string {
+	post := r.selectedPost()
+	if post == nil {
+		return []string{"No posts."}
+	}
+	return postLines(*post, width)
+}
+
+// postLines lays a post out for the terminal: title, feed, date and link,
+// then its text rendered by renderHTML.
+func postLines(post database.GetPostsForUserFilteredRow, width int) []string {
+	date := post.CreatedAt
+	if post.PublishedAt.Valid {
+		date = post.PublishedAt.Time
+	}
+	lines := wrapText(post.Title, width)
+	meta := post.FeedName + " · " + date.Format("Mon Jan 2 2006")
+	if post.Author.Valid {
+		meta += " · " + post.Author.String
+	}
+	lines = append(lines, meta, post.Url, "")
+	return append(lines, renderHTML(postBody(post), width)...)
+}
+
+func paneTitle(title string, focused bool) string {
+	if focused {
+		return "[" + title + "]"
+	}
+	return " " + title
+}
+
+func selectable(text string, selected bool) string {
+	if selected {
+		return "> " + text
+	}
+	return "  " + text
+}
+
+// scrollTo returns at most height lines of lines, keeping index visible.
+func scrollTo(lines []string, index, height int) []string {
+	if len(lines) <= height {
+		return lines
+	}
+	start := clamp(index-height+1, 0, len(lines)-height)
+	return lines[start : start+height]
+}
+
+func padLines(lines []string, height int) []string {
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+// fit pads or truncates s to exactly width runes.
+func fit(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:max(width-1, 0)]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+func wrapText(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func clamp(v, lo, hi int) int {
+	if hi < lo {
+		return lo
+	}
+	return min(max(v, lo), hi)
+}
+
+// openInBrowser starts $BROWSER, falling back to the platform's opener.
+func openInBrowser(url string) error {
+	browser := os.Getenv("BROWSER")
+	if browser == "" {
+		switch runtime.GOOS {
+		case "darwin":
+			browser = "open"
+		case "windows":
+			return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+		default:
+			browser = "xdg-open"
+		}
+	}
+	return exec.Command(browser, url).Start()
+}
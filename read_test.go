@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const readerRSS = `<?xml version="1.0"?>
+<rss version="2.0"><channel>
+<title>Test Blog</title>
+<item><title>Older post</title><link>%[1]s/older</link><pubDate>Mon, 05 Oct 2026 10:00:00 +0000</pubDate>
+  <description>&lt;p&gt;First paragraph, see &lt;a href="https://go.dev/doc"&gt;the docs&lt;/a&gt;.&lt;/p&gt;&lt;ul&gt;&lt;li&gt;one&lt;/li&gt;&lt;/ul&gt;&lt;script&gt;x()&lt;/script&gt;</description></item>
+<item><title>Newer post</title><link>%[1]s/newer</link><pubDate>Tue, 06 Oct 2026 10:00:00 +0000</pubDate><author>Ann</author></item>
+</channel></rss>`
+
+// frames splits the output of read --script into its frames, each height
+// lines long.
+func frames(t *testing.T, out string, height int) [][]string {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines)%height != 0 {
+		t.Fatalf("%d lines is not a whole number of %d line frames:\n%s", len(lines), height, out)
+	}
+	var frames [][]string
+	for len(lines) > 0 {
+		frames = append(frames, lines[:height])
+		lines = lines[height:]
+	}
+	return frames
+}
+
+func TestReadScript(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, readerRSS, "http://"+r.Host)
+	}))
+	defer srv.Close()
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", srv.URL+"/rss")
+	scrapeFeeds(h.s)
+
+	// move to the posts, down to the older post, open it and star it
+	h.mustRun("", "read", "--script", "lj\rsq", "--width", "80", "--height", "20")
+	got := frames(t, h.out.String(), 20)
+	if len(got) != 5 {
+		t.Fatalf("%d frames, want one before the keys and one after each but q", len(got))
+	}
+
+	first := strings.Join(got[0], "\n")
+	for _, want := range []string{"[Feeds]", "> All feeds", "  Test Blog", "Posts (2)", "> •  Newer post", "  •  Older post", "? for help"} {
+		if !strings.Contains(first, want) {
+			t.Errorf("first frame lacks %q:\n%s", want, first)
+		}
+	}
+	if frame := strings.Join(got[2], "\n"); !strings.Contains(frame, "[Posts (2)]") || !strings.Contains(frame, "> •  Older post") {
+		t.Errorf("after j:\n%s", frame)
+	}
+	if frame := strings.Join(got[3], "\n"); !strings.Contains(frame, "[Post]") || !strings.Contains(frame, ">    Older post") || !strings.HasSuffix(frame, "marked read") {
+		t.Errorf("after enter:\n%s", frame)
+	}
+
+	last := strings.Join(got[4], "\n")
+	for _, want := range []string{
+		">  ★ Older post",
+		"│ Older post",
+		"│ Test Blog · Mon Oct 5 2026",
+		"│ First paragraph, see the docs[1].",
+		"│ • one",
+		"│ [1] https://go.dev/doc",
+	} {
+		if !strings.Contains(last, want) {
+			t.Errorf("last frame lacks %q:\n%s", want, last)
+		}
+	}
+	if strings.Contains(last, "x()") {
+		t.Errorf("last frame shows the script:\n%s", last)
+	}
+	if !strings.HasSuffix(last, "starred") {
+		t.Errorf("status = %q, want starred", got[4][19])
+	}
+
+	// the marks are saved
+	if got := h.titles("--unread", "--limit", "10"); !slices.Equal(got, []string{"Newer post"}) {
+		t.Errorf("unread = %q", got)
+	}
+	if got := h.titles("--starred", "--limit", "10"); !slices.Equal(got, []string{"Older post"}) {
+		t.Errorf("starred = %q", got)
+	}
+
+	// r and s toggle back
+	h.mustRun("", "read", "--script", "ljrs", "--width", "80", "--height", "20")
+	if got := frames(t, h.out.String(), 20); !strings.HasSuffix(strings.Join(got[len(got)-1], "\n"), "unstarred") {
+		t.Errorf("after rs:\n%s", strings.Join(got[len(got)-1], "\n"))
+	}
+	if got := h.titles("--unread", "--limit", "10"); len(got) != 2 {
+		t.Errorf("unread after r = %q", got)
+	}
+	if got := h.titles("--starred"); len(got) != 0 {
+		t.Errorf("starred after s = %q", got)
+	}
+}
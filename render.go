@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// minRenderWidth keeps deeply nested lists and quotes readable on narrow
+// terminals, at the cost of overflowing them.
+const minRenderWidth = 20
+
+// renderHTML lays out a post's HTML for the terminal, wrapped to width:
+// blocks are separated by blank lines, list items get bullets or numbers,
+// quotes a bar, code blocks keep their lines, images become [image: alt]
+// and links are numbered, with their URLs listed as footnotes at the end.
+// Whatever sanitizeHTML drops, such as scripts and styles, is skipped.
+func renderHTML(input string, width int) []string {
+	r := &textRenderer{width: width, footnotes: true}
+	return r.render(input)
+}
+
+// htmlText is the text of an HTML fragment, one line per block and without
+// footnotes, for table cells, summaries and matching.
+func htmlText(input string) string {
+	r := &textRenderer{}
+	var lines []string
+	for _, line := range r.render(input) {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textRenderer collects the text of the current block in inline and writes
+// it to lines, prefixed by the indent of the lists and quotes it is in,
+// when the block ends.
+type textRenderer struct {
+	width     int // 0 doesn't wrap
+	footnotes bool
+
+	lines  []string
+	inline strings.Builder
+	space  bool // whitespace is pending before the next word
+	blank  bool // a blank line is pending before the next block
+	indent []indentLevel
+	lists  []listLevel
+	pre    int
+	links  []string
+}
+
+// indentLevel is one level of nesting. first prefixes the first line written
+// inside it, such as a list item's bullet, and rest the lines after it.
+type indentLevel struct {
+	first, rest string
+	used        bool
+}
+
+type listLevel struct {
+	ordered bool
+	next    int
+}
+
+func (r *textRenderer) render(input string) []string {
+	doc, err := html.Parse(strings.NewReader(input))
+	if err != nil {
+		return strings.Split(input, "\n")
+	}
+	r.walk(doc)
+	r.endLine()
+	if len(r.links) > 0 {
+		r.lines = append(r.lines, "")
+		for i, link := range r.links {
+			r.lines = append(r.lines, fmt.Sprintf("[%d] %s", i+1, link))
+		}
+	}
+	return r.lines
+}
+
+func (r *textRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.ElementNode:
+		if sanitizeDropped[n.DataAtom] {
+			return
+		}
+	case html.DocumentNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		if r.pre > 0 {
+			r.inline.WriteByte('\n')
+		} else {
+			r.endLine()
+		}
+	case atom.Hr:
+		r.paragraph()
+		r.emit([]string{strings.Repeat("─", min(r.available(), 40))})
+		r.paragraph()
+	case atom.Img:
+		if alt := strings.Join(strings.Fields(attr(n, "alt")), " "); alt != "" {
+			r.word("[image: " + alt + "]")
+		} else {
+			r.word("[image]")
+		}
+	case atom.A:
+		start := r.inline.Len()
+		r.children(n)
+		r.link(n, start)
+	case atom.Code, atom.Kbd, atom.Samp:
+		if r.pre > 0 {
+			r.children(n)
+			break
+		}
+		r.word("`")
+		r.children(n)
+		r.attach("`")
+	case atom.Pre:
+		r.paragraph()
+		r.pre++
+		r.children(n)
+		r.pre--
+		r.code()
+		r.paragraph()
+	case atom.Ul, atom.Ol:
+		r.paragraph()
+		l := listLevel{ordered: n.DataAtom == atom.Ol, next: 1}
+		if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+			l.next = start
+		}
+		r.lists = append(r.lists, l)
+		r.children(n)
+		r.lists = r.lists[:len(r.lists)-1]
+		r.paragraph()
+	case atom.Li:
+		r.endLine()
+		marker := "• "
+		if len(r.lists) > 0 && r.lists[len(r.lists)-1].ordered {
+			l := &r.lists[len(r.lists)-1]
+			marker = strconv.Itoa(l.next) + ". "
+			l.next++
+		}
+		r.nest(marker, strings.Repeat(" ", utf8.RuneCountInString(marker)), n)
+	case atom.Blockquote:
+		r.paragraph()
+		r.nest("│ ", "│ ", n)
+		r.paragraph()
+	case atom.Dd:
+		r.endLine()
+		r.nest("  ", "  ", n)
+	case atom.Td, atom.Th:
+		if r.inline.Len() > 0 {
+			r.word("|")
+			r.space = true
+		}
+		r.children(n)
+		r.space = true
+	case atom.Tr, atom.Div, atom.Dt, atom.Figcaption, atom.Caption:
+		r.endLine()
+		r.children(n)
+		r.endLine()
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Table, atom.Figure,
+		atom.Article, atom.Section, atom.Header, atom.Footer, atom.Main, atom.Aside, atom.Address, atom.Dl, atom.Details:
+		r.paragraph()
+		r.children(n)
+		r.paragraph()
+	default:
+		r.children(n)
+	}
+}
+
+func (r *textRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+// nest renders n's children indented by first and rest.
+func (r *textRenderer) nest(first, rest string, n *html.Node) {
+	r.indent = append(r.indent, indentLevel{first: first, rest: rest})
+	r.children(n)
+	r.endLine()
+	r.indent = r.indent[:len(r.indent)-1]
+}
+
+// link numbers the link n whose text starts at start in inline. Links
+// whose text is their url, and links within the page, need no footnote.
+func (r *textRenderer) link(n *html.Node, start int) {
+	href := sanitizeURL(attr(n, "href"), nil, true)
+	if !r.footnotes || href == "" || strings.HasPrefix(href, "#") {
+		return
+	}
+	if start <= r.inline.Len() && strings.TrimSpace(r.inline.String()[start:]) == strings.TrimPrefix(href, "mailto:") {
+		return
+	}
+	i := 0
+	for i < len(r.links) && r.links[i] != href {
+		i++
+	}
+	if i == len(r.links) {
+		r.links = append(r.links, href)
+	}
+	r.attach(fmt.Sprintf("[%d]", i+1))
+}
+
+func (r *textRenderer) text(s string) {
+	if r.pre > 0 {
+		r.inline.WriteString(s)
+		return
+	}
+	if s == "" {
+		return
+	}
+	if first, _ := utf8.DecodeRuneInString(s); unicode.IsSpace(first) {
+		r.space = true
+	}
+	for i, w := range strings.Fields(s) {
+		if i > 0 {
+			r.space = true
+		}
+		r.word(w)
+	}
+	if last, _ := utf8.DecodeLastRuneInString(s); unicode.IsSpace(last) {
+		r.space = true
+	}
+}
+
+// word adds w to the block, after a space if one is pending.
+func (r *textRenderer) word(w string) {
+	if r.space && r.inline.Len() > 0 {
+		r.inline.WriteByte(' ')
+	}
+	r.space = false
+	r.inline.WriteString(w)
+}
+
+// attach adds s to the end of the last word, keeping any pending space for
+// the next one.
+func (r *textRenderer) attach(s string) {
+	space := r.space
+	r.space = false
+	r.word(s)
+	r.space = space
+}
+
+// endLine writes the text collected so far, wrapped.
+func (r *textRenderer) endLine() {
+	text := strings.TrimSpace(r.inline.String())
+	r.inline.Reset()
+	r.space = false
+	if text == "" {
+		return
+	}
+	if r.width <= 0 {
+		r.emit([]string{text})
+		return
+	}
+	r.emit(wrapText(text, r.available()))
+}
+
+// paragraph ends the block and leaves a blank line before the next one.
+func (r *textRenderer) paragraph() {
+	r.endLine()
+	r.blank = true
+}
+
+// code writes the text of a pre block as is, indented, without wrapping.
+func (r *textRenderer) code() {
+	text := strings.Trim(r.inline.String(), "\n")
+	r.inline.Reset()
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	lines := strings.Split(strings.ReplaceAll(text, "\t", "    "), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("    "+line, " ")
+	}
+	r.emit(lines)
+}
+
+// available is the width left for text inside the current indent.
+func (r *textRenderer) available() int {
+	width := r.width
+	for _, in := range r.indent {
+		width -= utf8.RuneCountInString(in.rest)
+	}
+	return max(width, minRenderWidth)
+}
+
+// emit writes lines, the first behind the bullets of any list items it
+// starts, after the pending blank line.
+func (r *textRenderer) emit(lines []string) {
+	var blank, first, rest strings.Builder
+	for i := range r.indent {
+		in := &r.indent[i]
+		if in.used {
+			blank.WriteString(in.rest)
+			first.WriteString(in.rest)
+		} else {
+			first.WriteString(in.first)
+			in.used = true
+		}
+		rest.WriteString(in.rest)
+	}
+	if r.blank && len(r.lines) > 0 {
+		r.lines = append(r.lines, strings.TrimRight(blank.String(), " "))
+	}
+	r.blank = false
+	for i, line := range lines {
+		prefix := rest.String()
+		if i == 0 {
+			prefix = first.String()
+		}
+		r.lines = append(r.lines, strings.TrimRight(prefix+line, " "))
+	}
+}
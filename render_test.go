@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	for _, tc := range []struct {
+		name, html string
+		width      int
+		want       string
+	}{
+		{
+			name:  "paragraphs wrap",
+			html:  "<p>One two three four five six.</p><p>Seven\n  eight.</p>",
+			width: 20,
+			want:  "One two three four\nfive six.\n\nSeven eight.",
+		},
+		{
+			name:  "scripts and styles are dropped",
+			html:  "<style>p { color: red }</style><p>Hi<script>alert(1)</script> there</p>",
+			width: 80,
+			want:  "Hi there",
+		},
+		{
+			name:  "links become footnotes",
+			html:  `<p>See <a href="https://go.dev/doc">the docs</a>, <a href="https://go.dev">https://go.dev</a>, <a href="#top">top</a> and <a href="https://go.dev/doc">again</a>.</p>`,
+			width: 80,
+			want:  "See the docs[1], https://go.dev, top and again[1].\n\n[1] https://go.dev/doc",
+		},
+		{
+			name:  "images",
+			html:  `<p><img src="a.png" alt="a  gopher"> and <img src="b.png"></p>`,
+			width: 80,
+			want:  "[image: a gopher] and [image]",
+		},
+		{
+			name:  "lists",
+			html:  `<ul><li>first item that wraps around here</li><li>second<ol start="3"><li>nested</li><li>more</li></ol></li></ul><p>after</p>`,
+			width: 24,
+			want:  "• first item that wraps\n  around here\n• second\n\n  3. nested\n  4. more\n\nafter",
+		},
+		{
+			name:  "code",
+			html:  "<p>Run <code>go test</code>:</p><pre><code>func main() {\n\tfmt.Println(\"hi\")\n}\n</code></pre>",
+			width: 20,
+			want:  "Run `go test`:\n\n    func main() {\n        fmt.Println(\"hi\")\n    }",
+		},
+		{
+			name:  "quotes and breaks",
+			html:  "<p>Before</p><blockquote><p>Quoted line<br>next</p><p>second</p></blockquote>",
+			width: 80,
+			want:  "Before\n\n│ Quoted line\n│ next\n│\n│ second",
+		},
+		{
+			name:  "tables",
+			html:  "<table><tr><th>Go</th><th>Rust</th></tr><tr><td>1.25</td><td>1.90</td></tr></table>",
+			width: 80,
+			want:  "Go | Rust\n1.25 | 1.90",
+		},
+		{
+			name:  "plain text",
+			html:  "Tom & Jerry",
+			width: 80,
+			want:  "Tom & Jerry",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := strings.Join(renderHTML(tc.html, tc.width), "\n"); got != tc.want {
+				t.Errorf("renderHTML(%q) =\n%s\nwant\n%s", tc.html, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTMLText(t *testing.T) {
+	got := htmlText(`<p>First <a href="https://go.dev">link</a>.</p><script>x()</script><ul><li>one</li><li>two</li></ul>`)
+	if want := "First link.\n• one\n• two"; got != want {
+		t.Errorf("htmlText = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rules act on posts whose title matches a pattern. hide, star and tag:<tag>
+// rules are applied once, when scrapeFeed saves a post (or to older posts
+// with `rule add --existing`); highlight rules are checked whenever posts are
+// listed, so they cover posts fetched before the rule too.
+
+const (
+	ruleHide      = "hide"
+	ruleHighlight = "highlight"
+	ruleStar      = "star"
+	ruleTagPrefix = "tag:"
+)
+
+// ruleScanPage is how many posts matchingPosts reads per query.
+const ruleScanPage = 500
+
+func ruleFlags(fs *flag.FlagSet) {
+	fs.String("feed", "", "only posts from the followed feed with this name or url")
+	fs.String("title-matches", "", "regular expression for the post title, case-insensitive")
+	fs.String("action", "", "hide|highlight|star|tag:<tag>")
+	fs.Bool("existing", false, "add: also apply the rule to posts already fetched")
+	fs.Int("limit", 20, "test: number of matching posts to show")
+}
+
+func handlerRule(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+
+	switch action := cmd.Args[0]; action {
+	case "add":
+		if len(cmd.Args) != 1 {
+			return fmt.Errorf("usage: gator rule add [--feed <feed>] --title-matches <regex> --action <action>")
+		}
+		rule, err := newRule(ctx, s.db, user, cmd.flagString("feed"), cmd.flagString("title-matches"), cmd.flagString("action"))
+		if err != nil {
+			return err
+		}
+		if rule.Rule, err = s.db.CreateRule(ctx, database.CreateRuleParams(rule.Rule)); err != nil {
+			return fmt.Errorf("failed to add rule: %w", err)
+		}
+		s.out.notef("Added rule %s\n", rule.ID)
+
+		if cmd.flagBool("existing") {
+			posts, err := matchingPosts(ctx, s.db, user, rule)
+			if err != nil {
+				return err
+			}
+			for _, post := range posts {
+				if err := applyRule(ctx, s.db, rule, post.ID); err != nil {
+					return fmt.Errorf("failed to apply rule to %s: %w", post.Url, err)
+				}
+			}
+			s.out.notef("Applied it to %d existing posts\n", len(posts))
+		}
+		return nil
+
+	case "list":
+		if len(cmd.Args) != 1 {
+			return fmt.Errorf("usage: gator rule list")
+		}
+		rules, err := s.db.ListRules(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list rules: %w", err)
+		}
+		if len(rules) == 0 {
+			s.out.notef("No rules yet: add one with 'gator rule add --title-matches <regex> --action <action>'\n")
+		}
+		return s.out.render(rulesTable(rules))
+
+	case "remove":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator rule remove <rule_id>")
+		}
+		rule, err := getRule(ctx, s.db, user, cmd.Args[1])
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.DeleteRule(ctx, database.DeleteRuleParams{UserID: user.ID, ID: rule.ID}); err != nil {
+			return fmt.Errorf("failed to remove rule: %w", err)
+		}
+		s.out.notef("Removed rule %s\n", rule.ID)
+		if rule.Action == ruleHide {
+			n, err := unhidePosts(ctx, s.db, user, rule)
+			if err != nil {
+				return err
+			}
+			s.out.notef("%d posts it hid are shown again\n", n)
+		}
+		return nil
+
+	case "test":
+		var rule compiledRule
+		var err error
+		switch len(cmd.Args) {
+		case 1:
+			// Only the pattern and feed matter for a preview
+			rule, err = newRule(ctx, s.db, user, cmd.flagString("feed"), cmd.flagString("title-matches"), ruleHighlight)
+		case 2:
+			rule, err = getRule(ctx, s.db, user, cmd.Args[1])
+		default:
+			return fmt.Errorf("usage: gator rule test <rule_id> | gator rule test [--feed <feed>] --title-matches <regex>")
+		}
+		if err != nil {
+			return err
+		}
+		posts, err := matchingPosts(ctx, s.db, user, rule)
+		if err != nil {
+			return err
+		}
+		s.out.notef("%d existing posts match:\n", len(posts))
+		rows := make([][]any, 0, len(posts))
+		for _, post := range posts[:min(len(posts), max(cmd.flagInt("limit"), 0))] {
+			rows = append(rows, []any{post.ID, post.Title, post.FeedName, post.PublishedAt})
+		}
+		return s.out.render([]string{"id", "title", "feed_name", "published_at"}, rows)
+
+	default:
+		return fmt.Errorf("unknown rule action %q: expected add, list, remove or test", action)
+	}
+}
+
+// compiledRule is a rule with its title pattern compiled.
+type compiledRule struct {
+	database.Rule
+	re *regexp.Regexp
+}
+
+func compileRule(rule database.Rule) (compiledRule, error) {
+	re, err := regexp.Compile("(?i)" + rule.TitlePattern)
+	if err != nil {
+		return compiledRule{}, fmt.Errorf("invalid title pattern %q: %w", rule.TitlePattern, err)
+	}
+	return compiledRule{Rule: rule, re: re}, nil
+}
+
+func (r compiledRule) matches(feedID uuid.UUID, title string) bool {
+	return (!r.FeedID.Valid || r.FeedID.UUID == feedID) && r.re.MatchString(title)
+}
+
+// newRule checks the flags of `rule add` and `rule test`.
+func newRule(ctx context.Context, db database.Querier, user database.User, feed, pattern, action string) (compiledRule, error) {
+	if pattern == "" {
+		return compiledRule{}, fmt.Errorf("--title-matches is required")
+	}
+	if action == "" {
+		return compiledRule{}, fmt.Errorf("--action is required: hide, highlight, star or tag:<tag>")
+	}
+	switch {
+	case action == ruleHide, action == ruleHighlight, action == ruleStar:
+	case strings.HasPrefix(action, ruleTagPrefix):
+		tag := normalizeTag(strings.TrimPrefix(action, ruleTagPrefix))
+		if err := validTag(tag); err != nil {
+			return compiledRule{}, err
+		}
+		action = ruleTagPrefix + tag
+	default:
+		return compiledRule{}, fmt.Errorf("unknown rule action %q: expected hide, highlight, star or tag:<tag>", action)
+	}
+
+	feedID, err := followedFeedID(ctx, db, user, feed)
+	if err != nil {
+		return compiledRule{}, err
+	}
+
+	return compileRule(database.Rule{
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		UserID:       user.ID,
+		FeedID:       feedID,
+		TitlePattern: pattern,
+		Action:       action,
+	})
+}
+
+// followedFeedID resolves the --feed flag of rules and watches to one of the
+// user's followed feeds; an empty name means every feed.
+func followedFeedID(ctx context.Context, db database.Querier, user database.User, feed string) (uuid.NullUUID, error) {
+	if feed == "" {
+		return uuid.NullUUID{}, nil
+	}
+	follows, err := db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("failed to get follows: %w", err)
+	}
+	for _, f := range follows {
+		if f.FeedUrl == feed || f.FeedName == feed {
+			return uuid.NullUUID{UUID: f.FeedID, Valid: true}, nil
+		}
+	}
+	return uuid.NullUUID{}, fmt.Errorf("you don't follow %s", feed)
+}
+
+func getRule(ctx context.Context, db database.Querier, user database.User, id string) (compiledRule, error) {
+	ruleID, err := uuid.Parse(id)
+	if err != nil {
+		return compiledRule{}, fmt.Errorf("invalid rule id %q: see 'gator rule list'", id)
+	}
+	rule, err := db.GetRule(ctx, database.GetRuleParams{UserID: user.ID, ID: ruleID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return compiledRule{}, fmt.Errorf("no rule %s: see 'gator rule list'", id)
+	}
+	if err != nil {
+		return compiledRule{}, fmt.Errorf("failed to get rule: %w", err)
+	}
+	return compileRule(rule)
+}
+
+// listedRules compiles the user's rules with the given action.
+func listedRules(ctx context.Context, db database.Querier, user database.User, action string) ([]compiledRule, error) {
+	rows, err := db.ListRules(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rules: %w", err)
+	}
+	var rules []compiledRule
+	for _, r := range rows {
+		if r.Action != action {
+			continue
+		}
+		rule, err := compileRule(database.Rule{
+			ID:           r.ID,
+			CreatedAt:    r.CreatedAt,
+			UserID:       r.UserID,
+			FeedID:       r.FeedID,
+			TitlePattern: r.TitlePattern,
+			Action:       r.Action,
+		})
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// feedRules returns the compiled rules of everyone following a feed. A rule
+// that fails to compile is reported and left out.
+func feedRules(ctx context.Context, db database.Querier, feedID uuid.UUID) ([]compiledRule, error) {
+	rows, err := db.ListRulesForFeed(ctx, feedID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get rules: %w", err)
+	}
+	var rules []compiledRule
+	var errs []error
+	for _, r := range rows {
+		rule, err := compileRule(r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", r.ID, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, errors.Join(errs...)
+}
+
+// applyRule carries out a rule's action on a post for the rule's owner.
+func applyRule(ctx context.Context, db database.Querier, rule compiledRule, postID uuid.UUID) error {
+	switch {
+	case rule.Action == ruleHide:
+		return db.SetPostHidden(ctx, database.SetPostHiddenParams{UserID: rule.UserID, PostID: postID, Hidden: true})
+	case rule.Action == ruleStar:
+		return db.SetPostStarred(ctx, database.SetPostStarredParams{UserID: rule.UserID, PostID: postID, Starred: true})
+	case strings.HasPrefix(rule.Action, ruleTagPrefix):
+		return tagPost(ctx, db, database.User{ID: rule.UserID}, postID, strings.TrimPrefix(rule.Action, ruleTagPrefix))
+	}
+	return nil
+}
+
+// matchingPosts returns the user's posts the rule matches, hidden ones
+// included, newest first.
+func matchingPosts(ctx context.Context, db database.Querier, user database.User, rule compiledRule) ([]database.GetPostsForUserFilteredRow, error) {
+	opts := browseOptions{limit: ruleScanPage, sortBy: "published", hidden: true}
+	var matched []database.GetPostsForUserFilteredRow
+	for {
+		posts, next, err := listPosts(ctx, db, user, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, post := range posts {
+			if rule.matches(post.FeedID, post.Title) {
+				matched = append(matched, post)
+			}
+		}
+		if next == "" {
+			return matched, nil
+		}
+		opts.cursor = next
+	}
+}
+
+// unhidePosts shows the posts a removed hide rule matches again, unless
+// another hide rule still matches them.
+func unhidePosts(ctx context.Context, db database.Querier, user database.User, removed compiledRule) (int, error) {
+	remaining, err := listedRules(ctx, db, user, ruleHide)
+	if err != nil {
+		return 0, err
+	}
+	posts, err := matchingPosts(ctx, db, user, removed)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, post := range posts {
+		if !post.HiddenAt.Valid || anyRuleMatches(remaining, post.FeedID, post.Title) {
+			continue
+		}
+		if err := db.SetPostHidden(ctx, database.SetPostHiddenParams{UserID: user.ID, PostID: post.ID}); err != nil {
+			return n, fmt.Errorf("failed to unhide %s: %w", post.Url, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func anyRuleMatches(rules []compiledRule, feedID uuid.UUID, title string) bool {
+	for _, rule := range rules {
+		if rule.matches(feedID, title) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightedPosts marks the posts the user's highlight rules match.
+func highlightedPosts(ctx context.Context, db database.Querier, user database.User, posts []database.GetPostsForUserFilteredRow) (map[uuid.UUID]bool, error) {
+	rules, err := listedRules(ctx, db, user, ruleHighlight)
+	if err != nil {
+		return nil, err
+	}
+	highlighted := make(map[uuid.UUID]bool)
+	for _, post := range posts {
+		if anyRuleMatches(rules, post.FeedID, post.Title) {
+			highlighted[post.ID] = true
+		}
+	}
+	return highlighted, nil
+}
+
+func rulesTable(rules []database.ListRulesRow) ([]string, [][]any) {
+	rows := make([][]any, 0, len(rules))
+	for _, r := range rules {
+		feed := "(all)"
+		if r.FeedID.Valid {
+			feed = r.FeedName.String
+		}
+		rows = append(rows, []any{r.ID, feed, r.TitlePattern, r.Action, r.CreatedAt})
+	}
+	return []string{"id", "feed", "title_matches", "action", "created_at"}, rows
+}
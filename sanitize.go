@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// sanitizeAllowed maps the elements kept by sanitizeHTML to the attributes
+// they keep. Any other element is unwrapped: its children are kept without it.
+var sanitizeAllowed = map[atom.Atom][]string{
+	atom.A: {"href", "title"}, atom.Img: {"src", "alt", "title", "width", "height"},
+	atom.P: nil, atom.Br: nil, atom.Hr: nil, atom.Div: nil, atom.Span: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.Ul: nil, atom.Ol: {"start"}, atom.Li: nil, atom.Dl: nil, atom.Dt: nil, atom.Dd: nil,
+	atom.Blockquote: nil, atom.Pre: nil, atom.Code: nil, atom.Kbd: nil, atom.Samp: nil,
+	atom.Em: nil, atom.Strong: nil, atom.B: nil, atom.I: nil, atom.U: nil, atom.S: nil,
+	atom.Del: nil, atom.Ins: nil, atom.Mark: nil, atom.Small: nil, atom.Sub: nil, atom.Sup: nil,
+	atom.Abbr: {"title"}, atom.Cite: nil, atom.Q: nil, atom.Time: {"datetime"},
+	atom.Figure: nil, atom.Figcaption: nil, atom.Picture: nil,
+	atom.Table: nil, atom.Caption: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil,
+	atom.Tr: nil, atom.Th: {"colspan", "rowspan", "scope"}, atom.Td: {"colspan", "rowspan"},
+}
+
+// sanitizeDropped are removed together with everything inside them.
+var sanitizeDropped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Iframe: true, atom.Frame: true, atom.Frameset: true, atom.Object: true, atom.Embed: true,
+	atom.Applet: true, atom.Form: true, atom.Input: true, atom.Button: true, atom.Select: true,
+	atom.Textarea: true, atom.Svg: true, atom.Math: true, atom.Head: true, atom.Title: true,
+	atom.Meta: true, atom.Link: true, atom.Base: true, atom.Audio: true, atom.Video: true,
+	atom.Source: true, atom.Canvas: true, atom.Dialog: true,
+}
+
+// sanitizeFragment sanitizes HTML from a feed, such as a post's description.
+func sanitizeFragment(input string, base *url.URL) string {
+	nodes, err := html.ParseFragment(strings.NewReader(input), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return html.EscapeString(input)
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		sanitizeNode(&b, n, base)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// sanitizeHTML renders n keeping only the elements and attributes in
+// sanitizeAllowed. Relative links and image sources are resolved against
+// base, and any that aren't http, https (or mailto, for links) are dropped.
+func sanitizeHTML(n *html.Node, base *url.URL) string {
+	var b strings.Builder
+	sanitizeNode(&b, n, base)
+	return strings.TrimSpace(b.String())
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+		if sanitizeDropped[n.DataAtom] {
+			return
+		}
+	case html.DocumentNode:
+	default:
+		return
+	}
+
+	if n.DataAtom == atom.Img && sanitizeURL(attr(n, "src"), base, false) == "" {
+		return
+	}
+	attrs, ok := sanitizeAllowed[n.DataAtom]
+	ok = ok && n.Type == html.ElementNode
+	if ok {
+		b.WriteString("<" + n.Data)
+		for _, a := range n.Attr {
+			if a.Namespace != "" || !slices.Contains(attrs, a.Key) {
+				continue
+			}
+			val := a.Val
+			if a.Key == "href" || a.Key == "src" {
+				if val = sanitizeURL(val, base, a.Key == "href"); val == "" {
+					continue
+				}
+			}
+			b.WriteString(" " + a.Key + `="` + html.EscapeString(val) + `"`)
+		}
+		b.WriteString(">")
+		if n.DataAtom == atom.Br || n.DataAtom == atom.Hr || n.DataAtom == atom.Img {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c, base)
+	}
+	if ok {
+		b.WriteString("</" + n.Data + ">")
+	}
+}
+
+// sanitizeURL resolves ref against base and returns it if its scheme is
+// safe, or "" if not.
+func sanitizeURL(ref string, base *url.URL, link bool) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return u.String()
+	case "mailto":
+		if link {
+			return u.String()
+		}
+	case "":
+		// a fragment or relative reference without a base to resolve it
+		if link && u.Host == "" && u.Path == "" {
+			return u.String()
+		}
+	}
+	return ""
+}
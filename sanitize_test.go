@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSanitizeFragment(t *testing.T) {
+	base, _ := url.Parse("https://blog.example/posts/1")
+	for in, want := range map[string]string{
+		`<p onclick="x()">Hi <b>there</b></p>`:                               `<p>Hi <b>there</b></p>`,
+		`<a href="/about" target="_blank">about</a>`:                         `<a href="https://blog.example/about">about</a>`,
+		`<a href="javascript:alert(1)">x</a><script>alert(1)</script>`:       `<a>x</a>`,
+		`<img src="data:image/png;base64,AA"><img src="i.png" alt="i">`:      `<img src="https://blog.example/posts/i.png" alt="i">`,
+		`<iframe src="https://video.example"></iframe><custom>kept</custom>`: `kept`,
+		`Tom & Jerry`: `Tom &amp; Jerry`,
+	} {
+		if got := sanitizeFragment(in, base); got != want {
+			t.Errorf("sanitizeFragment(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
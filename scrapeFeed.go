@@ -4,8 +4,10 @@ import (
 	"blog/internal/database"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"strings"
 	"time"
 
@@ -13,13 +15,25 @@ import (
 )
 
 func handlerAgg(s *State, cmd Command) error {
-	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
-		return fmt.Errorf("usage: %v <time_between_reqs>", cmd.Name)
+	interval := s.sStruct.FetchInterval
+	if len(cmd.Args) == 1 {
+		interval = cmd.Args[0]
 	}
-
-	timeBetweenRequests, err := time.ParseDuration(cmd.Args[0])
+	if interval == "" {
+		return fmt.Errorf("usage: gator agg <time_between_reqs>, or set fetch_interval with 'gator config set'")
+	}
+	timeBetweenRequests, err := parseFetchDuration(interval)
 	if err != nil {
-		return fmt.Errorf("invalid duration: %w", err)
+		return err
+	}
+	if s.sStruct.FetchTimeout != "" {
+		if s.fetcher.timeout, err = parseFetchDuration(s.sStruct.FetchTimeout); err != nil {
+			return fmt.Errorf("fetch_timeout: %w", err)
+		}
+	}
+
+	if _, err := loadLocalNotifier(context.Background(), s); err != nil {
+		return err
 	}
 
 	log.Printf("Collecting feeds every %s...", timeBetweenRequests)
@@ -31,6 +45,18 @@ func handlerAgg(s *State, cmd Command) error {
 	}
 }
 
+// parseFetchDuration parses fetch_interval and fetch_timeout values.
+func parseFetchDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %w", err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q: must be positive", value)
+	}
+	return d, nil
+}
+
 func scrapeFeeds(s *State) {
 	feed, err := s.db.GetNextFeedToFetch(context.Background())
 	if err != nil {
@@ -38,23 +64,69 @@ func scrapeFeeds(s *State) {
 		return
 	}
 	log.Println("Found a feed to fetch!")
-	scrapeFeed(s.db, feed)
-}
 
-func scrapeFeed(db *database.Queries, feed database.Feed) {
-	err := db.MarkFeedFetched(context.Background(), feed.ID)
+	local, err := loadLocalNotifier(context.Background(), s)
 	if err != nil {
-		log.Printf("Couldn't mark feed %s fetched: %v", feed.Name, err)
-		return
+		log.Println("Couldn't set up local alerts", err)
 	}
 
-	feedData, err := fetchFeed(context.Background(), feed.Url)
+	created, err := scrapeFeed(s.db, s.fetcher, feed, local)
 	if err != nil {
 		log.Printf("Couldn't collect feed %s: %v", feed.Name, err)
-		return
+	}
+	if created > 0 || err == nil {
+		log.Printf("Feed %s collected, %d new posts", feed.Name, created)
+	}
+
+	delivered, undelivered, err := deliverWebhooks(context.Background(), s.db, s.fetcher.client, time.Now())
+	if err != nil {
+		log.Println("Couldn't deliver webhooks", err)
+	}
+	if delivered > 0 || undelivered > 0 {
+		log.Printf("Delivered %d webhooks, %d to retry or failed", delivered, undelivered)
+	}
+}
+
+// scrapeFeed fetches a feed and saves its new posts, returning how many were
+// created. Each new post gets the rules of the users following the feed
+// applied, raises an alert for each of their watches it matches and is
+// queued for their webhooks. Posts already saved are skipped; other failures
+// to save a post or act on it are joined into err without stopping the rest.
+// For feeds set to fetch full content, each new post's article is fetched
+// first, so the watches see it too. Alerts are sent once all the posts are
+// saved, with the watches notifying local going to local.
+func scrapeFeed(db database.Querier, f fetcher, feed database.Feed, local *localNotifier) (created int, err error) {
+	if err := db.MarkFeedFetched(context.Background(), feed.ID); err != nil {
+		return 0, fmt.Errorf("couldn't mark feed fetched: %w", err)
+	}
+
+	feedData, err := fetchFeed(context.Background(), f, feed.Url)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	var alerts []pendingAlert
+
+	rules, err := feedRules(context.Background(), db, feed.ID)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	watches, err := feedWatches(context.Background(), db, feed.ID, f.client)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	webhooks, err := feedWebhooks(context.Background(), db, feed.ID)
+	if err != nil {
+		errs = append(errs, err)
 	}
 
 	for _, item := range feedData.Channel.Item {
+		// A post is identified by its link, so items without one can't be saved
+		if item.Link == "" {
+			continue
+		}
+
 		// Parse published date
 		publishedAt := sql.NullTime{}
 		if t, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
@@ -63,26 +135,84 @@ func scrapeFeed(db *database.Queries, feed database.Feed) {
 			publishedAt = sql.NullTime{Time: t, Valid: true}
 		}
 
+		author := item.Author
+		if author == "" {
+			author = item.Creator
+		}
+
+		// Descriptions are shown in the reader, the web UI and published
+		// feeds, so only the markup sanitizeHTML allows is kept
+		var description string
+		if item.Description != "" {
+			base, _ := url.Parse(item.Link)
+			description = sanitizeFragment(item.Description, base)
+		}
+
+		categories := []string{}
+		for _, c := range item.Categories {
+			if c = strings.TrimSpace(c); c != "" {
+				categories = append(categories, c)
+			}
+		}
+
 		// Save post to database
-		_, err := db.CreatePost(context.Background(), database.CreatePostParams{
+		post, err := db.CreatePost(context.Background(), database.CreatePostParams{
 			ID:          uuid.New(),
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
 			Title:       item.Title,
 			Url:         item.Link,
-			Description: sql.NullString{String: item.Description, Valid: item.Description != ""},
+			Description: sql.NullString{String: description, Valid: description != ""},
 			PublishedAt: publishedAt,
 			FeedID:      feed.ID,
+			Author:      sql.NullString{String: author, Valid: author != ""},
+			Categories:  categories,
 		})
 		if err != nil {
 			// Ignore duplicate posts
-			if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			if isUniqueViolation(err) {
 				continue
 			}
-			log.Printf("Couldn't create post: %v", err)
+			errs = append(errs, fmt.Errorf("couldn't create post %s: %w", item.Link, err))
 			continue
 		}
+		created++
+
+		if feed.FetchFullContent {
+			if err := setPostContent(context.Background(), db, f, &post); err != nil {
+				errs = append(errs, fmt.Errorf("couldn't fetch content of %s: %w", post.Url, err))
+			}
+		}
+
+		for _, rule := range rules {
+			if !rule.matches(feed.ID, post.Title) {
+				continue
+			}
+			if err := applyRule(context.Background(), db, rule, post.ID); err != nil {
+				errs = append(errs, fmt.Errorf("couldn't apply rule %s to %s: %w", rule.ID, post.Url, err))
+			}
+		}
+
+		for _, watch := range watches {
+			if !watch.matches(post) {
+				continue
+			}
+			alert, err := raiseAlert(context.Background(), db, watch, feed, post)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("watch %s on %s: %w", watch.Name, post.Url, err))
+				continue
+			}
+			alerts = append(alerts, alert)
+		}
+
+		if err := queueWebhookDeliveries(context.Background(), db, webhooks, feed, post); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := sendAlerts(context.Background(), alerts, local); err != nil {
+		errs = append(errs, err)
 	}
 
-	log.Printf("Feed %s collected, %v posts processed", feed.Name, len(feedData.Channel.Item))
+	return created, errors.Join(errs...)
 }
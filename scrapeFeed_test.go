@@ -0,0 +1,250 @@
+package main
+
+import (
+	"blog/internal/database"
+	"blog/internal/memstore"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const fixtureRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel>
+<title>Fixture</title>
+<item>
+  <title>First</title>
+  <link>https://example.com/first</link>
+  <pubDate>Mon, 05 Oct 2026 10:00:00 +0000</pubDate>
+  <author>ann@example.com</author>
+  <category>go</category><category> </category><category>web</category>
+  <description>&lt;p onclick="x()"&gt;Hello, &lt;a href="/more"&gt;more&lt;/a&gt;&lt;/p&gt;&lt;script&gt;x()&lt;/script&gt;</description>
+</item>
+<item>
+  <title>Second</title>
+  <link>https://example.com/second</link>
+  <pubDate>Tue, 06 Oct 2026 10:00:00 GMT</pubDate>
+  <dc:creator>Bob</dc:creator>
+</item>
+<item>
+  <title>No link, not saved</title>
+</item>
+</channel></rss>`
+
+const fixtureEntities = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Entities</title>
+<item><title>Fish &amp;amp; Chips</title><link>https://example.com/fish</link></item>
+<item><title>Caf&#233; &mdash; open&nbsp;late</title><link>https://example.com/cafe</link></item>
+</channel></rss>`
+
+// feedServer serves the scraper fixtures, one per path.
+func feedServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, fixtureRSS)
+	})
+	mux.HandleFunc("/entities", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, fixtureEntities)
+	})
+	mux.HandleFunc("/malformed", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss><channel><item><title>Broken</title></channel>`)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+		fmt.Fprint(w, fixtureRSS)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/rss", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Huge</title>`)
+		padding := strings.Repeat(" ", 1<<20)
+		for range maxFeedSize>>20 + 1 {
+			fmt.Fprint(w, padding)
+		}
+		fmt.Fprint(w, `</channel></rss>`)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// scrapeFixture adds the feed at path for a new user and scrapes it once.
+func scrapeFixture(t *testing.T, db database.Querier, f fetcher, srv *httptest.Server, path string) (database.Feed, int, error) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), Name: "user" + path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addFeed(ctx, db, user, path, srv.URL+path); err != nil {
+		t.Fatal(err)
+	}
+	feed, err := db.GetFeedByURL(ctx, srv.URL+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := scrapeFeed(db, f, feed, nil)
+	return feed, created, err
+}
+
+// savedPosts returns the posts saved for feed, by title.
+func savedPosts(t *testing.T, db database.Querier, feed database.Feed) map[string]database.Post {
+	t.Helper()
+	posts, err := db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{UserID: feed.UserID, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTitle := make(map[string]database.Post)
+	for _, p := range posts {
+		byTitle[p.Title] = p
+	}
+	return byTitle
+}
+
+func TestScrapeFeed(t *testing.T) {
+	srv := feedServer(t)
+
+	f := fetcher{client: http.DefaultClient, timeout: 500 * time.Millisecond}
+
+	tests := []struct {
+		path       string
+		wantTitles []string
+		wantErr    string
+	}{
+		{"/rss", []string{"First", "Second"}, ""},
+		{"/redirect", []string{"First", "Second"}, ""},
+		{"/entities", []string{"Café — open\u00a0late", "Fish & Chips"}, ""},
+		{"/malformed", nil, "failed to parse body"},
+		{"/slow", nil, "context deadline exceeded"},
+		{"/error", nil, "unexpected status: 500 Internal Server Error"},
+		{"/loop", nil, "stopped after 10 redirects"},
+		{"/huge", nil, fmt.Sprintf("feed is larger than %d bytes", maxFeedSize)},
+		{"/missing", nil, "unexpected status: 404 Not Found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimPrefix(tt.path, "/"), func(t *testing.T) {
+			db := memstore.New()
+			feed, created, err := scrapeFixture(t, db, f, srv, tt.path)
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if created != len(tt.wantTitles) {
+				t.Errorf("created = %d, want %d", created, len(tt.wantTitles))
+			}
+
+			var titles []string
+			for title := range savedPosts(t, db, feed) {
+				titles = append(titles, title)
+			}
+			slices.Sort(titles)
+			if !slices.Equal(titles, tt.wantTitles) {
+				t.Errorf("saved posts = %q, want %q", titles, tt.wantTitles)
+			}
+
+			// A failing feed is still marked fetched so agg moves on
+			if refetched, _ := db.GetFeedByURL(context.Background(), feed.Url); !refetched.LastFetchedAt.Valid {
+				t.Error("feed not marked fetched")
+			}
+		})
+	}
+}
+
+func TestScrapeFeedFields(t *testing.T) {
+	db := memstore.New()
+	feed, _, err := scrapeFixture(t, db, testFetcher, feedServer(t), "/rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts := savedPosts(t, db, feed)
+
+	first := posts["First"]
+	if first.Url != "https://example.com/first" || first.FeedID != feed.ID {
+		t.Errorf("first post = %+v", first)
+	}
+	if want := time.Date(2026, 10, 5, 10, 0, 0, 0, time.UTC); !first.PublishedAt.Time.Equal(want) {
+		t.Errorf("first published = %v, want %v", first.PublishedAt, want)
+	}
+	if first.Author.String != "ann@example.com" || !slices.Equal(first.Categories, []string{"go", "web"}) {
+		t.Errorf("first author = %v, categories = %q", first.Author, first.Categories)
+	}
+	// descriptions are sanitized, with relative links resolved
+	if first.Description.String != `<p>Hello, <a href="https://example.com/more">more</a></p>` {
+		t.Errorf("first description = %q", first.Description.String)
+	}
+
+	second := posts["Second"]
+	if second.Author.String != "Bob" || !second.PublishedAt.Valid || second.Description.Valid {
+		t.Errorf("second post = %+v", second)
+	}
+	if second.Categories == nil {
+		t.Error("categories must be empty rather than nil")
+	}
+}
+
+func TestScrapeFeedTwice(t *testing.T) {
+	db := memstore.New()
+	feed, created, err := scrapeFixture(t, db, testFetcher, feedServer(t), "/rss")
+	if err != nil || created != 2 {
+		t.Fatalf("first scrape: created = %d, err = %v", created, err)
+	}
+
+	// Posts already saved are skipped without an error
+	created, err = scrapeFeed(db, testFetcher, feed, nil)
+	if err != nil || created != 0 {
+		t.Errorf("second scrape: created = %d, err = %v", created, err)
+	}
+	if n := len(savedPosts(t, db, feed)); n != 2 {
+		t.Errorf("saved %d posts, want 2", n)
+	}
+}
+
+// failingStore fails to save the post with the given URL.
+type failingStore struct {
+	*memstore.Store
+	url string
+}
+
+func (s failingStore) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	if arg.Url == s.url {
+		return database.Post{}, errors.New("disk full")
+	}
+	return s.Store.CreatePost(ctx, arg)
+}
+
+func TestScrapeFeedKeepsGoingAfterPostError(t *testing.T) {
+	db := failingStore{Store: memstore.New(), url: "https://example.com/first"}
+	feed, created, err := scrapeFixture(t, db, testFetcher, feedServer(t), "/rss")
+
+	if err == nil || err.Error() != "couldn't create post https://example.com/first: disk full" {
+		t.Errorf("err = %v", err)
+	}
+	if created != 1 {
+		t.Errorf("created = %d, want 1", created)
+	}
+	if _, ok := savedPosts(t, db, feed)["Second"]; !ok {
+		t.Error("second post not saved after the first failed")
+	}
+}
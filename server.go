@@ -0,0 +1,361 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// apiError is an error with the HTTP status it should be reported as.
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e apiError) Error() string { return e.msg }
+
+func apiErrorf(status int, format string, args ...any) error {
+	return apiError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+type apiHandler func(w http.ResponseWriter, r *http.Request) error
+type apiUserHandler func(w http.ResponseWriter, r *http.Request, user database.User) error
+
+type apiServer struct {
+	s *State
+}
+
+func serveFlags(fs *flag.FlagSet) {
+	fs.String("addr", ":8080", "address to listen on")
+}
+
+func handlerServe(s *State, cmd Command) error {
+	srv := &http.Server{
+		Addr:              cmd.flagString("addr"),
+		Handler:           (&apiServer{s: s}).routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Serving API on %s", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
+
+func (a *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/users", a.withUser(a.handleUsers))
+	mux.Handle("GET /api/feeds", a.withUser(a.handleFeeds))
+	mux.Handle("POST /api/feeds", a.withUser(a.handleCreateFeed))
+	mux.Handle("GET /api/follows", a.withUser(a.handleFollows))
+	mux.Handle("POST /api/follows", a.withUser(a.handleCreateFollow))
+	mux.Handle("DELETE /api/follows/{feedID}", a.withUser(a.handleDeleteFollow))
+	mux.Handle("GET /api/posts", a.withUser(a.handlePosts))
+	mux.Handle("GET /users/{name}/feed.atom", a.handle(a.handlePublishedFeed("atom")))
+	mux.Handle("GET /users/{name}/feed.rss", a.handle(a.handlePublishedFeed("rss")))
+	a.webRoutes(mux)
+	return logRequests(jsonMuxErrors(mux))
+}
+
+// jsonMuxErrors lets mux answer requests no route matches, 404 for an
+// unknown path and 405 with an Allow header for a known path with the wrong
+// method, but with the JSON error body every endpoint shares.
+func jsonMuxErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			w = &muxErrorWriter{ResponseWriter: w, r: r}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// muxErrorWriter replaces the plain text body of ServeMux's 404 and 405
+// responses. Other responses, such as its redirects, pass through.
+type muxErrorWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	replaced bool
+}
+
+func (w *muxErrorWriter) WriteHeader(status int) {
+	if status != http.StatusNotFound && status != http.StatusMethodNotAllowed {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.replaced = true
+	w.Header().Del("X-Content-Type-Options")
+	msg := fmt.Sprintf("no route for %s %s", w.r.Method, w.r.URL.Path)
+	if status == http.StatusMethodNotAllowed {
+		msg = fmt.Sprintf("method %s not allowed for %s", w.r.Method, w.r.URL.Path)
+	}
+	writeJSON(w.ResponseWriter, status, map[string]string{"error": msg})
+}
+
+func (w *muxErrorWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// handle turns a returned error into the JSON error body every endpoint
+// shares: {"error": "..."}.
+func (a *apiServer) handle(h apiHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := h(w, r)
+		if err == nil {
+			return
+		}
+
+		status := http.StatusInternalServerError
+		msg := "internal server error"
+		var apiErr apiError
+		switch {
+		case errors.As(err, &apiErr):
+			status, msg = apiErr.status, apiErr.msg
+		case errors.Is(err, sql.ErrNoRows):
+			status, msg = http.StatusNotFound, "not found"
+		case isUniqueViolation(err):
+			status, msg = http.StatusConflict, "already exists"
+		default:
+			log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		}
+		writeJSON(w, status, map[string]string{"error": msg})
+	})
+}
+
+// withUser is the HTTP counterpart of middlewareLoggedIn. The caller sends
+// an API key as "Authorization: Bearer <key>".
+func (a *apiServer) withUser(h apiUserHandler) http.Handler {
+	return a.handle(func(w http.ResponseWriter, r *http.Request) error {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || key == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			return apiErrorf(http.StatusUnauthorized, "missing bearer api key")
+		}
+		user, err := authenticate(r.Context(), a.s.db, key)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			return apiErrorf(http.StatusUnauthorized, "%v", err)
+		}
+		return h(w, r, user)
+	})
+}
+
+func (a *apiServer) handleUsers(w http.ResponseWriter, r *http.Request, user database.User) error {
+	users, err := a.s.db.GetAllUsers(r.Context())
+	if err != nil {
+		return err
+	}
+	columns, rows := usersTable(users, user.Name)
+	return writeTable(w, "users", columns, rows, nil)
+}
+
+func (a *apiServer) handleFeeds(w http.ResponseWriter, r *http.Request, _ database.User) error {
+	feeds, err := a.s.db.GetAllFeeds(r.Context())
+	if err != nil {
+		return err
+	}
+	columns, rows := feedsTable(feeds)
+	return writeTable(w, "feeds", columns, rows, nil)
+}
+
+func (a *apiServer) handleCreateFeed(w http.ResponseWriter, r *http.Request, user database.User) error {
+	var body struct {
+		Name string `json:"name"`
+		Url  string `json:"url"`
+	}
+	if err := decodeJSON(r, &body); err != nil {
+		return err
+	}
+	if body.Name == "" || body.Url == "" {
+		return apiErrorf(http.StatusBadRequest, "name and url are required")
+	}
+
+	feed, err := addFeed(r.Context(), a.s.db, user, body.Name, body.Url)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, map[string]any{
+		"id":         feed.ID,
+		"name":       feed.Name,
+		"url":        feed.Url,
+		"user_id":    feed.UserID.UUID,
+		"created_at": feed.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func (a *apiServer) handleFollows(w http.ResponseWriter, r *http.Request, user database.User) error {
+	follows, err := a.s.db.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	columns, rows := followsTable(follows)
+	return writeTable(w, "follows", columns, rows, nil)
+}
+
+func (a *apiServer) handleCreateFollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	var body struct {
+		Url string `json:"url"`
+	}
+	if err := decodeJSON(r, &body); err != nil {
+		return err
+	}
+
+	feed, err := a.s.db.GetFeedByURL(r.Context(), body.Url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiErrorf(http.StatusNotFound, "no feed with url %q", body.Url)
+	}
+	if err != nil {
+		return err
+	}
+
+	follow, err := a.s.db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		UserID: user.ID,
+		Url:    feed.Url,
+	})
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, map[string]any{
+		"feed_name":   follow.FeedName,
+		"feed_url":    feed.Url,
+		"feed_id":     follow.FeedID,
+		"followed_at": follow.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func (a *apiServer) handleDeleteFollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		return apiErrorf(http.StatusBadRequest, "invalid feed id: %v", err)
+	}
+
+	err = a.s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// handlePosts accepts the browse flags as query parameters, e.g.
+// /api/posts?limit=10&since=24h&feed=Hacker+News.
+func (a *apiServer) handlePosts(w http.ResponseWriter, r *http.Request, user database.User) error {
+	opts, err := browseOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		return apiErrorf(http.StatusBadRequest, "%v", err)
+	}
+
+	posts, nextCursor, err := listPosts(r.Context(), a.s.db, user, opts)
+	if err != nil {
+		return err
+	}
+	highlighted, err := highlightedPosts(r.Context(), a.s.db, user, posts)
+	if err != nil {
+		return err
+	}
+
+	extra := map[string]any{"next_cursor": nil}
+	if nextCursor != "" {
+		extra["next_cursor"] = nextCursor
+	}
+	columns, rows := postsTable(posts, highlighted)
+	if opts.full {
+		columns, rows = withContent(columns, rows, posts)
+	}
+	return writeTable(w, "posts", columns, rows, extra)
+}
+
+// writeTable encodes a listing with the same field names as --format json,
+// wrapped in an object so extra fields such as next_cursor can sit beside it.
+func writeTable(w http.ResponseWriter, key string, columns []string, rows [][]any, extra map[string]any) error {
+	objects := make([]json.RawMessage, 0, len(rows))
+	for _, row := range rows {
+		obj, err := jsonObject(columns, row)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, obj)
+	}
+
+	body := map[string]any{key: objects}
+	for k, v := range extra {
+		body[k] = v
+	}
+	return writeJSON(w, http.StatusOK, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+func decodeJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return apiErrorf(http.StatusBadRequest, "invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// loggedURI is the request URI with any feed token blanked out, so the log
+// doesn't hand out subscriptions.
+func loggedURI(u *url.URL) string {
+	query := u.Query()
+	if !query.Has("token") {
+		return u.RequestURI()
+	}
+	query.Set("token", "REDACTED")
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.RequestURI()
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%s %s %d %s", r.Method, loggedURI(r.URL), rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
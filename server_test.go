@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAPIErrors(t *testing.T) {
+	srv := httptest.NewServer((&apiServer{s: &State{}}).routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		header     string
+		wantStatus int
+		wantError  string
+	}{
+		{"unknown route", "GET", "/api/nope", "", http.StatusNotFound, "no route for GET /api/nope"},
+		{"wrong method", "PUT", "/api/feeds", "", http.StatusMethodNotAllowed, "method PUT not allowed for /api/feeds"},
+		{"users without user", "GET", "/api/users", "", http.StatusUnauthorized, "missing bearer api key"},
+		{"feeds without user", "GET", "/api/feeds", "", http.StatusUnauthorized, "missing bearer api key"},
+		{"posts without user", "GET", "/api/posts", "", http.StatusUnauthorized, "missing bearer api key"},
+		{"follows without user", "GET", "/api/follows", "", http.StatusUnauthorized, "missing bearer api key"},
+		{"basic auth", "GET", "/api/follows", "Basic YWxpY2U6", http.StatusUnauthorized, "missing bearer api key"},
+		{"malformed key", "GET", "/api/follows", "Bearer alice", http.StatusUnauthorized, "malformed api key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if allow := resp.Header.Get("Allow"); tt.wantStatus == http.StatusMethodNotAllowed && !strings.Contains(allow, "GET") {
+				t.Errorf("Allow = %q", allow)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding error body: %v", err)
+			}
+			if body.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", body.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestWriteTableUsesOutputFieldNames(t *testing.T) {
+	rec := httptest.NewRecorder()
+	columns, rows := usersTable([]string{"alice", "bob"}, "")
+	if err := writeTable(rec, "users", columns, rows, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"users":[{"name":"alice","current":false},{"name":"bob","current":false}]}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+func TestWebRequiresSession(t *testing.T) {
+	srv := httptest.NewServer((&apiServer{s: &State{}}).routes())
+	defer srv.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	for _, path := range []string{"/", "/feeds", "/feeds/" + "00000000-0000-0000-0000-000000000000"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/login" {
+			t.Errorf("GET %s = %d to %q, want 303 to /login", path, resp.StatusCode, resp.Header.Get("Location"))
+		}
+	}
+
+	resp, err := client.Get(srv.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `<form method="post" action="/login">`) {
+		t.Errorf("GET /login = %d, body:\n%s", resp.StatusCode, body)
+	}
+}
+
+func TestWebSession(t *testing.T) {
+	feedSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testRSS, "http://"+r.Host)
+	}))
+	defer feedSrv.Close()
+
+	h := newHandlerTest(t)
+	h.mustRun("hunter2!\nhunter2!\n", "register", "alice")
+	h.mustRun("", "addfeed", "Test Blog", feedSrv.URL+"/rss")
+	scrapeFeeds(h.s)
+	h.mustRun("", "browse", "--limit", "1")
+	var posts []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(h.out.Bytes(), &posts); err != nil || len(posts) != 1 {
+		t.Fatalf("browse output %s: %v", h.out.String(), err)
+	}
+	h.mustRun("correct horse\ncorrect horse\n", "register", "bob")
+
+	srv := httptest.NewServer((&apiServer{s: h.s}).routes())
+	defer srv.Close()
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	post := func(path string, form url.Values) int {
+		t.Helper()
+		resp, err := client.PostForm(srv.URL+path, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	webKeys := func(name string) int {
+		t.Helper()
+		user, err := h.s.db.GetUserByName(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, err := h.s.db.ListAPIKeysForUser(context.Background(), user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, k := range keys {
+			if k.Name == "web" && !k.RevokedAt.Valid {
+				n++
+			}
+		}
+		return n
+	}
+
+	// logging in again from the same browser keeps its key
+	for range 2 {
+		if status := post("/login", url.Values{"username": {"alice"}, "password": {"hunter2!"}}); status != http.StatusSeeOther {
+			t.Fatalf("login = %d", status)
+		}
+	}
+	if n := webKeys("alice"); n != 1 {
+		t.Errorf("alice has %d web keys after logging in twice, want 1", n)
+	}
+
+	resp, err := client.Get(srv.URL + "/feeds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), feedSrv.URL+"/rss") {
+		t.Errorf("GET /feeds = %d, body:\n%s", resp.StatusCode, body)
+	}
+
+	if status := post("/posts/"+posts[0].ID+"/read", url.Values{"read": {"true"}}); status != http.StatusSeeOther {
+		t.Errorf("marking a followed post read = %d", status)
+	}
+	if status := post("/posts/"+uuid.NewString()+"/read", url.Values{"read": {"true"}}); status != http.StatusNotFound {
+		t.Errorf("marking a missing post read = %d, want 404", status)
+	}
+
+	// bob doesn't follow the feed, so its posts don't exist for him
+	if status := post("/login", url.Values{"username": {"bob"}, "password": {"correct horse"}}); status != http.StatusSeeOther {
+		t.Fatalf("login as bob = %d", status)
+	}
+	if status := post("/posts/"+posts[0].ID+"/read", url.Values{"read": {"true"}}); status != http.StatusNotFound {
+		t.Errorf("marking an unfollowed post read = %d, want 404", status)
+	}
+	if n := webKeys("bob"); n != 1 {
+		t.Errorf("bob has %d web keys, want 1", n)
+	}
+}
@@ -0,0 +1,47 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, user_id, name, prefix, key_hash)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetUserByAPIKeyHash :one
+SELECT users.*, api_keys.id AS api_key_id
+FROM api_keys
+JOIN users ON api_keys.user_id = users.id
+WHERE api_keys.key_hash = $1
+  AND api_keys.revoked_at IS NULL;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: ListAPIKeysForUser :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: CountAPIKeysForUser :one
+SELECT COUNT(*) FROM api_keys
+WHERE user_id = $1;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE user_id = $1
+  AND prefix = $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeAllAPIKeys :exec
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeStaleAPIKeys :execrows
+-- Revokes the user's keys of one name that haven't been used since before.
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE user_id = sqlc.arg('user_id')
+  AND name = sqlc.arg('name')
+  AND revoked_at IS NULL
+  AND COALESCE(last_used_at, created_at) < sqlc.arg('before')::timestamp;
@@ -0,0 +1,14 @@
+-- name: GetDigestSettings :one
+SELECT * FROM digest_settings
+WHERE user_id = $1;
+
+-- name: SetDigestSettings :exec
+INSERT INTO digest_settings (user_id, email, schedule)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET email = EXCLUDED.email, schedule = EXCLUDED.schedule;
+
+-- name: SetDigestSent :exec
+-- Records the end of the window the last digest covered.
+INSERT INTO digest_settings (user_id, last_sent_at)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET last_sent_at = EXCLUDED.last_sent_at;
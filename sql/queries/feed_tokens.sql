@@ -0,0 +1,26 @@
+-- name: CreateFeedToken :one
+INSERT INTO feed_tokens (id, created_at, user_id, name, prefix, token_hash)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetFeedTokenByHash :one
+SELECT * FROM feed_tokens
+WHERE token_hash = $1
+  AND revoked_at IS NULL;
+
+-- name: TouchFeedToken :exec
+UPDATE feed_tokens
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: ListFeedTokensForUser :many
+SELECT * FROM feed_tokens
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: RevokeFeedToken :execrows
+UPDATE feed_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1
+  AND prefix = $2
+  AND revoked_at IS NULL;
@@ -0,0 +1,26 @@
+-- name: CreateFolder :one
+INSERT INTO folders (id, created_at, user_id, name, parent_id)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetFolderByName :one
+SELECT * FROM folders
+WHERE user_id = $1 AND name = $2;
+
+-- name: ListFolders :many
+SELECT folders.*, COUNT(folder_feeds.feed_id) AS feed_count
+FROM folders
+LEFT JOIN folder_feeds ON folder_feeds.folder_id = folders.id
+WHERE folders.user_id = $1
+GROUP BY folders.id
+ORDER BY folders.name;
+
+-- name: DeleteFolder :execrows
+DELETE FROM folders
+WHERE user_id = $1 AND name = $2;
+
+-- name: SetFeedFolder :exec
+-- The feed has to be followed; unfollowing drops it from its folder.
+INSERT INTO folder_feeds (user_id, feed_id, folder_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, feed_id) DO UPDATE SET folder_id = EXCLUDED.folder_id;
@@ -0,0 +1,13 @@
+-- name: SetPasswordReset :exec
+-- Replaces any earlier reset token of the user.
+INSERT INTO password_resets (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at;
+
+-- name: GetPasswordReset :one
+SELECT * FROM password_resets
+WHERE user_id = $1;
+
+-- name: DeletePasswordReset :exec
+DELETE FROM password_resets
+WHERE user_id = $1;
@@ -0,0 +1,86 @@
+-- name: GetPostsForUserFiltered :many
+-- Keyset pagination on (sort key, id): the cursor columns hold the sort key
+-- and id of the last row of the previous page. folder includes its subfolders;
+-- search matches the title, description or full content, markup included.
+SELECT
+    posts.*,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    post_states.read_at,
+    post_states.starred_at,
+    post_states.hidden_at,
+    ARRAY(
+        SELECT tags.name FROM post_tags
+        JOIN tags ON tags.id = post_tags.tag_id
+        WHERE post_tags.post_id = posts.id AND tags.user_id = feed_follows.user_id
+        ORDER BY tags.name
+    )::text[] AS tags,
+    post_notes.body AS note
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+LEFT JOIN post_notes ON post_notes.post_id = posts.id AND post_notes.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('feed')::text IS NULL OR feeds.url = sqlc.narg('feed') OR feeds.name = sqlc.narg('feed'))
+  AND (sqlc.narg('author')::text IS NULL OR posts.author ILIKE '%' || sqlc.narg('author') || '%')
+  AND (sqlc.narg('category')::text IS NULL OR sqlc.narg('category') = ANY(posts.categories))
+  AND (sqlc.narg('since')::timestamp IS NULL OR
+       CASE WHEN sqlc.arg('sort_by')::text = 'fetched' THEN posts.created_at
+            ELSE COALESCE(posts.published_at, posts.created_at) END >= sqlc.narg('since'))
+  AND (sqlc.narg('until')::timestamp IS NULL OR
+       CASE WHEN sqlc.arg('sort_by') = 'fetched' THEN posts.created_at
+            ELSE COALESCE(posts.published_at, posts.created_at) END < sqlc.narg('until'))
+  AND (sqlc.narg('cursor_time')::timestamp IS NULL OR
+       (CASE WHEN sqlc.arg('sort_by') = 'fetched' THEN posts.created_at
+             ELSE COALESCE(posts.published_at, posts.created_at) END, posts.id)
+       < (sqlc.narg('cursor_time'), sqlc.narg('cursor_id')::uuid))
+  AND (NOT sqlc.arg('unread_only')::boolean OR post_states.read_at IS NULL)
+  AND (NOT sqlc.arg('starred_only')::boolean OR post_states.starred_at IS NOT NULL)
+  AND (sqlc.narg('folder')::text IS NULL OR feed_follows.feed_id IN (
+       WITH RECURSIVE folder_tree AS (
+           SELECT folders.id FROM folders
+           WHERE folders.user_id = sqlc.arg('user_id') AND folders.name = sqlc.narg('folder')
+           UNION ALL
+           SELECT folders.id FROM folders JOIN folder_tree ON folders.parent_id = folder_tree.id
+       )
+       SELECT folder_feeds.feed_id FROM folder_feeds
+       JOIN folder_tree ON folder_feeds.folder_id = folder_tree.id
+       WHERE folder_feeds.user_id = sqlc.arg('user_id')))
+  AND (sqlc.narg('tag')::text IS NULL OR posts.id IN (
+       SELECT post_tags.post_id FROM post_tags
+       JOIN tags ON tags.id = post_tags.tag_id
+       WHERE tags.user_id = sqlc.arg('user_id') AND tags.name = sqlc.narg('tag')))
+  AND (sqlc.arg('include_hidden')::boolean OR post_states.hidden_at IS NULL)
+  AND (sqlc.narg('search')::text IS NULL OR posts.title ILIKE '%' || sqlc.narg('search') || '%'
+       OR posts.description ILIKE '%' || sqlc.narg('search') || '%'
+       OR posts.content ILIKE '%' || sqlc.narg('search') || '%')
+ORDER BY
+    CASE WHEN sqlc.arg('sort_by') = 'fetched' THEN posts.created_at
+         ELSE COALESCE(posts.published_at, posts.created_at) END DESC,
+    posts.id DESC
+LIMIT sqlc.arg('lim') OFFSET sqlc.arg('off');
+
+-- name: GetPost :one
+SELECT * FROM posts
+WHERE id = $1;
+
+-- name: SetPostContent :exec
+UPDATE posts
+SET content = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetPostHidden :exec
+INSERT INTO post_states (user_id, post_id, hidden_at)
+VALUES (@user_id, @post_id, CASE WHEN @hidden::boolean THEN NOW() END)
+ON CONFLICT (user_id, post_id) DO UPDATE SET hidden_at = EXCLUDED.hidden_at;
+
+-- name: SetPostRead :exec
+INSERT INTO post_states (user_id, post_id, read_at)
+VALUES (@user_id, @post_id, CASE WHEN @read::boolean THEN NOW() END)
+ON CONFLICT (user_id, post_id) DO UPDATE SET read_at = EXCLUDED.read_at;
+
+-- name: SetPostStarred :exec
+INSERT INTO post_states (user_id, post_id, starred_at)
+VALUES (@user_id, @post_id, CASE WHEN @starred::boolean THEN NOW() END)
+ON CONFLICT (user_id, post_id) DO UPDATE SET starred_at = EXCLUDED.starred_at;
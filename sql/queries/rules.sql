@@ -0,0 +1,28 @@
+-- name: CreateRule :one
+INSERT INTO rules (id, created_at, user_id, feed_id, title_pattern, action)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetRule :one
+SELECT * FROM rules
+WHERE user_id = $1 AND id = $2;
+
+-- name: ListRules :many
+SELECT rules.*, feeds.name AS feed_name
+FROM rules
+LEFT JOIN feeds ON feeds.id = rules.feed_id
+WHERE rules.user_id = $1
+ORDER BY rules.created_at;
+
+-- name: ListRulesForFeed :many
+-- The rules of every user following the feed that apply to it.
+SELECT rules.*
+FROM rules
+JOIN feed_follows ON feed_follows.user_id = rules.user_id
+WHERE feed_follows.feed_id = $1
+  AND (rules.feed_id IS NULL OR rules.feed_id = feed_follows.feed_id)
+ORDER BY rules.created_at;
+
+-- name: DeleteRule :execrows
+DELETE FROM rules
+WHERE user_id = $1 AND id = $2;
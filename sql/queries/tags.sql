@@ -0,0 +1,31 @@
+-- name: UpsertTag :one
+-- Returns the user's tag of that name, creating it the first time.
+INSERT INTO tags (id, created_at, user_id, name)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+RETURNING *;
+
+-- name: AddPostTag :exec
+INSERT INTO post_tags (tag_id, post_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: RemovePostTag :execrows
+DELETE FROM post_tags
+WHERE post_id = $1
+  AND tag_id = (SELECT id FROM tags WHERE user_id = $2 AND name = $3);
+
+-- name: ListPostTags :many
+SELECT tags.name
+FROM post_tags
+JOIN tags ON tags.id = post_tags.tag_id
+WHERE tags.user_id = $1 AND post_tags.post_id = $2
+ORDER BY tags.name;
+
+-- name: ListTags :many
+SELECT tags.*, COUNT(post_tags.post_id) AS post_count
+FROM tags
+LEFT JOIN post_tags ON post_tags.tag_id = tags.id
+WHERE tags.user_id = $1
+GROUP BY tags.id
+ORDER BY tags.name;
@@ -1,10 +1,11 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name) 
+INSERT INTO users (id, created_at, updated_at, name, password_hash) 
 VALUES ( 
   $1,
   $2,
   $3,
-  $4
+  $4,
+  $5
 )
 RETURNING *;
 
@@ -22,6 +23,38 @@ DELETE FROM users;
 -- name: GetAllUsers :many
 SELECT name FROM users;
 
+-- name: ListUsersWithoutPassword :many
+-- Users created before passwords; they need a reset token to log in.
+SELECT * FROM users
+WHERE password_hash IS NULL
+ORDER BY name;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2,
+    failed_logins = 0,
+    locked_until = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordFailedLogin :one
+UPDATE users
+SET failed_logins = failed_logins + 1
+WHERE id = $1
+RETURNING failed_logins;
+
+-- name: LockUser :exec
+UPDATE users
+SET locked_until = $2,
+    failed_logins = 0
+WHERE id = $1;
+
+-- name: ResetFailedLogins :exec
+UPDATE users
+SET failed_logins = 0,
+    locked_until = NULL
+WHERE id = $1;
+
 -- name: CreateFeed :one
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES ($1, $2, $3, $4, $5, $6)
@@ -40,6 +73,7 @@ WHERE user_id = $1;
 
 -- name: GetAllFeeds :many
 SELECT 
+    feeds.id AS feed_id,
     feeds.name AS feed_name,
     feeds.url AS feed_url,
     users.name AS user_name
@@ -74,14 +108,18 @@ SELECT
   feed_follows.user_id,
   feed_follows.feed_id,
   users.name AS user_name,
-  feeds.name AS feed_name
+  feeds.name AS feed_name,
+  feeds.url AS feed_url,
+  folders.name AS folder_name
 FROM feed_follows
 JOIN users ON feed_follows.user_id = users.id
 JOIN feeds ON feed_follows.feed_id = feeds.id
+LEFT JOIN folder_feeds ON folder_feeds.user_id = feed_follows.user_id AND folder_feeds.feed_id = feed_follows.feed_id
+LEFT JOIN folders ON folders.id = folder_feeds.folder_id
 WHERE feed_follows.user_id = $1;
 
 -- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, fetch_full_content
 FROM feeds
 WHERE url = $1;
 
@@ -98,16 +136,21 @@ SET
     updated_at = NOW()
 WHERE id = $1;
 
+-- name: SetFeedFullContent :exec
+UPDATE feeds
+SET fetch_full_content = $2, updated_at = NOW()
+WHERE id = $1;
+
 
 -- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, fetch_full_content
 FROM feeds
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT 1;
 
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 RETURNING *;
 
 -- name: GetPostsForUser :many
@@ -0,0 +1,77 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, user_id, url, secret, feed_id, folder_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: ListWebhooks :many
+SELECT webhooks.*, feeds.name AS feed_name, folders.name AS folder_name
+FROM webhooks
+LEFT JOIN feeds ON feeds.id = webhooks.feed_id
+LEFT JOIN folders ON folders.id = webhooks.folder_id
+WHERE webhooks.user_id = $1
+ORDER BY webhooks.created_at;
+
+-- name: ListWebhooksForFeed :many
+-- The webhooks of every user following the feed that apply to it, with the
+-- category the user filed the feed in. Webhooks scoped to a category are
+-- left for the caller to match against it and its parents.
+SELECT webhooks.*, folder_feeds.folder_id AS feed_folder_id
+FROM webhooks
+JOIN feed_follows ON feed_follows.user_id = webhooks.user_id
+LEFT JOIN folder_feeds ON folder_feeds.user_id = feed_follows.user_id AND folder_feeds.feed_id = feed_follows.feed_id
+WHERE feed_follows.feed_id = $1
+  AND (webhooks.feed_id IS NULL OR webhooks.feed_id = feed_follows.feed_id)
+ORDER BY webhooks.created_at;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE user_id = $1 AND id = $2;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, post_id, payload, next_attempt_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Marks the due deliveries of every user as sending until lease_until and
+-- returns them, so concurrent agg runs never send the same one. A delivery
+-- still sending when its lease runs out was left by an agg that stopped, and
+-- is due again.
+UPDATE webhook_deliveries
+SET status = 'sending', next_attempt_at = sqlc.arg('lease_until')
+FROM webhooks
+WHERE webhooks.id = webhook_deliveries.webhook_id
+  AND webhook_deliveries.id IN (
+    SELECT id FROM webhook_deliveries AS due
+    WHERE due.status IN ('pending', 'sending') AND due.next_attempt_at <= sqlc.arg('now')::timestamp
+    ORDER BY due.next_attempt_at
+    LIMIT sqlc.arg('lim')
+    FOR UPDATE SKIP LOCKED
+  )
+RETURNING webhook_deliveries.*, webhooks.url, webhooks.secret;
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = $3,
+    next_attempt_at = $4,
+    last_attempt_at = $5,
+    last_error = $6,
+    response_status = $7
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT webhook_deliveries.*, webhooks.url, posts.title
+FROM webhook_deliveries
+JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+JOIN posts ON posts.id = webhook_deliveries.post_id
+WHERE webhooks.user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('status')::text IS NULL OR webhook_deliveries.status = sqlc.narg('status'))
+ORDER BY webhook_deliveries.created_at DESC, webhook_deliveries.id DESC
+LIMIT sqlc.arg('lim');
+
+-- name: RetryWebhookDelivery :execrows
+-- Queues a failed delivery again, keeping its attempts.
+UPDATE webhook_deliveries
+SET status = 'pending', next_attempt_at = $3
+WHERE id = $1 AND status = 'failed'
+  AND webhook_id IN (SELECT id FROM webhooks WHERE user_id = $2);
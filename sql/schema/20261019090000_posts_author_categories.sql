@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE posts
+ADD COLUMN author TEXT,
+ADD COLUMN categories TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX posts_published_id_idx ON posts ((COALESCE(published_at, created_at)) DESC, id DESC);
+CREATE INDEX posts_fetched_id_idx ON posts (created_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS posts_fetched_id_idx;
+DROP INDEX IF EXISTS posts_published_id_idx;
+
+ALTER TABLE posts
+DROP COLUMN categories,
+DROP COLUMN author;
+-- +goose StatementEnd
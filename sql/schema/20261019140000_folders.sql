@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE folders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    parent_id UUID REFERENCES folders(id) ON DELETE SET NULL,
+    UNIQUE(user_id, name)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE folder_feeds (
+    user_id UUID NOT NULL,
+    feed_id UUID NOT NULL,
+    folder_id UUID NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, feed_id),
+    FOREIGN KEY (user_id, feed_id) REFERENCES feed_follows(user_id, feed_id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS folder_feeds;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS folders;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Commands and files are run by whoever runs agg, so watches no longer
+-- store them: they notify the local alert_notify setting instead.
+UPDATE watches
+SET notify = 'local'
+WHERE notify LIKE 'exec:%' OR notify LIKE 'file:%';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The commands and paths are gone; stdout is the notifier older versions
+-- understand.
+UPDATE watches
+SET notify = 'stdout'
+WHERE notify = 'local';
+-- +goose StatementEnd
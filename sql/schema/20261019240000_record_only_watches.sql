@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- stdout is the terminal of whoever runs agg, so watches no longer print
+-- there: they only record their alerts, and the operator can still print
+-- their own with alert_notify.
+UPDATE watches
+SET notify = 'none'
+WHERE notify = 'stdout';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE watches
+SET notify = 'stdout'
+WHERE notify = 'none';
+-- +goose StatementEnd
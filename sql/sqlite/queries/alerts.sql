@@ -0,0 +1,65 @@
+-- SQLite versions of sql/queries/alerts.sql, matched by name.
+
+-- name: CreateWatch :one
+INSERT INTO watches (id, created_at, user_id, feed_id, name, pattern, notify)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at, user_id, feed_id, name, pattern, notify;
+
+-- name: GetWatchByName :one
+SELECT id, created_at, user_id, feed_id, name, pattern, notify FROM watches
+WHERE user_id = $1 AND name = $2;
+
+-- name: ListWatches :many
+SELECT
+    watches.id, watches.created_at, watches.user_id, watches.feed_id, watches.name, watches.pattern, watches.notify,
+    feeds.name AS feed_name,
+    (SELECT COUNT(*) FROM alerts
+     WHERE alerts.watch_id = watches.id AND alerts.acknowledged_at IS NULL) AS unacknowledged
+FROM watches
+LEFT JOIN feeds ON feeds.id = watches.feed_id
+WHERE watches.user_id = $1
+ORDER BY watches.name;
+
+-- name: ListWatchesForFeed :many
+SELECT watches.id, watches.created_at, watches.user_id, watches.feed_id, watches.name, watches.pattern, watches.notify
+FROM watches
+JOIN feed_follows ON feed_follows.user_id = watches.user_id
+WHERE feed_follows.feed_id = $1
+  AND (watches.feed_id IS NULL OR watches.feed_id = feed_follows.feed_id)
+ORDER BY watches.created_at;
+
+-- name: DeleteWatch :execrows
+DELETE FROM watches
+WHERE user_id = $1 AND name = $2;
+
+-- name: CreateAlert :one
+INSERT INTO alerts (id, created_at, watch_id, post_id)
+VALUES ($1, $2, $3, $4)
+RETURNING id, created_at, watch_id, post_id, acknowledged_at;
+
+-- name: ListAlerts :many
+SELECT
+    alerts.id, alerts.created_at, alerts.watch_id, alerts.post_id, alerts.acknowledged_at,
+    watches.name AS watch_name,
+    posts.title,
+    posts.url,
+    feeds.name AS feed_name
+FROM alerts
+JOIN watches ON watches.id = alerts.watch_id
+JOIN posts ON posts.id = alerts.post_id
+JOIN feeds ON feeds.id = posts.feed_id
+WHERE watches.user_id = $1
+  AND ($2 OR alerts.acknowledged_at IS NULL)
+ORDER BY alerts.created_at DESC, alerts.id DESC;
+
+-- name: AcknowledgeAlert :execrows
+UPDATE alerts
+SET acknowledged_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = $1 AND acknowledged_at IS NULL
+  AND watch_id IN (SELECT id FROM watches WHERE user_id = $2);
+
+-- name: AcknowledgeAllAlerts :execrows
+UPDATE alerts
+SET acknowledged_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE acknowledged_at IS NULL
+  AND watch_id IN (SELECT id FROM watches WHERE user_id = $1);
@@ -0,0 +1,51 @@
+-- SQLite versions of sql/queries/api_keys.sql, matched by name. Parameters
+-- are numbered like the generated Postgres query.
+
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, user_id, name, prefix, key_hash)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, user_id, name, prefix, key_hash, last_used_at, revoked_at;
+
+-- name: GetUserByAPIKeyHash :one
+SELECT users.id, users.created_at, users.updated_at, users.name, users.password_hash,
+       users.failed_logins, users.locked_until, api_keys.id AS api_key_id
+FROM api_keys
+JOIN users ON api_keys.user_id = users.id
+WHERE api_keys.key_hash = $1
+  AND api_keys.revoked_at IS NULL;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = $1;
+
+-- name: ListAPIKeysForUser :many
+SELECT id, created_at, user_id, name, prefix, key_hash, last_used_at, revoked_at
+FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: CountAPIKeysForUser :one
+SELECT COUNT(*) FROM api_keys
+WHERE user_id = $1;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE user_id = $1
+  AND prefix = $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeAllAPIKeys :exec
+UPDATE api_keys
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeStaleAPIKeys :execrows
+UPDATE api_keys
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE user_id = $1
+  AND name = $2
+  AND revoked_at IS NULL
+  AND COALESCE(last_used_at, created_at) < $3;
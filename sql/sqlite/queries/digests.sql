@@ -0,0 +1,15 @@
+-- SQLite versions of sql/queries/digests.sql, matched by name.
+
+-- name: GetDigestSettings :one
+SELECT user_id, email, schedule, last_sent_at FROM digest_settings
+WHERE user_id = $1;
+
+-- name: SetDigestSettings :exec
+INSERT INTO digest_settings (user_id, email, schedule)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET email = excluded.email, schedule = excluded.schedule;
+
+-- name: SetDigestSent :exec
+INSERT INTO digest_settings (user_id, last_sent_at)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET last_sent_at = excluded.last_sent_at;
@@ -0,0 +1,30 @@
+-- SQLite versions of sql/queries/feed_tokens.sql, matched by name.
+
+-- name: CreateFeedToken :one
+INSERT INTO feed_tokens (id, created_at, user_id, name, prefix, token_hash)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, user_id, name, prefix, token_hash, last_used_at, revoked_at;
+
+-- name: GetFeedTokenByHash :one
+SELECT id, created_at, user_id, name, prefix, token_hash, last_used_at, revoked_at
+FROM feed_tokens
+WHERE token_hash = $1
+  AND revoked_at IS NULL;
+
+-- name: TouchFeedToken :exec
+UPDATE feed_tokens
+SET last_used_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = $1;
+
+-- name: ListFeedTokensForUser :many
+SELECT id, created_at, user_id, name, prefix, token_hash, last_used_at, revoked_at
+FROM feed_tokens
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: RevokeFeedToken :execrows
+UPDATE feed_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE user_id = $1
+  AND prefix = $2
+  AND revoked_at IS NULL;
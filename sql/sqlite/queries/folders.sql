@@ -0,0 +1,28 @@
+-- SQLite versions of sql/queries/folders.sql, matched by name.
+
+-- name: CreateFolder :one
+INSERT INTO folders (id, created_at, user_id, name, parent_id)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at, user_id, name, parent_id;
+
+-- name: GetFolderByName :one
+SELECT id, created_at, user_id, name, parent_id FROM folders
+WHERE user_id = $1 AND name = $2;
+
+-- name: ListFolders :many
+SELECT folders.id, folders.created_at, folders.user_id, folders.name, folders.parent_id,
+    COUNT(folder_feeds.feed_id) AS feed_count
+FROM folders
+LEFT JOIN folder_feeds ON folder_feeds.folder_id = folders.id
+WHERE folders.user_id = $1
+GROUP BY folders.id
+ORDER BY folders.name;
+
+-- name: DeleteFolder :execrows
+DELETE FROM folders
+WHERE user_id = $1 AND name = $2;
+
+-- name: SetFeedFolder :exec
+INSERT INTO folder_feeds (user_id, feed_id, folder_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, feed_id) DO UPDATE SET folder_id = excluded.folder_id;
@@ -0,0 +1,16 @@
+-- SQLite versions of sql/queries/notes.sql, matched by name.
+
+-- name: GetPostNote :one
+SELECT user_id, post_id, body, created_at, updated_at FROM post_notes
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: SetPostNote :exec
+INSERT INTO post_notes (user_id, post_id, body)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE SET
+    body = excluded.body,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
+
+-- name: DeletePostNote :execrows
+DELETE FROM post_notes
+WHERE user_id = $1 AND post_id = $2;
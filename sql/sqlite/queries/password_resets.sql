@@ -0,0 +1,14 @@
+-- SQLite versions of sql/queries/password_resets.sql, matched by name.
+
+-- name: SetPasswordReset :exec
+INSERT INTO password_resets (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, expires_at = excluded.expires_at;
+
+-- name: GetPasswordReset :one
+SELECT user_id, token_hash, expires_at FROM password_resets
+WHERE user_id = $1;
+
+-- name: DeletePasswordReset :exec
+DELETE FROM password_resets
+WHERE user_id = $1;
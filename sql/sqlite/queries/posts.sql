@@ -0,0 +1,93 @@
+-- SQLite versions of sql/queries/posts.sql, matched by name. Parameters
+-- are numbered like the generated Postgres query.
+
+-- name: GetPostsForUserFiltered :many
+-- $1 user_id, $2 feed, $3 author, $4 category, $5 since, $6 sort_by,
+-- $7 until, $8 cursor_time, $9 cursor_id, $10 unread_only,
+-- $11 starred_only, $12 folder, $13 tag, $14 include_hidden, $15 search,
+-- $16 lim, $17 off
+-- Tags are built as a TEXT[] literal; tag names never contain quotes or
+-- backslashes, so quoting each one is enough.
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+    posts.published_at, posts.feed_id, posts.author, posts.categories, posts.content,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    post_states.read_at,
+    post_states.starred_at,
+    post_states.hidden_at,
+    '{' || COALESCE((
+        SELECT group_concat('"' || tags.name || '"', ',' ORDER BY tags.name) FROM post_tags
+        JOIN tags ON tags.id = post_tags.tag_id
+        WHERE post_tags.post_id = posts.id AND tags.user_id = feed_follows.user_id
+    ), '') || '}' AS tags,
+    post_notes.body AS note
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+LEFT JOIN post_notes ON post_notes.post_id = posts.id AND post_notes.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+  AND ($2 IS NULL OR feeds.url = $2 OR feeds.name = $2)
+  AND ($3 IS NULL OR posts.author LIKE '%' || $3 || '%')
+  AND ($4 IS NULL OR pq_array_contains(posts.categories, $4))
+  AND ($5 IS NULL OR
+       CASE WHEN $6 = 'fetched' THEN posts.created_at
+            ELSE COALESCE(posts.published_at, posts.created_at) END >= $5)
+  AND ($7 IS NULL OR
+       CASE WHEN $6 = 'fetched' THEN posts.created_at
+            ELSE COALESCE(posts.published_at, posts.created_at) END < $7)
+  AND ($8 IS NULL OR
+       (CASE WHEN $6 = 'fetched' THEN posts.created_at
+             ELSE COALESCE(posts.published_at, posts.created_at) END, posts.id)
+       < ($8, $9))
+  AND (NOT $10 OR post_states.read_at IS NULL)
+  AND (NOT $11 OR post_states.starred_at IS NOT NULL)
+  AND ($12 IS NULL OR feed_follows.feed_id IN (
+       WITH RECURSIVE folder_tree AS (
+           SELECT folders.id FROM folders
+           WHERE folders.user_id = $1 AND folders.name = $12
+           UNION ALL
+           SELECT folders.id FROM folders JOIN folder_tree ON folders.parent_id = folder_tree.id
+       )
+       SELECT folder_feeds.feed_id FROM folder_feeds
+       JOIN folder_tree ON folder_feeds.folder_id = folder_tree.id
+       WHERE folder_feeds.user_id = $1))
+  AND ($13 IS NULL OR posts.id IN (
+       SELECT post_tags.post_id FROM post_tags
+       JOIN tags ON tags.id = post_tags.tag_id
+       WHERE tags.user_id = $1 AND tags.name = $13))
+  AND ($14 OR post_states.hidden_at IS NULL)
+  AND ($15 IS NULL OR posts.title LIKE '%' || $15 || '%'
+       OR posts.description LIKE '%' || $15 || '%'
+       OR posts.content LIKE '%' || $15 || '%')
+ORDER BY
+    CASE WHEN $6 = 'fetched' THEN posts.created_at
+         ELSE COALESCE(posts.published_at, posts.created_at) END DESC,
+    posts.id DESC
+LIMIT $16 OFFSET $17;
+
+-- name: GetPost :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, content
+FROM posts
+WHERE id = $1;
+
+-- name: SetPostContent :exec
+UPDATE posts
+SET content = $2, updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = $1;
+
+-- name: SetPostHidden :exec
+INSERT INTO post_states (user_id, post_id, hidden_at)
+VALUES ($1, $2, CASE WHEN $3 THEN strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') END)
+ON CONFLICT (user_id, post_id) DO UPDATE SET hidden_at = excluded.hidden_at;
+
+-- name: SetPostRead :exec
+INSERT INTO post_states (user_id, post_id, read_at)
+VALUES ($1, $2, CASE WHEN $3 THEN strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') END)
+ON CONFLICT (user_id, post_id) DO UPDATE SET read_at = excluded.read_at;
+
+-- name: SetPostStarred :exec
+INSERT INTO post_states (user_id, post_id, starred_at)
+VALUES ($1, $2, CASE WHEN $3 THEN strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') END)
+ON CONFLICT (user_id, post_id) DO UPDATE SET starred_at = excluded.starred_at;
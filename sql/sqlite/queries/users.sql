@@ -0,0 +1,152 @@
+-- SQLite versions of sql/queries/users.sql, matched by name. Parameters
+-- are numbered like the generated Postgres query.
+
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, name, password_hash)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at, updated_at, name, password_hash, failed_logins, locked_until;
+
+-- name: GetUser :one
+SELECT id, created_at, updated_at, name, password_hash, failed_logins, locked_until
+FROM users
+WHERE id = $1;
+
+-- name: GetUserByName :one
+SELECT id, created_at, updated_at, name, password_hash, failed_logins, locked_until
+FROM users
+WHERE name = $1;
+
+-- name: DeleteAllUsers :exec
+DELETE FROM users;
+
+-- name: GetAllUsers :many
+SELECT name FROM users;
+
+-- name: ListUsersWithoutPassword :many
+SELECT id, created_at, updated_at, name, password_hash, failed_logins, locked_until
+FROM users
+WHERE password_hash IS NULL
+ORDER BY name;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2,
+    failed_logins = 0,
+    locked_until = NULL,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = $1;
+
+-- name: RecordFailedLogin :one
+UPDATE users
+SET failed_logins = failed_logins + 1
+WHERE id = $1
+RETURNING failed_logins;
+
+-- name: LockUser :exec
+UPDATE users
+SET locked_until = $2,
+    failed_logins = 0
+WHERE id = $1;
+
+-- name: ResetFailedLogins :exec
+UPDATE users
+SET failed_logins = 0,
+    locked_until = NULL
+WHERE id = $1;
+
+-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, updated_at, name, url, user_id;
+
+-- name: GetFeed :one
+SELECT id, created_at, updated_at, name, url, user_id
+FROM feeds
+WHERE id = $1;
+
+-- name: GetFeedsByUser :many
+SELECT id, created_at, updated_at, name, url, user_id
+FROM feeds
+WHERE user_id = $1;
+
+-- name: GetAllFeeds :many
+SELECT
+    feeds.id AS feed_id,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name
+FROM feeds
+INNER JOIN users ON feeds.user_id = users.id;
+
+-- name: CreateFeedFollow :one
+-- SQLite has no INSERT in WITH, so the names come from subqueries in
+-- RETURNING instead of a join.
+INSERT INTO feed_follows (user_id, feed_id)
+VALUES ($1, (SELECT id FROM feeds WHERE feeds.url = $2))
+RETURNING
+    id,
+    created_at,
+    updated_at,
+    user_id,
+    feed_id,
+    (SELECT name FROM users WHERE users.id = user_id) AS user_name,
+    (SELECT name FROM feeds WHERE feeds.id = feed_id) AS feed_name;
+
+-- name: GetFeedFollowsForUser :many
+SELECT
+  feed_follows.id,
+  feed_follows.created_at,
+  feed_follows.updated_at,
+  feed_follows.user_id,
+  feed_follows.feed_id,
+  users.name AS user_name,
+  feeds.name AS feed_name,
+  feeds.url AS feed_url,
+  folders.name AS folder_name
+FROM feed_follows
+JOIN users ON feed_follows.user_id = users.id
+JOIN feeds ON feed_follows.feed_id = feeds.id
+LEFT JOIN folder_feeds ON folder_feeds.user_id = feed_follows.user_id AND folder_feeds.feed_id = feed_follows.feed_id
+LEFT JOIN folders ON folders.id = folder_feeds.folder_id
+WHERE feed_follows.user_id = $1;
+
+-- name: GetFeedByURL :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, fetch_full_content
+FROM feeds
+WHERE url = $1;
+
+-- name: DeleteFeedFollow :exec
+DELETE FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: MarkFeedFetched :exec
+UPDATE feeds
+SET
+    last_fetched_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+    updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = $1;
+
+-- name: SetFeedFullContent :exec
+UPDATE feeds
+SET fetch_full_content = $2, updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = $1;
+
+-- name: GetNextFeedToFetch :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, fetch_full_content
+FROM feeds
+ORDER BY last_fetched_at ASC NULLS FIRST
+LIMIT 1;
+
+-- name: CreatePost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, author, categories, content;
+
+-- name: GetPostsForUser :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+       posts.published_at, posts.feed_id, posts.author, posts.categories, posts.content
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+WHERE feeds.user_id = $1
+ORDER BY posts.published_at DESC NULLS LAST
+LIMIT $2;
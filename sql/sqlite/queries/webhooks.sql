@@ -0,0 +1,78 @@
+-- SQLite versions of sql/queries/webhooks.sql, matched by name.
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, user_id, url, secret, feed_id, folder_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at, user_id, url, secret, feed_id, folder_id;
+
+-- name: ListWebhooks :many
+SELECT webhooks.id, webhooks.created_at, webhooks.user_id, webhooks.url, webhooks.secret, webhooks.feed_id, webhooks.folder_id,
+    feeds.name AS feed_name, folders.name AS folder_name
+FROM webhooks
+LEFT JOIN feeds ON feeds.id = webhooks.feed_id
+LEFT JOIN folders ON folders.id = webhooks.folder_id
+WHERE webhooks.user_id = $1
+ORDER BY webhooks.created_at;
+
+-- name: ListWebhooksForFeed :many
+SELECT webhooks.id, webhooks.created_at, webhooks.user_id, webhooks.url, webhooks.secret, webhooks.feed_id, webhooks.folder_id,
+    folder_feeds.folder_id AS feed_folder_id
+FROM webhooks
+JOIN feed_follows ON feed_follows.user_id = webhooks.user_id
+LEFT JOIN folder_feeds ON folder_feeds.user_id = feed_follows.user_id AND folder_feeds.feed_id = feed_follows.feed_id
+WHERE feed_follows.feed_id = $1
+  AND (webhooks.feed_id IS NULL OR webhooks.feed_id = feed_follows.feed_id)
+ORDER BY webhooks.created_at;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE user_id = $1 AND id = $2;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, post_id, payload, next_attempt_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ClaimDueWebhookDeliveries :many
+-- SQLite runs one write at a time, so the update alone claims the rows; its
+-- RETURNING clause can't name webhooks, hence the subqueries.
+UPDATE webhook_deliveries
+SET status = 'sending', next_attempt_at = $1
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status IN ('pending', 'sending') AND next_attempt_at <= $2
+    ORDER BY next_attempt_at
+    LIMIT $3
+)
+RETURNING id, created_at, webhook_id, post_id, payload, status, attempts, next_attempt_at,
+    last_attempt_at, last_error, response_status,
+    (SELECT url FROM webhooks WHERE webhooks.id = webhook_deliveries.webhook_id) AS url,
+    (SELECT secret FROM webhooks WHERE webhooks.id = webhook_deliveries.webhook_id) AS secret;
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = $3,
+    next_attempt_at = $4,
+    last_attempt_at = $5,
+    last_error = $6,
+    response_status = $7
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT webhook_deliveries.id, webhook_deliveries.created_at, webhook_deliveries.webhook_id, webhook_deliveries.post_id,
+    webhook_deliveries.payload, webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at,
+    webhook_deliveries.last_attempt_at, webhook_deliveries.last_error, webhook_deliveries.response_status,
+    webhooks.url, posts.title
+FROM webhook_deliveries
+JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+JOIN posts ON posts.id = webhook_deliveries.post_id
+WHERE webhooks.user_id = $1
+  AND ($2 IS NULL OR webhook_deliveries.status = $2)
+ORDER BY webhook_deliveries.created_at DESC, webhook_deliveries.id DESC
+LIMIT $3;
+
+-- name: RetryWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending', next_attempt_at = $3
+WHERE id = $1 AND status = 'failed'
+  AND webhook_id IN (SELECT id FROM webhooks WHERE user_id = $2);
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE folders (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    parent_id TEXT REFERENCES folders(id) ON DELETE SET NULL,
+    UNIQUE(user_id, name)
+);
+
+CREATE TABLE folder_feeds (
+    user_id TEXT NOT NULL,
+    feed_id TEXT NOT NULL,
+    folder_id TEXT NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, feed_id),
+    FOREIGN KEY (user_id, feed_id) REFERENCES feed_follows(user_id, feed_id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE folder_feeds;
+DROP TABLE folders;
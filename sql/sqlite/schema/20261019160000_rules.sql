@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE rules (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    feed_id TEXT REFERENCES feeds(id) ON DELETE CASCADE,
+    title_pattern TEXT NOT NULL,
+    action TEXT NOT NULL
+);
+
+ALTER TABLE post_states ADD COLUMN hidden_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE post_states DROP COLUMN hidden_at;
+DROP TABLE rules;
@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE watches (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    feed_id TEXT REFERENCES feeds(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    notify TEXT NOT NULL,
+    UNIQUE(user_id, name)
+);
+
+CREATE TABLE alerts (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    watch_id TEXT NOT NULL REFERENCES watches(id) ON DELETE CASCADE,
+    post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    acknowledged_at TIMESTAMP,
+    UNIQUE(watch_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE alerts;
+DROP TABLE watches;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE digest_settings (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT NOT NULL DEFAULT '',
+    schedule TEXT NOT NULL DEFAULT 'daily',
+    last_sent_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE digest_settings;
@@ -0,0 +1,5 @@
+-- +goose Up
+UPDATE watches SET notify = 'local' WHERE notify LIKE 'exec:%' OR notify LIKE 'file:%';
+
+-- +goose Down
+UPDATE watches SET notify = 'stdout' WHERE notify = 'local';
@@ -0,0 +1,5 @@
+-- +goose Up
+UPDATE watches SET notify = 'none' WHERE notify = 'stdout';
+
+-- +goose Down
+UPDATE watches SET notify = 'stdout' WHERE notify = 'none';
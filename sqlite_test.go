@@ -0,0 +1,437 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func openTestSQLite(t *testing.T) *dbConn {
+	t.Helper()
+	conn, err := openDatabase("sqlite://" + filepath.Join(t.TempDir(), "gator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestSQLiteHasEveryQuery(t *testing.T) {
+	nameRe := regexp.MustCompile(`(?m)^-- name: (\w+)`)
+	collect := func(dir string) []string {
+		files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range nameRe.FindAllStringSubmatch(string(data), -1) {
+				names = append(names, m[1])
+			}
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	postgres, sqlite := collect("sql/queries"), collect("sql/sqlite/queries")
+	if !slices.Equal(postgres, sqlite) {
+		t.Errorf("query names differ\npostgres: %v\nsqlite:   %v", postgres, sqlite)
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	conn := openTestSQLite(t)
+
+	if err := conn.migrator.Check(ctx); err == nil {
+		t.Fatal("Check passed on an empty database")
+	}
+	if _, err := conn.migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.migrator.Check(ctx); err != nil {
+		t.Fatalf("Check after up: %v", err)
+	}
+	if _, err := conn.migrator.Redo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for range conn.migrator.Migrations {
+		if _, err := conn.migrator.Down(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statuses, err := conn.migrator.Statuses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range statuses {
+		if st.AppliedAt.Valid {
+			t.Errorf("%s still applied after down", st.Name)
+		}
+	}
+}
+
+func TestSQLiteQueries(t *testing.T) {
+	ctx := context.Background()
+	conn := openTestSQLite(t)
+	if _, err := conn.migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	db := conn.queries
+
+	now := time.Now()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateUser(ctx, database.CreateUserParams{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice",
+	}); !isUniqueViolation(err) {
+		t.Errorf("duplicate user: err = %v, want a unique violation", err)
+	}
+
+	feed, err := addFeed(ctx, db, user, "Go Blog", "https://go.dev/blog/feed.atom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	follows, err := db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil || len(follows) != 1 || follows[0].FeedName != "Go Blog" || follows[0].UserName != "alice" {
+		t.Fatalf("follows = %+v, %v", follows, err)
+	}
+
+	published := time.Date(2026, 10, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	for i, cats := range [][]string{{"go", "release notes"}, {"rust"}, {}} {
+		_, err := db.CreatePost(ctx, database.CreatePostParams{
+			ID:          uuid.New(),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Title:       "post " + string(rune('a'+i)),
+			Url:         "https://go.dev/blog/" + string(rune('a'+i)),
+			PublishedAt: sql.NullTime{Time: published.Add(time.Duration(i) * time.Hour), Valid: true},
+			FeedID:      feed.ID,
+			Categories:  cats,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(opts browseOptions) []string {
+		t.Helper()
+		if opts.limit == 0 {
+			opts.limit = 10
+		}
+		if opts.sortBy == "" {
+			opts.sortBy = "published"
+		}
+		posts, _, err := listPosts(ctx, db, user, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, p := range posts {
+			titles = append(titles, p.Title)
+		}
+		return titles
+	}
+
+	if got := list(browseOptions{}); !slices.Equal(got, []string{"post c", "post b", "post a"}) {
+		t.Errorf("all posts = %v", got)
+	}
+	if got := list(browseOptions{category: "release notes"}); !slices.Equal(got, []string{"post a"}) {
+		t.Errorf("post category filter = %v", got)
+	}
+
+	// Categories nest: browsing "tech" includes feeds filed under tech/go
+	if _, err := createCategory(ctx, db, user, "tech", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createCategory(ctx, db, user, "go", "tech"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createCategory(ctx, db, user, "go", ""); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("duplicate category: err = %v", err)
+	}
+	if got := list(browseOptions{folder: "tech"}); len(got) != 0 {
+		t.Errorf("empty category = %v", got)
+	}
+	if err := addToCategory(ctx, db, user, feed.Url, "go"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"go", "tech"} {
+		if got := list(browseOptions{folder: name}); len(got) != 3 {
+			t.Errorf("category %s = %v", name, got)
+		}
+	}
+	folders, err := db.ListFolders(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, rows := categoriesTable(folders); len(rows) != 2 || rows[0][1] != "tech" || rows[1][1] != "tech/go" || rows[1][2] != int64(1) {
+		t.Errorf("categories = %v", rows)
+	}
+	if follows, _ := db.GetFeedFollowsForUser(ctx, user.ID); follows[0].FolderName.String != "go" {
+		t.Errorf("follow category = %v", follows[0].FolderName)
+	}
+	since := sql.NullTime{Time: published.Add(30 * time.Minute), Valid: true}
+	if got := list(browseOptions{since: since}); !slices.Equal(got, []string{"post c", "post b"}) {
+		t.Errorf("since filter = %v", got)
+	}
+
+	posts, cursor, err := listPosts(ctx, db, user, browseOptions{limit: 2, sortBy: "published"})
+	if err != nil || cursor == "" {
+		t.Fatalf("first page: %v, cursor %q", err, cursor)
+	}
+	if got := list(browseOptions{cursor: cursor}); !slices.Equal(got, []string{"post a"}) {
+		t.Errorf("second page = %v", got)
+	}
+
+	if err := db.SetPostRead(ctx, database.SetPostReadParams{UserID: user.ID, PostID: posts[0].ID, Read: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(browseOptions{unread: true}); !slices.Equal(got, []string{"post b", "post a"}) {
+		t.Errorf("unread = %v", got)
+	}
+
+	// Tags come back as a TEXT[] built by the query, sorted by name
+	if err := tagPost(ctx, db, user, posts[0].ID, "team-reading", "#Go", "go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tagPost(ctx, db, user, posts[1].ID, "go"); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(browseOptions{tag: "team-reading"}); !slices.Equal(got, []string{"post c"}) {
+		t.Errorf("tag filter = %v", got)
+	}
+	if err := db.SetPostNote(ctx, database.SetPostNoteParams{UserID: user.ID, PostID: posts[0].ID, Body: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetPostNote(ctx, database.SetPostNoteParams{UserID: user.ID, PostID: posts[0].ID, Body: "*second*"}); err != nil {
+		t.Fatal(err)
+	}
+	tagged, _, err := listPosts(ctx, db, user, browseOptions{limit: 10, sortBy: "published", tag: "go"})
+	if err != nil || len(tagged) != 2 {
+		t.Fatalf("tag go: %d posts, %v", len(tagged), err)
+	}
+	if !slices.Equal(tagged[0].Tags, []string{"go", "team-reading"}) || tagged[0].Note.String != "*second*" {
+		t.Errorf("post c tags %q, note %v", tagged[0].Tags, tagged[0].Note)
+	}
+	if !slices.Equal(tagged[1].Tags, []string{"go"}) || tagged[1].Note.Valid {
+		t.Errorf("post b tags %q, note %v", tagged[1].Tags, tagged[1].Note)
+	}
+	if n, err := db.RemovePostTag(ctx, database.RemovePostTagParams{PostID: posts[0].ID, UserID: user.ID, Name: "go"}); n != 1 || err != nil {
+		t.Errorf("remove tag: %d, %v", n, err)
+	}
+	if tags, _ := db.ListTags(ctx, user.ID); len(tags) != 2 || tags[0].Name != "go" || tags[0].PostCount != 1 {
+		t.Errorf("tags = %+v", tags)
+	}
+
+	rule, err := newRule(ctx, db, user, "Go Blog", "^post [ab]$", ruleHide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateRule(ctx, database.CreateRuleParams(rule.Rule)); err != nil {
+		t.Fatal(err)
+	}
+	if rules, err := db.ListRulesForFeed(ctx, feed.ID); err != nil || len(rules) != 1 || rules[0].TitlePattern != "^post [ab]$" {
+		t.Errorf("rules for feed = %+v, %v", rules, err)
+	}
+	if rules, err := db.ListRules(ctx, user.ID); err != nil || len(rules) != 1 || rules[0].FeedName.String != "Go Blog" {
+		t.Errorf("rules = %+v, %v", rules, err)
+	}
+	matched, err := matchingPosts(ctx, db, user, rule)
+	if err != nil || len(matched) != 2 {
+		t.Fatalf("matching posts = %d, %v", len(matched), err)
+	}
+	for _, post := range matched {
+		if err := applyRule(ctx, db, rule, post.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := list(browseOptions{}); !slices.Equal(got, []string{"post c"}) {
+		t.Errorf("posts after hiding = %v", got)
+	}
+	if got := list(browseOptions{hidden: true}); len(got) != 3 {
+		t.Errorf("posts with hidden = %v", got)
+	}
+	if n, err := db.DeleteRule(ctx, database.DeleteRuleParams{UserID: user.ID, ID: rule.ID}); n != 1 || err != nil {
+		t.Errorf("delete rule: %d, %v", n, err)
+	}
+	if n, err := unhidePosts(ctx, db, user, rule); n != 2 || err != nil {
+		t.Errorf("unhide: %d, %v", n, err)
+	}
+
+	watch, err := db.CreateWatch(ctx, database.CreateWatchParams{
+		ID: uuid.New(), CreatedAt: now, UserID: user.ID, FeedID: uuid.NullUUID{UUID: feed.ID, Valid: true},
+		Name: "releases", Pattern: "release", Notify: "none",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watches, err := db.ListWatchesForFeed(ctx, feed.ID); err != nil || len(watches) != 1 || watches[0].Name != "releases" {
+		t.Errorf("watches for feed = %+v, %v", watches, err)
+	}
+	for _, post := range posts {
+		if _, err := db.CreateAlert(ctx, database.CreateAlertParams{ID: uuid.New(), CreatedAt: now, WatchID: watch.ID, PostID: post.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alerts, err := db.ListAlerts(ctx, database.ListAlertsParams{UserID: user.ID})
+	if err != nil || len(alerts) != 2 || alerts[0].WatchName != "releases" || alerts[0].FeedName != "Go Blog" {
+		t.Fatalf("alerts = %+v, %v", alerts, err)
+	}
+	if n, err := db.AcknowledgeAlert(ctx, database.AcknowledgeAlertParams{ID: alerts[0].ID, UserID: user.ID}); n != 1 || err != nil {
+		t.Errorf("acknowledge alert: %d, %v", n, err)
+	}
+	if watches, err := db.ListWatches(ctx, user.ID); err != nil || len(watches) != 1 || watches[0].Unacknowledged != 1 || watches[0].FeedName.String != "Go Blog" {
+		t.Errorf("watches = %+v, %v", watches, err)
+	}
+	if n, err := db.AcknowledgeAllAlerts(ctx, user.ID); n != 1 || err != nil {
+		t.Errorf("acknowledge all alerts: %d, %v", n, err)
+	}
+	if alerts, err := db.ListAlerts(ctx, database.ListAlertsParams{UserID: user.ID, IncludeAcknowledged: true}); err != nil || len(alerts) != 2 || !alerts[1].AcknowledgedAt.Valid {
+		t.Errorf("acknowledged alerts = %+v, %v", alerts, err)
+	}
+
+	// A webhook on a category covers the feeds in its subcategories
+	webhook, err := newWebhook(ctx, db, testFetcher, user, "https://example.com/hook", "", "tech", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateWebhook(ctx, database.CreateWebhookParams(webhook)); err != nil {
+		t.Fatal(err)
+	}
+	webhooks, err := feedWebhooks(ctx, db, feed.ID)
+	if err != nil || len(webhooks) != 1 || webhooks[0].FeedFolderID.UUID == webhook.FolderID.UUID {
+		t.Fatalf("webhooks for feed = %+v, %v", webhooks, err)
+	}
+	if listed, err := db.ListWebhooks(ctx, user.ID); err != nil || len(listed) != 1 || listed[0].FolderName.String != "tech" {
+		t.Errorf("webhooks = %+v, %v", listed, err)
+	}
+	post, err := db.GetPost(ctx, posts[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queueWebhookDeliveries(ctx, db, webhooks, database.Feed{ID: feed.ID, Name: feed.Name, Url: feed.Url}, post); err != nil {
+		t.Fatal(err)
+	}
+	claim := database.ClaimDueWebhookDeliveriesParams{LeaseUntil: time.Now().Add(time.Minute), Now: time.Now(), Lim: 10}
+	due, err := db.ClaimDueWebhookDeliveries(ctx, claim)
+	if err != nil || len(due) != 1 || due[0].Status != deliverySending || due[0].Secret != webhook.Secret || !strings.Contains(due[0].Payload, post.Url) {
+		t.Fatalf("due deliveries = %+v, %v", due, err)
+	}
+	if again, err := db.ClaimDueWebhookDeliveries(ctx, claim); err != nil || len(again) != 0 {
+		t.Errorf("claimed deliveries claimed again: %+v, %v", again, err)
+	}
+	claim.Now = claim.LeaseUntil
+	if expired, err := db.ClaimDueWebhookDeliveries(ctx, claim); err != nil || len(expired) != 1 {
+		t.Errorf("deliveries with an expired claim = %+v, %v", expired, err)
+	}
+	if err := db.UpdateWebhookDelivery(ctx, database.UpdateWebhookDeliveryParams{
+		ID: due[0].ID, Status: deliveryFailed, Attempts: 1, NextAttemptAt: due[0].NextAttemptAt,
+		LastAttemptAt: sql.NullTime{Time: now, Valid: true}, ResponseStatus: sql.NullInt32{Int32: 502, Valid: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{ID: due[0].ID, UserID: user.ID, NextAttemptAt: now}); n != 1 || err != nil {
+		t.Errorf("retry delivery: %d, %v", n, err)
+	}
+	pending, err := db.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{
+		UserID: user.ID, Status: sql.NullString{String: deliveryPending, Valid: true}, Lim: 10,
+	})
+	if err != nil || len(pending) != 1 || pending[0].Attempts != 1 || pending[0].ResponseStatus.Int32 != 502 || pending[0].Title != post.Title {
+		t.Errorf("pending deliveries = %+v, %v", pending, err)
+	}
+
+	// Recording a digest creates the settings row with its defaults, and
+	// changing the settings keeps the timestamp
+	sent := sql.NullTime{Time: now.Add(time.Minute), Valid: true}
+	if err := db.SetDigestSent(ctx, database.SetDigestSentParams{UserID: user.ID, LastSentAt: sent}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetDigestSettings(ctx, database.SetDigestSettingsParams{UserID: user.ID, Email: "alice@example.com", Schedule: "weekly"}); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := digestSettings(ctx, db, user.ID)
+	if err != nil || settings.Email != "alice@example.com" || settings.Schedule != "weekly" || !settings.LastSentAt.Time.Equal(sent.Time) {
+		t.Errorf("digest settings = %+v, %v", settings, err)
+	}
+	if d, err := collectDigest(ctx, db, user, now.Add(-time.Minute), sent.Time, 10); err != nil || d.total == 0 || d.feeds[0].Name != "Go Blog" {
+		t.Errorf("digest = %+v, %v", d, err)
+	}
+	if d, err := collectDigest(ctx, db, user, sent.Time, sent.Time.Add(time.Hour), 10); err != nil || d.total != 0 {
+		t.Errorf("digest after the last one = %+v, %v", d, err)
+	}
+
+	// Full content is stored per post and searched along with the title
+	if err := db.SetFeedFullContent(ctx, database.SetFeedFullContentParams{ID: feed.ID, FetchFullContent: true}); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := db.GetFeedByURL(ctx, feed.Url); err != nil || !f.FetchFullContent {
+		t.Errorf("feed = %+v, %v", f, err)
+	}
+	all, _, err := listPosts(ctx, db, user, browseOptions{limit: 10, sortBy: "published"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := sql.NullString{String: "<p>Generics, <em>Finally</em></p>", Valid: true}
+	if err := db.SetPostContent(ctx, database.SetPostContentParams{ID: all[2].ID, Content: content}); err != nil {
+		t.Fatal(err)
+	}
+	if post, err := db.GetPost(ctx, all[2].ID); err != nil || post.Content != content {
+		t.Errorf("post content = %v, %v", post.Content, err)
+	}
+	if got := list(browseOptions{search: "finally"}); !slices.Equal(got, []string{"post a"}) {
+		t.Errorf("content search = %v", got)
+	}
+	if got := list(browseOptions{search: "POST B"}); !slices.Equal(got, []string{"post b"}) {
+		t.Errorf("title search = %v", got)
+	}
+
+	key, _, err := createAPIKey(ctx, db, user, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := authenticate(ctx, db, key); err != nil || got.ID != user.ID {
+		t.Errorf("authenticate = %v, %v", got.Name, err)
+	}
+
+	if users, err := db.ListUsersWithoutPassword(ctx); err != nil || len(users) != 1 || users[0].ID != user.ID {
+		t.Errorf("users without password = %v, %v", users, err)
+	}
+	token, _, err := issueResetToken(ctx, db, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset, err := db.GetPasswordReset(ctx, user.ID); err != nil || reset.TokenHash != hashAPIKey(token) || !reset.ExpiresAt.After(time.Now()) {
+		t.Errorf("password reset = %+v, %v", reset, err)
+	}
+	if err := db.RevokeAllAPIKeys(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticate(ctx, db, key); err == nil {
+		t.Error("key still works after RevokeAllAPIKeys")
+	}
+
+	if err := db.DeleteAllUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetFeedByURL(ctx, feed.Url); err != sql.ErrNoRows {
+		t.Errorf("feed survived deleting its user: %v", err)
+	}
+}
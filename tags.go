@@ -0,0 +1,247 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+// Tags and notes are a user's own annotations on posts: the same post can
+// carry different tags and notes for every user who follows its feed.
+
+const maxTagLen = 64
+
+func tagFlags(fs *flag.FlagSet) {
+	fs.Bool("remove", false, "remove the tags instead of adding them")
+}
+
+func handlerTag(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+
+	if len(cmd.Args) == 0 {
+		tags, err := s.db.ListTags(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list tags: %w", err)
+		}
+		if len(tags) == 0 {
+			s.out.notef("No tags yet: tag a post with 'gator tag <post_id> <tag>'\n")
+		}
+		return s.out.render(tagsTable(tags))
+	}
+
+	post, err := getPost(ctx, s.db, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	if cmd.flagBool("remove") {
+		if len(cmd.Args) == 1 {
+			return fmt.Errorf("usage: gator tag --remove <post_id> <tag>...")
+		}
+		for _, name := range cmd.Args[1:] {
+			n, err := s.db.RemovePostTag(ctx, database.RemovePostTagParams{PostID: post.ID, UserID: user.ID, Name: normalizeTag(name)})
+			if err != nil {
+				return fmt.Errorf("failed to remove tag: %w", err)
+			}
+			if n == 0 {
+				s.out.notef("%s wasn't tagged %s\n", post.Title, name)
+			}
+		}
+	} else if err := tagPost(ctx, s.db, user, post.ID, cmd.Args[1:]...); err != nil {
+		return err
+	}
+
+	tags, err := s.db.ListPostTags(ctx, database.ListPostTagsParams{UserID: user.ID, PostID: post.ID})
+	if err != nil {
+		return fmt.Errorf("failed to get tags: %w", err)
+	}
+	s.out.notef("Tags of %s:\n", post.Title)
+	rows := make([][]any, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, []any{tag})
+	}
+	return s.out.render([]string{"tag"}, rows)
+}
+
+// normalizeTag makes "#Go " and "go" the same tag.
+func normalizeTag(name string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+}
+
+// validTag rejects the characters that would need quoting in a TEXT[]
+// literal, as well as whitespace, so tags stay easy to type and filter on.
+func validTag(name string) error {
+	if name == "" || len(name) > maxTagLen || strings.ContainsAny(name, " \t\r\n,\"\\{}") {
+		return fmt.Errorf("invalid tag %q: tags are 1 to %d characters without spaces, commas, quotes, backslashes or braces", name, maxTagLen)
+	}
+	return nil
+}
+
+// tagPost adds the user's tags to a post, creating the tags as needed.
+func tagPost(ctx context.Context, db database.Querier, user database.User, postID uuid.UUID, names ...string) error {
+	for i, name := range names {
+		names[i] = normalizeTag(name)
+		if err := validTag(names[i]); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		tag, err := db.UpsertTag(ctx, database.UpsertTagParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UserID:    user.ID,
+			Name:      name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create tag %s: %w", name, err)
+		}
+		if err := db.AddPostTag(ctx, database.AddPostTagParams{TagID: tag.ID, PostID: postID}); err != nil {
+			return fmt.Errorf("failed to tag post: %w", err)
+		}
+	}
+	return nil
+}
+
+func tagsTable(tags []database.ListTagsRow) ([]string, [][]any) {
+	rows := make([][]any, 0, len(tags))
+	for _, t := range tags {
+		rows = append(rows, []any{t.Name, t.PostCount})
+	}
+	return []string{"tag", "posts"}, rows
+}
+
+// getPost looks up a post by the id browse prints.
+func getPost(ctx context.Context, db database.Querier, id string) (database.Post, error) {
+	postID, err := uuid.Parse(id)
+	if err != nil {
+		return database.Post{}, fmt.Errorf("invalid post id %q: use the id shown by 'gator browse'", id)
+	}
+	post, err := db.GetPost(ctx, postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return post, fmt.Errorf("no post with id %s", id)
+	}
+	if err != nil {
+		return post, fmt.Errorf("failed to get post: %w", err)
+	}
+	return post, nil
+}
+
+func noteFlags(fs *flag.FlagSet) {
+	fs.Bool("print", false, "print the note instead of editing it")
+	fs.Bool("delete", false, "delete the note")
+}
+
+// handlerNote edits the user's markdown note on a post in $VISUAL or
+// $EDITOR. When stdin isn't a terminal the note is read from it instead, so
+// `echo "..." | gator note <post_id>` works in scripts. An empty note
+// deletes it.
+func handlerNote(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+	post, err := getPost(ctx, s.db, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	key := database.GetPostNoteParams{UserID: user.ID, PostID: post.ID}
+
+	if cmd.flagBool("delete") {
+		return deleteNote(s, post, key)
+	}
+
+	note, err := s.db.GetPostNote(ctx, key)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get note: %w", err)
+	}
+	if cmd.flagBool("print") {
+		if note.Body == "" {
+			return fmt.Errorf("no note on %s", post.Title)
+		}
+		fmt.Fprintln(s.out.w, note.Body)
+		return nil
+	}
+
+	var body string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		body, err = editNote(note.Body)
+	} else {
+		var data []byte
+		data, err = io.ReadAll(stdinLines)
+		body = string(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	body = strings.TrimSpace(body)
+	switch body {
+	case note.Body:
+		s.out.notef("Note unchanged\n")
+		return nil
+	case "":
+		return deleteNote(s, post, key)
+	}
+	if err := s.db.SetPostNote(ctx, database.SetPostNoteParams{UserID: user.ID, PostID: post.ID, Body: body}); err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+	s.out.notef("Saved note on %s\n", post.Title)
+	return nil
+}
+
+func deleteNote(s *State, post database.Post, key database.GetPostNoteParams) error {
+	n, err := s.db.DeletePostNote(context.Background(), database.DeletePostNoteParams(key))
+	if err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no note on %s", post.Title)
+	}
+	s.out.notef("Deleted note on %s\n", post.Title)
+	return nil
+}
+
+// editNote opens the note in the user's editor and returns what they saved.
+func editNote(body string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	f, err := os.CreateTemp("", "gator-note-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create note file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.WriteString(f, body); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write note file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write note file: %w", err)
+	}
+
+	// $EDITOR may carry arguments, e.g. "code --wait"
+	args := append(strings.Fields(editor), f.Name())
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read note file: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"blog/internal/database"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+const (
+	sessionCookie   = "gator_session"
+	sessionLifetime = 30 * 24 * time.Hour
+)
+
+const summaryLen = 300
+
+var templateFuncs = template.FuncMap{
+	"summary": func(description sql.NullString) string {
+		text := strings.Join(strings.Fields(htmlText(description.String)), " ")
+		return strings.TrimRight(fit(text, summaryLen), " ")
+	},
+	"date": func(post database.GetPostsForUserFilteredRow) string {
+		return postSortKey("published", post).Format("Jan 2, 2006 15:04")
+	},
+}
+
+// pages maps a page name to its template, each combined with the layout.
+var pages = func() map[string]*template.Template {
+	m := make(map[string]*template.Template)
+	for _, name := range []string{"river", "feeds", "login", "error"} {
+		m[name] = template.Must(template.New("layout.html").Funcs(templateFuncs).
+			ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html"))
+	}
+	return m
+}()
+
+type webPage struct {
+	Title    string
+	User     *database.User
+	Error    string
+	Posts    []database.GetPostsForUserFilteredRow
+	Path     string
+	Filter   string
+	NextURL  string
+	Feed     *database.Feed
+	Followed bool
+	Feeds    []webFeed
+}
+
+type webFeed struct {
+	ID       uuid.UUID
+	Name     string
+	Url      string
+	Followed bool
+	AddedBy  string
+}
+
+type webHandler func(w http.ResponseWriter, r *http.Request, user database.User) error
+
+func (a *apiServer) webRoutes(mux *http.ServeMux) {
+	mux.Handle("GET /{$}", a.withSession(a.handleRiver))
+	mux.Handle("GET /feeds", a.withSession(a.handleWebFeeds))
+	mux.Handle("GET /feeds/{feedID}", a.withSession(a.handleWebFeed))
+	mux.Handle("POST /follows", a.withSession(a.handleWebFollow))
+	mux.Handle("POST /follows/{feedID}/delete", a.withSession(a.handleWebUnfollow))
+	mux.Handle("POST /posts/{postID}/read", a.withSession(a.handleWebRead))
+	mux.HandleFunc("GET /login", a.handleLoginForm)
+	mux.HandleFunc("POST /login", a.handleLogin)
+	mux.HandleFunc("POST /logout", a.handleLogout)
+}
+
+// withSession authenticates browser requests with the API key stored in the
+// session cookie at login, sending everyone else to the login form.
+func (a *apiServer) withSession(h webHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		user, err := authenticate(r.Context(), a.s.db, cookie.Value)
+		if err != nil {
+			clearSession(w)
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		if err := h(w, r, user); err != nil {
+			status := http.StatusInternalServerError
+			msg := "Something went wrong."
+			var apiErr apiError
+			switch {
+			case errors.As(err, &apiErr):
+				status, msg = apiErr.status, apiErr.msg
+			case errors.Is(err, sql.ErrNoRows):
+				status, msg = http.StatusNotFound, "Not found."
+			default:
+				log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+			}
+			w.WriteHeader(status)
+			renderPage(w, "error", webPage{Title: http.StatusText(status), User: &user, Error: msg})
+		}
+	})
+}
+
+func renderPage(w http.ResponseWriter, name string, page webPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pages[name].Execute(w, page); err != nil {
+		log.Printf("rendering %s: %v", name, err)
+	}
+}
+
+// redirectBack returns to the page the form was posted from.
+func redirectBack(w http.ResponseWriter, r *http.Request) {
+	target := "/"
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && strings.HasPrefix(ref.Path, "/") {
+		target = ref.RequestURI()
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+func (a *apiServer) handleRiver(w http.ResponseWriter, r *http.Request, user database.User) error {
+	return a.renderPosts(w, r, user, nil)
+}
+
+func (a *apiServer) handleWebFeed(w http.ResponseWriter, r *http.Request, user database.User) error {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		return apiErrorf(http.StatusNotFound, "No such feed.")
+	}
+	feed, err := a.s.db.GetFeed(r.Context(), feedID)
+	if err != nil {
+		return err
+	}
+	return a.renderPosts(w, r, user, &database.Feed{ID: feed.ID, Name: feed.Name, Url: feed.Url})
+}
+
+func (a *apiServer) isFollowing(ctx context.Context, user database.User, feedID uuid.UUID) (bool, error) {
+	follows, err := a.s.db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range follows {
+		if f.FeedID == feedID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// renderPosts is the web version of browse: the query string takes the same
+// options, and feed pages pin --feed.
+func (a *apiServer) renderPosts(w http.ResponseWriter, r *http.Request, user database.User, feed *database.Feed) error {
+	query := r.URL.Query()
+	if !query.Has("limit") {
+		query.Set("limit", "30")
+	}
+	if feed != nil {
+		query.Set("feed", feed.Url)
+	}
+
+	opts, err := browseOptionsFromQuery(query)
+	if err != nil {
+		return apiErrorf(http.StatusBadRequest, "%v", err)
+	}
+	posts, nextCursor, err := listPosts(r.Context(), a.s.db, user, opts)
+	if err != nil {
+		return err
+	}
+
+	page := webPage{
+		Title: "All posts",
+		User:  &user,
+		Posts: posts,
+		Path:  r.URL.Path,
+		Feed:  feed,
+	}
+	if feed != nil {
+		page.Title = feed.Name
+		if page.Followed, err = a.isFollowing(r.Context(), user, feed.ID); err != nil {
+			return err
+		}
+	}
+	switch {
+	case opts.unread:
+		page.Filter = "unread"
+	case opts.starred:
+		page.Filter = "starred"
+	}
+	if nextCursor != "" {
+		next := r.URL.Query()
+		next.Del("page")
+		next.Del("offset")
+		next.Set("cursor", nextCursor)
+		page.NextURL = r.URL.Path + "?" + next.Encode()
+	}
+	renderPage(w, "river", page)
+	return nil
+}
+
+func (a *apiServer) handleWebFeeds(w http.ResponseWriter, r *http.Request, user database.User) error {
+	ctx := r.Context()
+	follows, err := a.s.db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	followed := make(map[string]bool, len(follows))
+	for _, f := range follows {
+		followed[f.FeedUrl] = true
+	}
+
+	feeds, err := a.s.db.GetAllFeeds(ctx)
+	if err != nil {
+		return err
+	}
+	page := webPage{Title: "Feeds", User: &user}
+	for _, f := range feeds {
+		page.Feeds = append(page.Feeds, webFeed{
+			ID:       f.FeedID,
+			Name:     f.FeedName,
+			Url:      f.FeedUrl,
+			Followed: followed[f.FeedUrl],
+			AddedBy:  f.UserName,
+		})
+	}
+	renderPage(w, "feeds", page)
+	return nil
+}
+
+func (a *apiServer) handleWebFollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	_, err := a.s.db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		UserID: user.ID,
+		Url:    r.FormValue("url"),
+	})
+	if err != nil {
+		return err
+	}
+	redirectBack(w, r)
+	return nil
+}
+
+func (a *apiServer) handleWebUnfollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		return apiErrorf(http.StatusNotFound, "No such feed.")
+	}
+	err = a.s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		return err
+	}
+	redirectBack(w, r)
+	return nil
+}
+
+func (a *apiServer) handleWebRead(w http.ResponseWriter, r *http.Request, user database.User) error {
+	postID, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		return apiErrorf(http.StatusNotFound, "No such post.")
+	}
+	post, err := a.s.db.GetPost(r.Context(), postID)
+	if err != nil {
+		return err
+	}
+	if following, err := a.isFollowing(r.Context(), user, post.FeedID); err != nil {
+		return err
+	} else if !following {
+		return apiErrorf(http.StatusNotFound, "No such post.")
+	}
+	err = a.s.db.SetPostRead(r.Context(), database.SetPostReadParams{
+		UserID: user.ID,
+		PostID: postID,
+		Read:   r.FormValue("read") == "true",
+	})
+	if err != nil {
+		return err
+	}
+	redirectBack(w, r)
+	return nil
+}
+
+func (a *apiServer) handleLoginForm(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, "login", webPage{Title: "Log in"})
+}
+
+// handleLogin checks the password like `gator login` and keeps an API key in
+// an HttpOnly cookie. A browser that still has a working session for the user
+// keeps its key; otherwise a new "web" key is issued, and web keys unused for
+// longer than a session lasts are revoked so they don't pile up.
+func (a *apiServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	fail := func(status int, msg string) {
+		w.WriteHeader(status)
+		renderPage(w, "login", webPage{Title: "Log in", Error: msg})
+	}
+
+	ctx := r.Context()
+	user, err := a.s.db.GetUserByName(ctx, r.FormValue("username"))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !user.PasswordHash.Valid) {
+		fail(http.StatusUnauthorized, errWrongPassword.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("login: %v", err)
+		fail(http.StatusInternalServerError, "Something went wrong.")
+		return
+	}
+	if err := checkPassword(ctx, a.s.db, user, r.FormValue("password")); err != nil {
+		fail(http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	key, err := a.sessionKey(r, user)
+	if err != nil {
+		log.Printf("login: %v", err)
+		fail(http.StatusInternalServerError, "Something went wrong.")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    key,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Now().Add(sessionLifetime),
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (a *apiServer) sessionKey(r *http.Request, user database.User) (string, error) {
+	ctx := r.Context()
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if current, err := authenticate(ctx, a.s.db, cookie.Value); err == nil && current.ID == user.ID {
+			return cookie.Value, nil
+		}
+	}
+
+	_, err := a.s.db.RevokeStaleAPIKeys(ctx, database.RevokeStaleAPIKeysParams{
+		UserID: user.ID,
+		Name:   "web",
+		Before: time.Now().Add(-sessionLifetime),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to revoke stale web keys: %w", err)
+	}
+	key, _, err := createAPIKey(ctx, a.s.db, user, "web")
+	return key, err
+}
+
+// handleLogout revokes the session's API key as well as dropping the cookie.
+func (a *apiServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if user, err := authenticate(r.Context(), a.s.db, cookie.Value); err == nil {
+			body := strings.TrimPrefix(cookie.Value, apiKeyScheme)
+			if len(body) >= apiKeyPrefixLen {
+				a.s.db.RevokeAPIKey(context.WithoutCancel(r.Context()), database.RevokeAPIKeyParams{
+					UserID: user.ID,
+					Prefix: body[:apiKeyPrefixLen],
+				})
+			}
+		}
+	}
+	clearSession(w)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func clearSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+}
+
+// browseOptionsFromQuery parses browse flags given as query parameters, so
+// the API and web UI accept exactly what `gator browse` does.
+func browseOptionsFromQuery(query url.Values) (browseOptions, error) {
+	cmd := Command{Name: "browse", Flags: commandSpec{Name: "browse", Flags: browseFlags}.flagSet()}
+	for name, values := range query {
+		if cmd.Flags.Lookup(name) == nil {
+			return browseOptions{}, fmt.Errorf("unknown query parameter %q", name)
+		}
+		if err := cmd.Flags.Set(name, values[len(values)-1]); err != nil {
+			return browseOptions{}, fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	return parseBrowseArgs(cmd)
+}
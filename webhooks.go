@@ -0,0 +1,485 @@
+package main
+
+import (
+	"blog/internal/database"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhooks POST each new post of the feeds they cover to a URL. scrapeFeed
+// queues a delivery per post and webhook, and deliverWebhooks, run by agg
+// after every fetch, claims the due ones, sends them and retries failures
+// with exponential backoff. Requests are signed with the webhook's secret:
+// X-Gator-Timestamp is the Unix time of the attempt, and X-Gator-Signature
+// is "sha256=" and the hex HMAC-SHA256 of the timestamp, a dot and the body,
+// so receivers can reject old requests replayed with their signature.
+
+const (
+	deliveryPending   = "pending"
+	deliverySending   = "sending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+// A failed delivery is retried after webhookBackoff, then twice as long each
+// time up to webhookMaxBackoff, and given up after webhookMaxAttempts.
+var (
+	webhookBackoff     = time.Minute
+	webhookMaxBackoff  = 6 * time.Hour
+	webhookMaxAttempts = 8
+)
+
+// webhookBatch is how many due deliveries deliverWebhooks claims per run,
+// and webhookConcurrency how many of them it sends at once.
+const (
+	webhookBatch       = 100
+	webhookConcurrency = 8
+)
+
+// webhookDeadline bounds a deliverWebhooks run; deliveries not sent by then
+// go back to pending. webhookLease is how long a claim lasts, so the
+// deliveries of an agg that stopped mid-run are sent by the next one.
+var (
+	webhookDeadline = time.Minute
+	webhookLease    = 5 * time.Minute
+)
+
+func webhookFlags(fs *flag.FlagSet) {
+	fs.String("feed", "", "add: only posts from the followed feed with this name or url")
+	fs.String("folder", "", "add: only posts from feeds in this 'gator category' or its subcategories")
+	fs.String("secret", "", "add: signing secret, generated if empty")
+	fs.String("status", "", "deliveries: only pending, sending, delivered or failed deliveries")
+	fs.Int("limit", 20, "deliveries: number of deliveries to show")
+}
+
+func handlerWebhook(s *State, cmd Command, user database.User) error {
+	ctx := context.Background()
+
+	switch action := cmd.Args[0]; action {
+	case "add":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator webhook add [--feed <feed>] [--folder <category>] [--secret <secret>] <url>")
+		}
+		webhook, err := newWebhook(ctx, s.db, s.fetcher, user, cmd.Args[1], cmd.flagString("feed"), cmd.flagString("folder"), cmd.flagString("secret"))
+		if err != nil {
+			return err
+		}
+		if webhook, err = s.db.CreateWebhook(ctx, database.CreateWebhookParams(webhook)); err != nil {
+			return fmt.Errorf("failed to add webhook: %w", err)
+		}
+		fmt.Fprintf(s.out.w, "Added webhook %s, signing with secret:\n\n    %s\n\n", webhook.ID, webhook.Secret)
+		fmt.Fprintln(s.out.w, "Verify the X-Gator-Signature header with it; it isn't shown again.")
+		return nil
+
+	case "list":
+		if len(cmd.Args) != 1 {
+			return fmt.Errorf("usage: gator webhook list")
+		}
+		webhooks, err := s.db.ListWebhooks(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list webhooks: %w", err)
+		}
+		if len(webhooks) == 0 {
+			s.out.notef("No webhooks yet: add one with 'gator webhook add <url>'\n")
+		}
+		return s.out.render(webhooksTable(webhooks))
+
+	case "remove":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator webhook remove <webhook_id>")
+		}
+		id, err := uuid.Parse(cmd.Args[1])
+		if err != nil {
+			return fmt.Errorf("invalid webhook id %q: see 'gator webhook list'", cmd.Args[1])
+		}
+		n, err := s.db.DeleteWebhook(ctx, database.DeleteWebhookParams{UserID: user.ID, ID: id})
+		if err != nil {
+			return fmt.Errorf("failed to remove webhook: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no webhook %s: see 'gator webhook list'", id)
+		}
+		s.out.notef("Removed webhook %s and its deliveries\n", id)
+		return nil
+
+	case "deliveries":
+		if len(cmd.Args) != 1 {
+			return fmt.Errorf("usage: gator webhook deliveries [--status <status>] [--limit <n>]")
+		}
+		status := cmd.flagString("status")
+		switch status {
+		case "", deliveryPending, deliverySending, deliveryDelivered, deliveryFailed:
+		default:
+			return fmt.Errorf("invalid --status %q: expected pending, sending, delivered or failed", status)
+		}
+		deliveries, err := s.db.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{
+			UserID: user.ID,
+			Status: sql.NullString{String: status, Valid: status != ""},
+			Lim:    int32(max(cmd.flagInt("limit"), 0)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list deliveries: %w", err)
+		}
+		return s.out.render(deliveriesTable(deliveries))
+
+	case "retry":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("usage: gator webhook retry <delivery_id>")
+		}
+		id, err := uuid.Parse(cmd.Args[1])
+		if err != nil {
+			return fmt.Errorf("invalid delivery id %q: see 'gator webhook deliveries'", cmd.Args[1])
+		}
+		n, err := s.db.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{ID: id, UserID: user.ID, NextAttemptAt: time.Now()})
+		if err != nil {
+			return fmt.Errorf("failed to retry delivery: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no failed delivery %s: see 'gator webhook deliveries --status failed'", id)
+		}
+		s.out.notef("Queued delivery %s again; agg sends it on its next run\n", id)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown webhook action %q: expected add, list, remove, deliveries or retry", action)
+	}
+}
+
+// newWebhook checks the arguments of `webhook add`, refusing hosts f won't
+// send to.
+func newWebhook(ctx context.Context, db database.Querier, f fetcher, user database.User, rawURL, feed, category, secret string) (database.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return database.Webhook{}, fmt.Errorf("invalid webhook url %q: expected http:// or https://", rawURL)
+	}
+	if f.publicOnly {
+		if err := checkPublicHost(ctx, u.Hostname()); err != nil {
+			return database.Webhook{}, fmt.Errorf("invalid webhook url %q: agg only sends to public addresses: %w", rawURL, err)
+		}
+	}
+	feedID, err := followedFeedID(ctx, db, user, feed)
+	if err != nil {
+		return database.Webhook{}, err
+	}
+	var folderID uuid.NullUUID
+	if category != "" {
+		folder, err := db.GetFolderByName(ctx, database.GetFolderByNameParams{UserID: user.ID, Name: category})
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Webhook{}, fmt.Errorf("no category %q", category)
+		}
+		if err != nil {
+			return database.Webhook{}, fmt.Errorf("failed to get category: %w", err)
+		}
+		folderID = uuid.NullUUID{UUID: folder.ID, Valid: true}
+	}
+	if secret == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return database.Webhook{}, fmt.Errorf("failed to generate secret: %w", err)
+		}
+		secret = hex.EncodeToString(b)
+	}
+	return database.Webhook{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		Url:       rawURL,
+		Secret:    secret,
+		FeedID:    feedID,
+		FolderID:  folderID,
+	}, nil
+}
+
+// feedWebhooks returns the webhooks that cover a feed, checking the
+// category of the ones scoped to a category against each user's folders.
+func feedWebhooks(ctx context.Context, db database.Querier, feedID uuid.UUID) ([]database.ListWebhooksForFeedRow, error) {
+	rows, err := db.ListWebhooksForFeed(ctx, feedID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get webhooks: %w", err)
+	}
+	parents := make(map[uuid.UUID]map[uuid.UUID]uuid.NullUUID)
+	var webhooks []database.ListWebhooksForFeedRow
+	for _, w := range rows {
+		if w.FolderID.Valid {
+			if !w.FeedFolderID.Valid {
+				continue
+			}
+			if parents[w.UserID] == nil {
+				folders, err := db.ListFolders(ctx, w.UserID)
+				if err != nil {
+					return nil, fmt.Errorf("couldn't get categories: %w", err)
+				}
+				parents[w.UserID] = make(map[uuid.UUID]uuid.NullUUID)
+				for _, f := range folders {
+					parents[w.UserID][f.ID] = f.ParentID
+				}
+			}
+			if !inFolder(parents[w.UserID], w.FeedFolderID.UUID, w.FolderID.UUID) {
+				continue
+			}
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, nil
+}
+
+// inFolder reports whether folder is ancestor or nested inside it.
+func inFolder(parents map[uuid.UUID]uuid.NullUUID, folder, ancestor uuid.UUID) bool {
+	for range len(parents) + 1 {
+		if folder == ancestor {
+			return true
+		}
+		parent := parents[folder]
+		if !parent.Valid {
+			return false
+		}
+		folder = parent.UUID
+	}
+	return false
+}
+
+type webhookPayload struct {
+	Event string      `json:"event"`
+	Feed  webhookFeed `json:"feed"`
+	Post  webhookPost `json:"post"`
+}
+
+type webhookFeed struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	URL  string    `json:"url"`
+}
+
+type webhookPost struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description string     `json:"description,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	Categories  []string   `json:"categories"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// queueWebhookDeliveries queues a delivery of a new post to each webhook. The
+// payload is stored with the delivery, so retries send the same body.
+func queueWebhookDeliveries(ctx context.Context, db database.Querier, webhooks []database.ListWebhooksForFeedRow, feed database.Feed, post database.Post) error {
+	if len(webhooks) == 0 {
+		return nil
+	}
+	payload := webhookPayload{
+		Event: "post.created",
+		Feed:  webhookFeed{ID: feed.ID, Name: feed.Name, URL: feed.Url},
+		Post: webhookPost{
+			ID:          post.ID,
+			Title:       post.Title,
+			URL:         post.Url,
+			Description: post.Description.String,
+			Author:      post.Author.String,
+			Categories:  post.Categories,
+			CreatedAt:   post.CreatedAt,
+		},
+	}
+	if post.PublishedAt.Valid {
+		payload.Post.PublishedAt = &post.PublishedAt.Time
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, w := range webhooks {
+		err := db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			ID:            uuid.New(),
+			CreatedAt:     time.Now(),
+			WebhookID:     w.ID,
+			PostID:        post.ID,
+			Payload:       string(data),
+			NextAttemptAt: time.Now(),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't queue webhook %s: %w", w.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliverWebhooks claims the deliveries due at now, sends them with client a
+// few at a time and records the outcome of each. Failed sends are rescheduled, not
+// returned as errors; the ones left when webhookDeadline passes are released
+// without counting an attempt.
+func deliverWebhooks(ctx context.Context, db database.Querier, client *http.Client, now time.Time) (delivered, undelivered int, err error) {
+	due, err := db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: now.Add(webhookLease),
+		Now:        now,
+		Lim:        webhookBatch,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("couldn't claim webhook deliveries: %w", err)
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, webhookDeadline)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, webhookConcurrency)
+	)
+	for _, d := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			update := database.UpdateWebhookDeliveryParams{
+				ID:             d.ID,
+				Status:         deliveryPending,
+				Attempts:       d.Attempts,
+				NextAttemptAt:  now,
+				LastAttemptAt:  d.LastAttemptAt,
+				LastError:      d.LastError,
+				ResponseStatus: d.ResponseStatus,
+			}
+			select {
+			case sem <- struct{}{}:
+				if sendCtx.Err() == nil {
+					update = webhookAttempt(sendCtx, client, d, now)
+				}
+				<-sem
+			case <-sendCtx.Done():
+			}
+			err := db.UpdateWebhookDelivery(ctx, update)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case update.Status == deliveryDelivered:
+				delivered++
+			case update.Attempts > d.Attempts:
+				undelivered++
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("couldn't update delivery %s: %w", d.ID, err))
+			}
+		}()
+	}
+	wg.Wait()
+	return delivered, undelivered, errors.Join(errs...)
+}
+
+// webhookAttempt sends a claimed delivery and returns its new state.
+func webhookAttempt(ctx context.Context, client *http.Client, d database.ClaimDueWebhookDeliveriesRow, now time.Time) database.UpdateWebhookDeliveryParams {
+	status, err := sendWebhook(ctx, client, d)
+	update := database.UpdateWebhookDeliveryParams{
+		ID:             d.ID,
+		Status:         deliveryDelivered,
+		Attempts:       d.Attempts + 1,
+		NextAttemptAt:  now,
+		LastAttemptAt:  sql.NullTime{Time: now, Valid: true},
+		ResponseStatus: sql.NullInt32{Int32: int32(status), Valid: status != 0},
+	}
+	if err != nil {
+		update.LastError = sql.NullString{String: err.Error(), Valid: true}
+		if int(update.Attempts) >= webhookMaxAttempts {
+			update.Status = deliveryFailed
+		} else {
+			update.Status = deliveryPending
+			update.NextAttemptAt = now.Add(webhookRetryDelay(int(update.Attempts)))
+		}
+	}
+	return update
+}
+
+// webhookRetryDelay is the wait before the next attempt after the given
+// number of failed ones.
+func webhookRetryDelay(attempts int) time.Duration {
+	d := webhookBackoff
+	for i := 1; i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, webhookMaxBackoff)
+}
+
+// signWebhook returns the X-Gator-Signature header for a body sent with the
+// given X-Gator-Timestamp.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook POSTs a delivery and returns the response status, if any.
+func sendWebhook(ctx context.Context, client *http.Client, d database.ClaimDueWebhookDeliveriesRow) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", d.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gator")
+	req.Header.Set("X-Gator-Event", "post.created")
+	req.Header.Set("X-Gator-Delivery", d.ID.String())
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Gator-Timestamp", timestamp)
+	req.Header.Set("X-Gator-Signature", signWebhook(d.Secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func webhooksTable(webhooks []database.ListWebhooksRow) ([]string, [][]any) {
+	rows := make([][]any, 0, len(webhooks))
+	for _, w := range webhooks {
+		feed, category := "(all)", "(all)"
+		if w.FeedID.Valid {
+			feed = w.FeedName.String
+		}
+		if w.FolderID.Valid {
+			category = w.FolderName.String
+		}
+		rows = append(rows, []any{w.ID, w.Url, feed, category, w.CreatedAt})
+	}
+	return []string{"id", "url", "feed", "category", "created_at"}, rows
+}
+
+func deliveriesTable(deliveries []database.ListWebhookDeliveriesRow) ([]string, [][]any) {
+	rows := make([][]any, 0, len(deliveries))
+	for _, d := range deliveries {
+		var next any
+		if d.Status == deliveryPending {
+			next = d.NextAttemptAt
+		}
+		rows = append(rows, []any{d.ID, d.Status, d.Attempts, d.ResponseStatus, d.LastError, next, d.Url, d.Title, d.CreatedAt})
+	}
+	return []string{"id", "status", "attempts", "response_status", "last_error", "next_attempt_at", "url", "title", "created_at"}, rows
+}